@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// AddressInput creates an address book entry, or replaces one on update
+type AddressInput struct {
+	Label      string `json:"label" binding:"max=50"`
+	Name       string `json:"name" binding:"required,max=100"`
+	Line1      string `json:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2" binding:"max=200"`
+	City       string `json:"city" binding:"required,max=100"`
+	State      string `json:"state" binding:"max=50"`
+	PostalCode string `json:"postal_code" binding:"max=20"`
+	Country    string `json:"country" binding:"required,len=2,alpha"`
+	Phone      string `json:"phone" binding:"max=30"`
+	IsDefault  bool   `json:"is_default"` // unsets the user's other default
+}
+
+func CreateAddress(c *gin.Context) {
+	var input AddressInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	address := models.Address{UserID: currentUser.ID}
+	if !saveAddress(c, &address, input) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Address created successfully",
+		Data:    address,
+	})
+}
+
+// GetAddresses lists the user's address book, the default entry first
+func GetAddresses(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var addresses []models.Address
+	if err := initializers.DB.Where("user_id = ? AND deleted_at IS NULL", currentUser.ID).
+		Order("is_default DESC, id ASC").
+		Find(&addresses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch addresses",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Addresses retrieved successfully",
+		Data:    addresses,
+	})
+}
+
+func GetAddress(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	address, ok := findAddress(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Address retrieved successfully",
+		Data:    address,
+	})
+}
+
+// UpdateAddress replaces the entry. Placed orders keep the address they were
+// shipped to.
+func UpdateAddress(c *gin.Context) {
+	var input AddressInput
+	if !bindAddressInput(c, &input) {
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	address, ok := findAddress(c, currentUser.ID)
+	if !ok {
+		return
+	}
+	if !saveAddress(c, &address, input) {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Address updated successfully",
+		Data:    address,
+	})
+}
+
+// DeleteAddress soft deletes the entry
+func DeleteAddress(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	address, ok := findAddress(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := initializers.DB.Model(&address).Updates(map[string]interface{}{
+		"deleted_at": &now,
+		"is_default": false,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete address",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Address deleted successfully",
+	})
+}
+
+// bindAddressInput binds the input and checks what's left of it once
+// trimmed. It writes the error response itself.
+func bindAddressInput(c *gin.Context, input *AddressInput) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return false
+	}
+
+	var errors []libs.ValidationError
+	required := []struct {
+		field string
+		value string
+	}{{"name", input.Name}, {"line1", input.Line1}, {"city", input.City}}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errors = append(errors, libs.ValidationError{Field: r.field, Message: r.field + " is required"})
+		}
+	}
+
+	if len(errors) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    errors,
+		})
+		return false
+	}
+	return true
+}
+
+// saveAddress applies the input to the address and saves it, unsetting the
+// user's other default when it becomes the default. It writes the error
+// response itself.
+func saveAddress(c *gin.Context, address *models.Address, input AddressInput) bool {
+	address.Label = strings.TrimSpace(input.Label)
+	address.PostalAddress = services.NormalizePostalAddress(models.PostalAddress{
+		Name:       input.Name,
+		Line1:      input.Line1,
+		Line2:      input.Line2,
+		City:       input.City,
+		State:      input.State,
+		PostalCode: input.PostalCode,
+		Country:    input.Country,
+		Phone:      input.Phone,
+	})
+	address.IsDefault = input.IsDefault
+
+	tx := initializers.DB.Begin()
+
+	err := tx.Save(address).Error
+	if err == nil && address.IsDefault {
+		err = tx.Model(&models.Address{}).
+			Where("user_id = ? AND id <> ? AND is_default = ?", address.UserID, address.ID, true).
+			Update("is_default", false).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save address",
+		})
+		return false
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save address",
+		})
+		return false
+	}
+	return true
+}
+
+func findAddress(c *gin.Context, userID uint) (models.Address, bool) {
+	var address models.Address
+	if err := initializers.DB.Where("id = ? AND user_id = ? AND deleted_at IS NULL", parseID(c.Param("id")), userID).
+		First(&address).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Address not found",
+			})
+			return address, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch address",
+		})
+		return address, false
+	}
+	return address, true
+}
@@ -0,0 +1,370 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// adminOrderSortFields maps the accepted sort= fields to order columns
+var adminOrderSortFields = map[string]string{
+	"id":           "orders.id",
+	"created_at":   "orders.created_at",
+	"updated_at":   "orders.updated_at",
+	"total_amount": "orders.total_amount_minor",
+	"status":       "orders.status",
+	"user_id":      "orders.user_id",
+}
+
+// adminOrdersQuery builds the filtered, unordered order query shared by the
+// admin listing and the export. Supported filters: status and payment_status
+// (comma separated), user_id, product_id, date_from/date_to (RFC3339 or YYYY-MM-DD), currency
+// and min_total/max_total. Totals are in the currency filter, the base
+// currency by default, and only match orders in that currency.
+func adminOrdersQuery(c *gin.Context) (*gorm.DB, []libs.ValidationError) {
+	var errors []libs.ValidationError
+	query := initializers.DB.Model(&models.Order{}).Where("orders.deleted_at IS NULL")
+
+	if raw := c.Query("status"); raw != "" {
+		var statuses []models.OrderStatus
+		for _, s := range strings.Split(raw, ",") {
+			status := models.OrderStatus(strings.TrimSpace(s))
+			if !status.IsValid() {
+				errors = append(errors, libs.ValidationError{
+					Field:   "status",
+					Message: fmt.Sprintf("Invalid status %q", status),
+				})
+				continue
+			}
+			statuses = append(statuses, status)
+		}
+		if len(statuses) > 0 {
+			query = query.Where("orders.status IN ?", statuses)
+		}
+	}
+
+	if raw := c.Query("payment_status"); raw != "" {
+		var statuses []models.OrderPaymentStatus
+		for _, s := range strings.Split(raw, ",") {
+			status := models.OrderPaymentStatus(strings.TrimSpace(s))
+			if !status.IsValid() {
+				errors = append(errors, libs.ValidationError{
+					Field:   "payment_status",
+					Message: fmt.Sprintf("Invalid payment status %q", status),
+				})
+				continue
+			}
+			statuses = append(statuses, status)
+		}
+		if len(statuses) > 0 {
+			query = query.Where("orders.payment_status IN ?", statuses)
+		}
+	}
+
+	if raw := c.Query("user_id"); raw != "" {
+		userID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "user_id", Message: "user_id must be a number"})
+		} else {
+			query = query.Where("orders.user_id = ?", userID)
+		}
+	}
+
+	if raw := c.Query("product_id"); raw != "" {
+		productID, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "product_id", Message: "product_id must be a number"})
+		} else {
+			query = query.Where("EXISTS (SELECT 1 FROM order_items WHERE order_items.order_id = orders.id AND order_items.product_id = ?)", productID)
+		}
+	}
+
+	if raw := c.Query("date_from"); raw != "" {
+		from, err := parseDateParam(raw, false)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "date_from", Message: err.Error()})
+		} else {
+			query = query.Where("orders.created_at >= ?", from)
+		}
+	}
+
+	if raw := c.Query("date_to"); raw != "" {
+		to, err := parseDateParam(raw, true)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "date_to", Message: err.Error()})
+		} else {
+			query = query.Where("orders.created_at <= ?", to)
+		}
+	}
+
+	totalCurrency := money.BaseCurrency()
+	if raw := c.Query("currency"); raw != "" {
+		currency, err := money.NormalizeCurrency(raw)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "currency", Message: err.Error()})
+		} else {
+			totalCurrency = currency
+			query = query.Where("orders.currency = ?", currency)
+		}
+	}
+	if c.Query("min_total") != "" || c.Query("max_total") != "" {
+		query = query.Where("orders.total_amount_currency = ?", totalCurrency)
+	}
+
+	if raw := c.Query("min_total"); raw != "" {
+		minTotal, err := money.Parse(raw, totalCurrency)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "min_total", Message: "min_total must be an amount"})
+		} else {
+			query = query.Where("orders.total_amount_minor >= ?", minTotal.Amount)
+		}
+	}
+
+	if raw := c.Query("max_total"); raw != "" {
+		maxTotal, err := money.Parse(raw, totalCurrency)
+		if err != nil {
+			errors = append(errors, libs.ValidationError{Field: "max_total", Message: "max_total must be an amount"})
+		} else {
+			query = query.Where("orders.total_amount_minor <= ?", maxTotal.Amount)
+		}
+	}
+
+	return query, errors
+}
+
+// parseDateParam accepts RFC3339 timestamps or plain dates. A plain date used
+// as an upper bound covers the whole day.
+func parseDateParam(raw string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, raw); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be a date (YYYY-MM-DD) or RFC3339 timestamp")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+func AdminGetOrders(c *gin.Context) {
+	query, validationErrors := adminOrdersQuery(c)
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid filters",
+			Data:    validationErrors,
+		})
+		return
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	// Cap maximum page size
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	sortFields, err := libs.ParseSort(c.DefaultQuery("sort", "-created_at"), adminOrderSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid filters",
+			Data:    []libs.ValidationError{{Field: "sort", Message: err.Error()}},
+		})
+		return
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch orders",
+		})
+		return
+	}
+
+	var orders []models.Order
+	offset := (page - 1) * pageSize
+	// id last keeps the order stable between pages
+	if err := query.Preload("User").Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").
+		Order(libs.OrderClause(sortFields)).Order("orders.id DESC").
+		Offset(offset).Limit(pageSize).Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch orders",
+		})
+		return
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Orders retrieved successfully",
+		Data:    orders,
+		Pagination: &libs.PaginationMeta{
+			CurrentPage: page,
+			PageSize:    pageSize,
+			TotalItems:  total,
+			TotalPages:  totalPages,
+		},
+	})
+}
+
+func AdminGetOrder(c *gin.Context) {
+	var order models.Order
+	err := initializers.DB.
+		Preload("User").
+		Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").Preload("Refunds.Items").Preload("Changes.Items").Preload("Shipments.Items").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Preload("History.Actor").
+		First(&order, parseID(c.Param("id"))).Error
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Order not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch order",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Order retrieved successfully",
+		Data:    order,
+	})
+}
+
+var orderExportHeader = []string{
+	"id", "user_id", "username", "email", "status", "payment_status", "total_amount",
+	"refunded_total", "currency", "exchange_rate", "item_count", "created_at", "updated_at",
+}
+
+// csvText neutralizes user-entered text for spreadsheets: a cell starting
+// with =, +, -, @, a tab or a carriage return would be run as a formula, so
+// it is prefixed with a quote
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// AdminExportOrders streams every order matching the listing filters as CSV
+// (default) or NDJSON (format=ndjson), reading the table in batches of
+// ascending ID (sort= is ignored)
+func AdminExportOrders(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid filters",
+			Data: []libs.ValidationError{{
+				Field:   "format",
+				Message: "format must be one of [csv, ndjson]",
+			}},
+		})
+		return
+	}
+
+	query, validationErrors := adminOrdersQuery(c)
+	if len(validationErrors) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid filters",
+			Data:    validationErrors,
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("orders-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var csvWriter *csv.Writer
+	var encoder *json.Encoder
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		csvWriter = csv.NewWriter(c.Writer)
+		csvWriter.Write(orderExportHeader)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder = json.NewEncoder(c.Writer)
+	}
+	c.Status(http.StatusOK)
+
+	var batch []models.Order
+	result := query.Preload("User").Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, order := range batch {
+				if csvWriter != nil {
+					itemCount := 0
+					for _, item := range order.Items {
+						itemCount += item.Quantity
+					}
+					csvWriter.Write([]string{
+						strconv.FormatUint(uint64(order.ID), 10),
+						strconv.FormatUint(uint64(order.UserID), 10),
+						csvText(order.User.Username),
+						csvText(order.User.Email),
+						string(order.Status),
+						string(order.PaymentStatus),
+						order.TotalAmount.String(),
+						order.RefundedTotal.String(),
+						order.Currency,
+						order.ExchangeRate,
+						strconv.Itoa(itemCount),
+						order.CreatedAt.Format(time.RFC3339),
+						order.UpdatedAt.Format(time.RFC3339),
+					})
+				} else if err := encoder.Encode(order); err != nil {
+					return err
+				}
+			}
+
+			if csvWriter != nil {
+				csvWriter.Flush()
+				if err := csvWriter.Error(); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		})
+
+	// Headers are already sent, so a failure can only be logged
+	if result.Error != nil {
+		log.Println("Failed to export orders", result.Error)
+	}
+}
@@ -0,0 +1,590 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// Issues that can be flagged on a cart line
+const (
+	CartIssueUnavailable       = "product_unavailable"
+	CartIssueOutOfStock        = "out_of_stock"
+	CartIssueInsufficientStock = "insufficient_stock"
+)
+
+type AddCartItemInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"` // required for products with variants
+	Quantity  int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// CheckoutInput is the optional body of a checkout
+type CheckoutInput struct {
+	CouponCodes      []string       `json:"coupon_codes"`
+	TaxRegion        TaxRegionInput `json:"tax_region"`
+	AddressID        uint           `json:"address_id"`
+	ShippingMethodID uint           `json:"shipping_method_id"`
+}
+
+type UpdateCartItemInput struct {
+	Quantity int `json:"quantity" binding:"required,gt=0"`
+}
+
+type CartItemResponse struct {
+	ID           uint                     `json:"id"`
+	ProductID    uint                     `json:"product_id"`
+	ProductName  string                   `json:"product_name"`
+	VariantID    *uint                    `json:"variant_id,omitempty"`
+	SKU          string                   `json:"sku,omitempty"`
+	Attributes   models.VariantAttributes `json:"attributes,omitempty"`
+	Quantity     int                      `json:"quantity"`
+	UnitPrice    money.Money              `json:"unit_price"`    // current product (or variant) price in the cart currency
+	AddedPrice   money.Money              `json:"added_price"`   // base currency price when the line was added/updated
+	PriceChanged bool                     `json:"price_changed"` // current base price differs from added price
+	LineTotal    money.Money              `json:"line_total"`
+	Available    int                      `json:"available"`
+	Issues       []string                 `json:"issues,omitempty"`
+}
+
+type CartResponse struct {
+	ID        uint               `json:"id"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Items     []CartItemResponse `json:"items"`
+	ItemCount int                `json:"item_count"`
+	Currency  string             `json:"currency"`
+	Subtotal  money.Money        `json:"subtotal"` // excludes unavailable lines
+	HasIssues bool               `json:"has_issues"`
+}
+
+// getOrCreateCart returns the user's cart with items and their products loaded
+func getOrCreateCart(db *gorm.DB, userID uint) (models.Cart, error) {
+	var cart models.Cart
+	if err := db.Where(models.Cart{UserID: userID}).FirstOrCreate(&cart).Error; err != nil {
+		return cart, err
+	}
+
+	err := db.Where("cart_id = ?", cart.ID).
+		Preload("Product").
+		Preload("Variant").
+		Order("id ASC").
+		Find(&cart.Items).Error
+
+	return cart, err
+}
+
+// cartLineState returns the current base currency unit price and stock of a
+// cart line and whether its product (and variant) can still be ordered
+func cartLineState(item models.CartItem) (money.Money, int, bool) {
+	product := item.Product
+	available := product.ID != 0 && product.IsActive && product.DeletedAt == nil
+
+	if item.VariantID == nil {
+		return product.Price, product.Stock, available
+	}
+	if item.Variant == nil {
+		return product.Price, 0, false
+	}
+	return item.Variant.EffectivePrice(product), item.Variant.Stock, available && item.Variant.IsAvailable()
+}
+
+// buildCartResponse re-prices every line against the current product data in
+// the pricer's currency and flags lines that can no longer be ordered
+func buildCartResponse(cart models.Cart, pricer services.Pricer) CartResponse {
+	response := CartResponse{
+		ID:        cart.ID,
+		UpdatedAt: cart.UpdatedAt,
+		Items:     []CartItemResponse{},
+		Currency:  pricer.Currency,
+		Subtotal:  money.New(0, pricer.Currency),
+	}
+
+	for _, item := range cart.Items {
+		basePrice, stock, available := cartLineState(item)
+		price := pricer.Price(item.Product, item.Variant)
+
+		line := CartItemResponse{
+			ID:           item.ID,
+			ProductID:    item.ProductID,
+			ProductName:  item.Product.Name,
+			VariantID:    item.VariantID,
+			Quantity:     item.Quantity,
+			UnitPrice:    price,
+			AddedPrice:   item.Price,
+			PriceChanged: basePrice != item.Price,
+			LineTotal:    price.Mul(item.Quantity),
+			Available:    stock,
+		}
+		if item.Variant != nil {
+			line.SKU = item.Variant.SKU
+			line.Attributes = item.Variant.Attributes
+		}
+
+		switch {
+		case !available:
+			line.Issues = append(line.Issues, CartIssueUnavailable)
+		case stock <= 0:
+			line.Issues = append(line.Issues, CartIssueOutOfStock)
+		case stock < item.Quantity:
+			line.Issues = append(line.Issues, CartIssueInsufficientStock)
+		}
+
+		if len(line.Issues) > 0 {
+			response.HasIssues = true
+		}
+		if len(line.Issues) == 0 || line.Issues[0] != CartIssueUnavailable {
+			response.Subtotal, _ = response.Subtotal.Add(line.LineTotal)
+		}
+
+		response.ItemCount += item.Quantity
+		response.Items = append(response.Items, line)
+	}
+
+	return response
+}
+
+func respondWithCart(c *gin.Context, status int, message string, userID uint) {
+	cart, err := getOrCreateCart(initializers.DB, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch cart",
+		})
+		return
+	}
+
+	pricer, ok := pricerFor(c, cartProducts(cart))
+	if !ok {
+		return
+	}
+
+	c.JSON(status, ProductResponse{
+		Status:  "success",
+		Message: message,
+		Data:    buildCartResponse(cart, pricer),
+	})
+}
+
+func GetCart(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	respondWithCart(c, http.StatusOK, "Cart retrieved successfully", currentUser.ID)
+}
+
+func AddCartItem(c *gin.Context) {
+	var input AddCartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var product models.Product
+	if err := initializers.DB.Where("is_active = ? AND deleted_at IS NULL", true).
+		First(&product, input.ProductID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product",
+		})
+		return
+	}
+
+	price, stock := product.Price, product.Stock
+	var variant *models.ProductVariant
+
+	if input.VariantID != 0 {
+		variant = &models.ProductVariant{}
+		if err := initializers.DB.Where("product_id = ? AND is_active = ? AND deleted_at IS NULL", product.ID, true).
+			First(variant, input.VariantID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, ProductResponse{
+					Status:  "error",
+					Message: "Product variant not found",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to fetch product variant",
+			})
+			return
+		}
+		price, stock = variant.EffectivePrice(product), variant.Stock
+	} else {
+		hasVariants, err := services.ProductHasVariants(product.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to fetch product",
+			})
+			return
+		}
+		if hasVariants {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid product variant",
+				Data: []libs.ValidationError{{
+					Field:   "variant_id",
+					Message: "a variant must be selected for this product",
+				}},
+			})
+			return
+		}
+	}
+
+	cart, err := getOrCreateCart(initializers.DB, currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch cart",
+		})
+		return
+	}
+
+	// Adding a product (variant) that is already in the cart increases its quantity
+	item := models.CartItem{CartID: cart.ID, ProductID: product.ID}
+	if variant != nil {
+		item.VariantID = &variant.ID
+	}
+	for _, existing := range cart.Items {
+		if existing.ProductID == product.ID && sameVariant(existing.VariantID, item.VariantID) {
+			item = existing
+			break
+		}
+	}
+
+	quantity := item.Quantity + input.Quantity
+	if stock < quantity {
+		shortage := services.InsufficientStock{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Requested:   quantity,
+			Available:   stock,
+		}
+		if variant != nil {
+			shortage.VariantID = variant.ID
+			shortage.SKU = variant.SKU
+		}
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Insufficient stock",
+			Data:    shortage,
+		})
+		return
+	}
+
+	item.Quantity = quantity
+	item.Price = price
+	item.Product = models.Product{}
+	item.Variant = nil
+	if err := initializers.DB.Save(&item).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to add item to cart",
+		})
+		return
+	}
+
+	respondWithCart(c, http.StatusOK, "Item added to cart successfully", currentUser.ID)
+}
+
+func UpdateCartItem(c *gin.Context) {
+	var input UpdateCartItemInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	item, ok := findCartItem(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	// the same checks as adding the line: the product (variant) must still be
+	// orderable as it is
+	price, stock, available := cartLineState(item)
+	if !available {
+		message := "Product not found"
+		if item.Product.IsActive && item.Product.DeletedAt == nil {
+			message = "Product variant not found"
+		}
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: message,
+		})
+		return
+	}
+	if item.VariantID == nil {
+		hasVariants, err := services.ProductHasVariants(item.ProductID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to fetch product",
+			})
+			return
+		}
+		if hasVariants {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid product variant",
+				Data: []libs.ValidationError{{
+					Field:   "variant_id",
+					Message: "a variant must be selected for this product",
+				}},
+			})
+			return
+		}
+	}
+	if stock < input.Quantity {
+		shortage := services.InsufficientStock{
+			ProductID:   item.ProductID,
+			ProductName: item.Product.Name,
+			Requested:   input.Quantity,
+			Available:   stock,
+		}
+		if item.Variant != nil {
+			shortage.VariantID = item.Variant.ID
+			shortage.SKU = item.Variant.SKU
+		}
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Insufficient stock",
+			Data:    shortage,
+		})
+		return
+	}
+
+	if err := initializers.DB.Model(&models.CartItem{}).Where("id = ?", item.ID).Updates(map[string]interface{}{
+		"quantity":       input.Quantity,
+		"price_minor":    price.Amount,
+		"price_currency": price.Currency,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to update cart item",
+		})
+		return
+	}
+
+	respondWithCart(c, http.StatusOK, "Cart item updated successfully", currentUser.ID)
+}
+
+func RemoveCartItem(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	item, ok := findCartItem(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	if err := initializers.DB.Delete(&models.CartItem{}, item.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to remove cart item",
+		})
+		return
+	}
+
+	respondWithCart(c, http.StatusOK, "Cart item removed successfully", currentUser.ID)
+}
+
+func ClearCart(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	cart, err := getOrCreateCart(initializers.DB, currentUser.ID)
+	if err == nil {
+		err = initializers.DB.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to clear cart",
+		})
+		return
+	}
+
+	respondWithCart(c, http.StatusOK, "Cart cleared successfully", currentUser.ID)
+}
+
+// Checkout turns the cart into an order and empties the cart in one transaction
+func Checkout(c *gin.Context) {
+	var input CheckoutInput
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	tx := initializers.DB.Begin()
+
+	cart, err := getOrCreateCart(tx, currentUser.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch cart",
+		})
+		return
+	}
+
+	if len(cart.Items) == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Cart is empty",
+		})
+		return
+	}
+
+	pricer, ok := pricerFor(c, cartProducts(cart))
+	if !ok {
+		tx.Rollback()
+		return
+	}
+
+	cartResponse := buildCartResponse(cart, pricer)
+	if cartResponse.HasIssues {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Some cart items are unavailable or out of stock",
+			Data:    cartResponse,
+		})
+		return
+	}
+
+	lines := make([]services.OrderLine, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		line := services.OrderLine{
+			ProductID: item.ProductID,
+			Quantity:  item.Quantity,
+		}
+		if item.VariantID != nil {
+			line.VariantID = *item.VariantID
+		}
+		lines = append(lines, line)
+	}
+
+	order, err := services.CreateOrderTx(tx, currentUser.ID, lines, services.OrderOptions{
+		Currency:         pricer.Currency,
+		CouponCodes:      input.CouponCodes,
+		TaxRegion:        input.TaxRegion.toModel(),
+		AddressID:        input.AddressID,
+		ShippingMethodID: input.ShippingMethodID,
+	})
+	if err != nil {
+		tx.Rollback()
+		respondOrderCreationError(c, err)
+		return
+	}
+
+	if err := tx.Where("cart_id = ?", cart.ID).Delete(&models.CartItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to clear cart",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		log.Println("Failed to commit checkout", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to create order",
+		})
+		return
+	}
+
+	// Load order items for response
+	initializers.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").First(&order, order.ID)
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Order created successfully",
+		Data:    order,
+	})
+}
+
+// findCartItem loads the cart line from the :id path param, making sure it
+// belongs to the user's cart. It writes the error response itself.
+func findCartItem(c *gin.Context, userID uint) (models.CartItem, bool) {
+	var item models.CartItem
+	err := initializers.DB.
+		Joins("JOIN carts ON carts.id = cart_items.cart_id").
+		Where("cart_items.id = ? AND carts.user_id = ?", c.Param("id"), userID).
+		Preload("Product").
+		Preload("Variant").
+		First(&item).Error
+
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Cart item not found",
+			})
+			return item, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch cart item",
+		})
+		return item, false
+	}
+
+	return item, true
+}
+
+func cartProducts(cart models.Cart) []models.Product {
+	products := make([]models.Product, 0, len(cart.Items))
+	for _, item := range cart.Items {
+		products = append(products, item.Product)
+	}
+	return products
+}
+
+func sameVariant(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
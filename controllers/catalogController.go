@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// CatalogProduct is the customer facing view of a product. Stock levels,
+// activation and deletion state stay admin-only.
+type CatalogProduct struct {
+	ID          uint              `json:"id"`
+	CreatedAt   time.Time         `json:"created_at"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Price       money.Money       `json:"price"`
+	InStock     bool              `json:"in_stock"`
+	Categories  []CatalogCategory `json:"categories"`
+	Variants    []CatalogVariant  `json:"variants,omitempty"`
+	Images      []CatalogImage    `json:"images"`
+
+	Highlight *models.ProductHighlight `json:"highlight,omitempty"`
+}
+
+type CatalogImage struct {
+	ID         uint                        `json:"id"`
+	URL        string                      `json:"url"`
+	Width      int                         `json:"width"`
+	Height     int                         `json:"height"`
+	IsPrimary  bool                        `json:"is_primary"`
+	Thumbnails map[string]CatalogThumbnail `json:"thumbnails"`
+}
+
+type CatalogThumbnail struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+type CatalogVariant struct {
+	ID         uint                     `json:"id"`
+	SKU        string                   `json:"sku"`
+	Attributes models.VariantAttributes `json:"attributes"`
+	Price      money.Money              `json:"price"`
+	InStock    bool                     `json:"in_stock"`
+}
+
+type CatalogCategory struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// catalogSortFields maps the accepted sort= fields to product columns
+var catalogSortFields = map[string]string{
+	"name":       "name",
+	"price":      "price_minor",
+	"created_at": "created_at",
+}
+
+// toCatalogProduct builds the customer view of a product with its prices in
+// the pricer's currency
+func toCatalogProduct(product models.Product, pricer services.Pricer) CatalogProduct {
+	categories := make([]CatalogCategory, 0, len(product.Categories))
+	for _, category := range product.Categories {
+		categories = append(categories, CatalogCategory{
+			ID:   category.ID,
+			Name: category.Name,
+			Slug: category.Slug,
+		})
+	}
+
+	catalogProduct := CatalogProduct{
+		ID:          product.ID,
+		CreatedAt:   product.CreatedAt,
+		Name:        product.Name,
+		Description: product.Description,
+		Price:       pricer.Price(product, nil),
+		InStock:     product.Stock > 0,
+		Categories:  categories,
+		Highlight:   product.Highlight,
+	}
+
+	catalogProduct.Images = make([]CatalogImage, 0, len(product.Images))
+	for _, image := range product.Images {
+		thumbnails := make(map[string]CatalogThumbnail, len(image.Thumbnails))
+		for name, thumb := range image.Thumbnails {
+			thumbnails[name] = CatalogThumbnail{URL: thumb.URL, Width: thumb.Width, Height: thumb.Height}
+		}
+		catalogProduct.Images = append(catalogProduct.Images, CatalogImage{
+			ID:         image.ID,
+			URL:        image.URL,
+			Width:      image.Width,
+			Height:     image.Height,
+			IsPrimary:  image.IsPrimary,
+			Thumbnails: thumbnails,
+		})
+	}
+
+	// A product with variants is in stock when any of its variants is
+	if len(product.Variants) > 0 {
+		catalogProduct.InStock = false
+	}
+	for i, variant := range product.Variants {
+		catalogProduct.Variants = append(catalogProduct.Variants, CatalogVariant{
+			ID:         variant.ID,
+			SKU:        variant.SKU,
+			Attributes: variant.Attributes,
+			Price:      pricer.Price(product, &product.Variants[i]),
+			InStock:    variant.Stock > 0,
+		})
+		if variant.Stock > 0 {
+			catalogProduct.InStock = true
+		}
+	}
+
+	return catalogProduct
+}
+
+// preloadCatalogVariants only loads the variants customers can order
+func preloadCatalogVariants(db *gorm.DB) *gorm.DB {
+	return db.Where("is_active = ? AND deleted_at IS NULL", true).Order("id ASC")
+}
+
+// catalogQuery only ever sees active, non-deleted products
+func catalogQuery() *gorm.DB {
+	return initializers.DB.Model(&models.Product{}).
+		Where("is_active = ? AND deleted_at IS NULL", true)
+}
+
+func GetCatalogProducts(c *gin.Context) {
+	// Pagination parameters
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	// searches are ordered by relevance unless a sort is asked for
+	search, searching := services.NewProductSearch(c.Query("q"))
+	defaultSort := "name"
+	if searching {
+		defaultSort = ""
+	}
+
+	sortFields, err := libs.ParseSort(c.DefaultQuery("sort", defaultSort), catalogSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid sort",
+			Data:    []libs.ValidationError{{Field: "sort", Message: err.Error()}},
+		})
+		return
+	}
+	if len(sortFields) == 0 && !searching {
+		sortFields = []libs.SortField{{Field: "name", Column: "name"}}
+	}
+
+	query := applyProductFilters(catalogQuery(), c)
+
+	var facets *services.ProductFacets
+	if c.Query("facets") == "true" {
+		if facets, err = services.ComputeProductFacets(query); err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to compute product facets",
+			})
+			return
+		}
+	}
+
+	if usesCursor(c) {
+		if len(sortFields) == 0 {
+			respondSearchWithCursor(c)
+			return
+		}
+
+		fields := libs.WithTiebreak(sortFields, libs.SortField{Field: "id", Column: "products.id"})
+		products, cursor, ok := fetchCursorPage(c,
+			query.Preload("Categories").Preload("Variants", preloadCatalogVariants).Preload("Images", orderedImages),
+			fields, pageSize, productSortValues(fields))
+		if !ok {
+			return
+		}
+
+		if searching {
+			if err := attachHighlights(search, products); err != nil {
+				c.JSON(http.StatusInternalServerError, ProductResponse{
+					Status:  "error",
+					Message: "Failed to fetch products",
+				})
+				return
+			}
+		}
+
+		pricer, ok := pricerFor(c, products)
+		if !ok {
+			return
+		}
+
+		catalog := make([]CatalogProduct, 0, len(products))
+		for _, product := range products {
+			catalog = append(catalog, toCatalogProduct(product, pricer))
+		}
+
+		c.JSON(http.StatusOK, ProductResponse{
+			Status:  "success",
+			Message: "Products retrieved successfully",
+			Data:    catalog,
+			Cursor:  cursor,
+			Facets:  facets,
+		})
+		return
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch products",
+		})
+		return
+	}
+
+	offset := (page - 1) * pageSize
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	if len(sortFields) > 0 {
+		query = query.Order(libs.OrderClause(sortFields))
+	} else {
+		query = search.OrderByRank(query)
+	}
+
+	// id last keeps the order stable between pages
+	var products []models.Product
+	if err := query.Preload("Categories").Preload("Variants", preloadCatalogVariants).Preload("Images", orderedImages).
+		Order("id ASC").Offset(offset).Limit(pageSize).Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch products",
+		})
+		return
+	}
+
+	if searching {
+		if err := attachHighlights(search, products); err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to fetch products",
+			})
+			return
+		}
+	}
+
+	pricer, ok := pricerFor(c, products)
+	if !ok {
+		return
+	}
+
+	catalog := make([]CatalogProduct, 0, len(products))
+	for _, product := range products {
+		catalog = append(catalog, toCatalogProduct(product, pricer))
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Products retrieved successfully",
+		Data:    catalog,
+		Pagination: &libs.PaginationMeta{
+			CurrentPage: page,
+			PageSize:    pageSize,
+			TotalItems:  total,
+			TotalPages:  totalPages,
+		},
+		Facets: facets,
+	})
+}
+
+func GetCatalogProduct(c *gin.Context) {
+	var product models.Product
+	if err := catalogQuery().Preload("Categories").Preload("Variants", preloadCatalogVariants).
+		Preload("Images", orderedImages).First(&product, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product",
+		})
+		return
+	}
+
+	pricer, ok := pricerFor(c, []models.Product{product})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product retrieved successfully",
+		Data:    toCatalogProduct(product, pricer),
+	})
+}
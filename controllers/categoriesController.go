@@ -0,0 +1,311 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+type CreateCategoryInput struct {
+	Name        string `json:"name" binding:"required"`
+	Slug        string `json:"slug"`
+	Description string `json:"description"`
+	ParentID    *uint  `json:"parent_id"`
+}
+
+type UpdateCategoryInput struct {
+	Name        string  `json:"name"`
+	Slug        string  `json:"slug"`
+	Description *string `json:"description"`
+	ParentID    *uint   `json:"parent_id"` // 0 moves the category to the top level
+}
+
+func CreateCategory(c *gin.Context) {
+	var input CreateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	category := models.Category{
+		Name:        input.Name,
+		Slug:        services.Slugify(input.Slug),
+		Description: input.Description,
+	}
+	if category.Slug == "" {
+		category.Slug = services.Slugify(input.Name)
+	}
+	if category.Slug == "" {
+		// e.g. a name of only punctuation; without a slug of its own it
+		// would collide with the next such category
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "slug",
+				Message: "name has no letter or digit to build a slug from, a slug is required",
+			}},
+		})
+		return
+	}
+
+	if input.ParentID != nil && *input.ParentID != 0 {
+		if !validateCategoryParent(c, 0, *input.ParentID) {
+			return
+		}
+		category.ParentID = input.ParentID
+	}
+
+	if !ensureUniqueSlug(c, category.Slug, 0) {
+		return
+	}
+
+	if err := initializers.DB.Create(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to create category",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Category created successfully",
+		Data:    category,
+	})
+}
+
+func GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := initializers.DB.Order("name ASC, id ASC").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch categories",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Categories retrieved successfully",
+		Data:    categories,
+	})
+}
+
+func GetCategory(c *gin.Context) {
+	category, ok := findCategory(c)
+	if !ok {
+		return
+	}
+
+	initializers.DB.Where("parent_id = ?", category.ID).Order("name ASC").Find(&category.Children)
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Category retrieved successfully",
+		Data:    category,
+	})
+}
+
+// GetCategoryTree returns every category nested under its parent, with counts
+// of the active products in each subtree
+func GetCategoryTree(c *gin.Context) {
+	tree, err := services.CategoryTree()
+	if err != nil {
+		log.Println("Failed to build category tree", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch categories",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Categories retrieved successfully",
+		Data:    tree,
+	})
+}
+
+func UpdateCategory(c *gin.Context) {
+	var input UpdateCategoryInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	category, ok := findCategory(c)
+	if !ok {
+		return
+	}
+
+	// Update fields if provided
+	if input.Name != "" {
+		category.Name = input.Name
+	}
+	if input.Slug != "" {
+		category.Slug = services.Slugify(input.Slug)
+		if !ensureUniqueSlug(c, category.Slug, category.ID) {
+			return
+		}
+	}
+	if input.Description != nil {
+		category.Description = *input.Description
+	}
+	if input.ParentID != nil {
+		if *input.ParentID == 0 {
+			category.ParentID = nil
+		} else {
+			if !validateCategoryParent(c, category.ID, *input.ParentID) {
+				return
+			}
+			category.ParentID = input.ParentID
+		}
+		category.Parent = nil
+	}
+
+	if err := initializers.DB.Save(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to update category",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Category updated successfully",
+		Data:    category,
+	})
+}
+
+// DeleteCategory removes a category without children; its product
+// assignments are removed, the products themselves stay
+func DeleteCategory(c *gin.Context) {
+	category, ok := findCategory(c)
+	if !ok {
+		return
+	}
+
+	var children int64
+	initializers.DB.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&children)
+	if children > 0 {
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Category has subcategories, move or delete them first",
+		})
+		return
+	}
+
+	tx := initializers.DB.Begin()
+
+	if err := tx.Model(&category).Association("Products").Clear(); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete category",
+		})
+		return
+	}
+
+	if err := tx.Delete(&category).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete category",
+		})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Category deleted successfully",
+	})
+}
+
+func findCategory(c *gin.Context) (models.Category, bool) {
+	var category models.Category
+	if err := initializers.DB.First(&category, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Category not found",
+			})
+			return category, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch category",
+		})
+		return category, false
+	}
+	return category, true
+}
+
+func validateCategoryParent(c *gin.Context, categoryID uint, parentID uint) bool {
+	err := services.ValidateCategoryParent(categoryID, parentID)
+	switch err {
+	case nil:
+		return true
+	case services.ErrCategoryParentMissing, services.ErrCategoryCycle:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid parent category",
+			Data: []libs.ValidationError{{
+				Field:   "parent_id",
+				Message: err.Error(),
+			}},
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to validate parent category",
+		})
+	}
+	return false
+}
+
+func ensureUniqueSlug(c *gin.Context, slug string, categoryID uint) bool {
+	if slug == "" {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "slug",
+				Message: "slug must contain at least one letter or digit",
+			}},
+		})
+		return false
+	}
+
+	var count int64
+	initializers.DB.Model(&models.Category{}).Where("slug = ? AND id <> ?", slug, categoryID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Category already exists",
+			Data: []libs.ValidationError{{
+				Field:   "slug",
+				Message: "This slug is already used by another category",
+			}},
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// CouponInput creates a coupon, or replaces all settings of one on update
+type CouponInput struct {
+	Code          string            `json:"code" binding:"required,max=64"`
+	Description   string            `json:"description"`
+	Type          models.CouponType `json:"type" binding:"required,oneof=percentage fixed_amount free_shipping"`
+	Percentage    int               `json:"percentage" binding:"omitempty,gt=0,lte=100"` // percentage coupons
+	AmountOff     money.Money       `json:"amount_off" binding:"omitempty,gt=0"`         // fixed_amount coupons
+	MinOrderValue money.Money       `json:"min_order_value" binding:"omitempty,gte=0"`
+	StartsAt      *time.Time        `json:"starts_at"`
+	EndsAt        *time.Time        `json:"ends_at"`
+	UsageLimit    *int              `json:"usage_limit" binding:"omitempty,gt=0"`
+	PerUserLimit  *int              `json:"per_user_limit" binding:"omitempty,gt=0"`
+	Stackable     bool              `json:"stackable"`
+	IsActive      *bool             `json:"is_active"` // defaults to true
+	ProductIDs    []uint            `json:"product_ids"`
+	CategoryIDs   []uint            `json:"category_ids"`
+}
+
+func CreateCoupon(c *gin.Context) {
+	var input CouponInput
+	if !bindCouponInput(c, &input) {
+		return
+	}
+
+	coupon := models.Coupon{IsActive: true}
+	if !saveCoupon(c, &coupon, input) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Coupon created successfully",
+		Data:    coupon,
+	})
+}
+
+func GetCoupons(c *gin.Context) {
+	var coupons []models.Coupon
+	if err := initializers.DB.Where("deleted_at IS NULL").Order("id DESC").Find(&coupons).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch coupons",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Coupons retrieved successfully",
+		Data:    coupons,
+	})
+}
+
+func GetCoupon(c *gin.Context) {
+	coupon, ok := findCoupon(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Coupon retrieved successfully",
+		Data:    coupon,
+	})
+}
+
+// UpdateCoupon replaces the coupon's settings; its usage count is kept
+func UpdateCoupon(c *gin.Context) {
+	var input CouponInput
+	if !bindCouponInput(c, &input) {
+		return
+	}
+
+	coupon, ok := findCoupon(c)
+	if !ok {
+		return
+	}
+	if input.IsActive == nil {
+		input.IsActive = &coupon.IsActive
+	}
+	if !saveCoupon(c, &coupon, input) {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Coupon updated successfully",
+		Data:    coupon,
+	})
+}
+
+// DeleteCoupon soft deletes the coupon; orders keep the discounts it gave
+func DeleteCoupon(c *gin.Context) {
+	coupon, ok := findCoupon(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := initializers.DB.Model(&coupon).Update("deleted_at", &now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete coupon",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Coupon deleted successfully",
+	})
+}
+
+// bindCouponInput binds and checks the rules that depend on the coupon type.
+// It writes the error response itself.
+func bindCouponInput(c *gin.Context, input *CouponInput) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return false
+	}
+
+	input.Code = services.NormalizeCouponCode(input.Code)
+
+	var errors []libs.ValidationError
+	if input.Code == "" {
+		errors = append(errors, libs.ValidationError{Field: "code", Message: "code is required"})
+	}
+	switch input.Type {
+	case models.CouponPercentage:
+		if input.Percentage == 0 {
+			errors = append(errors, libs.ValidationError{Field: "percentage", Message: "percentage is required for percentage coupons"})
+		}
+	case models.CouponFixedAmount:
+		if !input.AmountOff.IsSet() {
+			errors = append(errors, libs.ValidationError{Field: "amount_off", Message: "amount_off is required for fixed_amount coupons"})
+		}
+	}
+	if input.AmountOff.IsSet() && input.AmountOff.Currency != money.BaseCurrency() {
+		errors = append(errors, libs.ValidationError{Field: "amount_off", Message: "Amounts must be in " + money.BaseCurrency()})
+	}
+	if input.MinOrderValue.IsSet() && input.MinOrderValue.Currency != money.BaseCurrency() {
+		errors = append(errors, libs.ValidationError{Field: "min_order_value", Message: "Amounts must be in " + money.BaseCurrency()})
+	}
+	if input.StartsAt != nil && input.EndsAt != nil && !input.EndsAt.After(*input.StartsAt) {
+		errors = append(errors, libs.ValidationError{Field: "ends_at", Message: "ends_at must be after starts_at"})
+	}
+
+	if len(errors) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    errors,
+		})
+		return false
+	}
+	return true
+}
+
+// saveCoupon applies the input to the coupon and saves it with its product
+// and category restrictions. It writes the error response itself.
+func saveCoupon(c *gin.Context, coupon *models.Coupon, input CouponInput) bool {
+	var count int64
+	initializers.DB.Model(&models.Coupon{}).Where("code = ? AND id <> ?", input.Code, coupon.ID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Coupon already exists",
+			Data: []libs.ValidationError{{
+				Field:   "code",
+				Message: "This code is already used by another coupon",
+			}},
+		})
+		return false
+	}
+
+	categories, ok := loadProductCategories(c, input.CategoryIDs)
+	if !ok {
+		return false
+	}
+	products, ok := loadCouponProducts(c, input.ProductIDs)
+	if !ok {
+		return false
+	}
+
+	coupon.Code = input.Code
+	coupon.Description = input.Description
+	coupon.Type = input.Type
+	coupon.Percentage = 0
+	coupon.AmountOff = money.Money{}
+	switch input.Type {
+	case models.CouponPercentage:
+		coupon.Percentage = input.Percentage
+	case models.CouponFixedAmount:
+		coupon.AmountOff = input.AmountOff
+	}
+	coupon.MinOrderValue = input.MinOrderValue
+	coupon.StartsAt = input.StartsAt
+	coupon.EndsAt = input.EndsAt
+	coupon.UsageLimit = input.UsageLimit
+	coupon.PerUserLimit = input.PerUserLimit
+	coupon.Stackable = input.Stackable
+	if input.IsActive != nil {
+		coupon.IsActive = *input.IsActive
+	}
+	coupon.Products = nil
+	coupon.Categories = nil
+
+	tx := initializers.DB.Begin()
+
+	// Save skips the false is_active on create because of its default
+	err := tx.Save(coupon).Error
+	if err == nil {
+		err = tx.Model(coupon).Update("is_active", coupon.IsActive).Error
+	}
+	if err == nil {
+		err = tx.Model(coupon).Association("Products").Replace(products)
+	}
+	if err == nil {
+		err = tx.Model(coupon).Association("Categories").Replace(categories)
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save coupon",
+		})
+		return false
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save coupon",
+		})
+		return false
+	}
+
+	coupon.Products = products
+	coupon.Categories = categories
+	return true
+}
+
+func loadCouponProducts(c *gin.Context, ids []uint) ([]models.Product, bool) {
+	products := []models.Product{}
+	if len(ids) == 0 {
+		return products, true
+	}
+
+	if err := initializers.DB.Where("id IN ? AND deleted_at IS NULL", ids).Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch products",
+		})
+		return nil, false
+	}
+
+	found := make(map[uint]bool, len(products))
+	for _, product := range products {
+		found[product.ID] = true
+	}
+	var missing []uint
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "product_ids",
+				Message: fmt.Sprintf("Products not found: %v", missing),
+			}},
+		})
+		return nil, false
+	}
+
+	return products, true
+}
+
+func findCoupon(c *gin.Context) (models.Coupon, bool) {
+	var coupon models.Coupon
+	if err := initializers.DB.Where("deleted_at IS NULL").Preload("Products").Preload("Categories").
+		First(&coupon, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Coupon not found",
+			})
+			return coupon, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch coupon",
+		})
+		return coupon, false
+	}
+	return coupon, true
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
+)
+
+// usesCursor reports whether the listing was asked for in cursor mode. An
+// empty cursor= starts at the first page.
+func usesCursor(c *gin.Context) bool {
+	_, ok := c.GetQuery("cursor")
+	return ok
+}
+
+// fetchCursorPage loads one keyset page of query into T. fields is the full
+// ordering and must end in the id column; values returns a row's values for
+// those fields. It writes the error response itself.
+func fetchCursorPage[T any](c *gin.Context, query *gorm.DB, fields []libs.SortField, pageSize int, values func(T) []interface{}) ([]T, *libs.CursorMeta, bool) {
+	var cursor *libs.Cursor
+	if raw := c.Query("cursor"); raw != "" {
+		decoded, err := libs.DecodeCursor(raw, fields)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid cursor",
+				Data:    []libs.ValidationError{{Field: "cursor", Message: err.Error()}},
+			})
+			return nil, nil, false
+		}
+		cursor = &decoded
+
+		condition, args := libs.KeysetCondition(fields, decoded)
+		query = query.Where(condition, args...)
+	}
+
+	var rows []T
+	if err := query.Order(libs.KeysetOrder(fields, cursor)).Limit(pageSize + 1).Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch results",
+		})
+		return nil, nil, false
+	}
+
+	rows, meta := libs.CursorPage(rows, fields, cursor, pageSize, values)
+	return rows, &meta, true
+}
+
+// productSortValues returns the values of a product for the given sort fields
+func productSortValues(fields []libs.SortField) func(models.Product) []interface{} {
+	return func(product models.Product) []interface{} {
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			switch field.Field {
+			case "id":
+				values[i] = product.ID
+			case "name":
+				values[i] = product.Name
+			case "price":
+				values[i] = product.Price.Amount
+			case "stock":
+				values[i] = product.Stock
+			case "created_at":
+				values[i] = product.CreatedAt
+			case "updated_at":
+				values[i] = product.UpdatedAt
+			}
+		}
+		return values
+	}
+}
+
+// orderSortValues returns the values of an order for the given sort fields
+func orderSortValues(fields []libs.SortField) func(models.Order) []interface{} {
+	return func(order models.Order) []interface{} {
+		values := make([]interface{}, len(fields))
+		for i, field := range fields {
+			switch field.Field {
+			case "id":
+				values[i] = order.ID
+			case "created_at":
+				values[i] = order.CreatedAt
+			case "updated_at":
+				values[i] = order.UpdatedAt
+			case "total_amount":
+				values[i] = order.TotalAmount.Amount
+			case "status":
+				values[i] = order.Status
+			}
+		}
+		return values
+	}
+}
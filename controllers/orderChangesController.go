@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/payments"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// EditOrderInput sets the quantities of products on a pending order. Products
+// not listed are left as they are.
+type EditOrderInput struct {
+	Items []EditOrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Note  string               `json:"note" binding:"max=500"`
+}
+
+type EditOrderItemInput struct {
+	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"`                        // required for products with variants
+	Quantity  *int `json:"quantity" binding:"required,gte=0"` // 0 removes the item
+}
+
+// CancelOrderItemsInput cancels units of a processing order's items
+type CancelOrderItemsInput struct {
+	Items []CancelOrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Note  string                 `json:"note" binding:"max=500"`
+}
+
+type CancelOrderItemInput struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+}
+
+// EditOrder adds, removes or changes the quantity of items of a pending
+// order, for its owner or admins
+func EditOrder(c *gin.Context) {
+	var input EditOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	ownerID := currentUser.ID
+	if currentUser.Role == models.UserRoleAdmin {
+		ownerID = 0
+	}
+
+	lines := make([]services.OrderLine, 0, len(input.Items))
+	for _, item := range input.Items {
+		lines = append(lines, services.OrderLine{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
+			Quantity:  *item.Quantity,
+		})
+	}
+
+	order, err := services.EditOrder(parseID(c.Param("id")), ownerID, lines, currentUser, input.Note)
+	if err != nil {
+		respondOrderChangeError(c, err)
+		return
+	}
+
+	respondWithChangedOrder(c, order.ID, "Order items updated successfully")
+}
+
+// CancelOrderItems cancels units of a processing order and refunds what the
+// order no longer costs
+func CancelOrderItems(c *gin.Context) {
+	var input CancelOrderItemsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	lines := make([]services.CancelLine, 0, len(input.Items))
+	for _, item := range input.Items {
+		lines = append(lines, services.CancelLine{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	order, err := services.CancelOrderItems(c.Request.Context(), parseID(c.Param("id")), lines, currentUser, input.Note)
+	if err != nil {
+		respondOrderChangeError(c, err)
+		return
+	}
+
+	respondWithChangedOrder(c, order.ID, "Order items cancelled successfully")
+}
+
+// GetOrderChanges lists the item changes of an order, oldest first
+func GetOrderChanges(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var changes []models.OrderChange
+	if err := initializers.DB.Where("order_id = ?", order.ID).Preload("Items").Order("id ASC").Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch order changes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Order changes retrieved successfully",
+		Data:    changes,
+	})
+}
+
+func respondWithChangedOrder(c *gin.Context, orderID uint, message string) {
+	var order models.Order
+	if err := initializers.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").
+		Preload("Refunds.Items").Preload("Changes.Items").Preload("Shipments.Items").
+		First(&order, orderID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch order",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: message,
+		Data:    toOrderResponse(order),
+	})
+}
+
+// respondOrderChangeError maps errors from services.EditOrder and
+// services.CancelOrderItems to responses
+func respondOrderChangeError(c *gin.Context, err error) {
+	var changeErr services.OrderChangeError
+	var refundErr services.RefundError
+	var declined payments.DeclinedError
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: "Order not found",
+		})
+	case errors.As(err, &changeErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid order change",
+			Data:    []libs.ValidationError{{Field: changeErr.Field, Message: changeErr.Error()}},
+		})
+	case errors.As(err, &refundErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid refund",
+			Data:    []libs.ValidationError{{Field: refundErr.Field, Message: refundErr.Error()}},
+		})
+	case errors.As(err, &declined):
+		c.JSON(http.StatusBadGateway, ProductResponse{
+			Status:  "error",
+			Message: "Refund declined: " + declined.Message,
+		})
+	default:
+		switch err.(type) {
+		case services.ProductNotFoundError, services.VariantError, services.InsufficientStockError, services.CouponError, services.ShippingError:
+			respondOrderCreationError(c, err)
+			return
+		}
+		log.Println("Failed to change order items", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to change order items",
+		})
+	}
+}
@@ -1,7 +1,8 @@
 package controllers
 
 import (
-	"fmt"
+	"errors"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -10,26 +11,70 @@ import (
 	"github.com/roronoazor/goShopAPI/initializers"
 	"github.com/roronoazor/goShopAPI/libs"
 	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
 	"gorm.io/gorm"
 )
 
 // CreateOrderInput represents the input for creating an order
 type CreateOrderInput struct {
-	Items []OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Items            []OrderItemInput `json:"items" binding:"required,min=1,dive"`
+	Currency         string           `json:"currency"` // defaults to the base currency
+	CouponCodes      []string         `json:"coupon_codes"`
+	TaxRegion        TaxRegionInput   `json:"tax_region"`         // the address's when empty; no tax is charged without a country
+	AddressID        uint             `json:"address_id"`         // from the address book, required with a shipping method
+	ShippingMethodID uint             `json:"shipping_method_id"` // nothing is charged for shipping without one
 }
 
 type OrderItemInput struct {
 	ProductID uint `json:"product_id" binding:"required"`
+	VariantID uint `json:"variant_id"` // required for products with variants
 	Quantity  int  `json:"quantity" binding:"required,gt=0"`
 }
 
 type OrderResponse struct {
-	ID          uint               `json:"id"`
-	CreatedAt   time.Time          `json:"created_at"`
-	UpdatedAt   time.Time          `json:"updated_at"`
-	Status      models.OrderStatus `json:"status"`
-	TotalAmount float64            `json:"total_amount"`
-	Items       []models.OrderItem `json:"items"`
+	ID                 uint                       `json:"id"`
+	CreatedAt          time.Time                  `json:"created_at"`
+	UpdatedAt          time.Time                  `json:"updated_at"`
+	Status             models.OrderStatus         `json:"status"`
+	PaymentStatus      models.OrderPaymentStatus  `json:"payment_status"`
+	Subtotal           money.Money                `json:"subtotal"`
+	DiscountTotal      money.Money                `json:"discount_total"`
+	TaxTotal           money.Money                `json:"tax_total"`
+	ShippingTotal      money.Money                `json:"shipping_total"`
+	TotalAmount        money.Money                `json:"total_amount"`
+	RefundedTotal      money.Money                `json:"refunded_total"`
+	TaxRegion          models.TaxRegion           `json:"tax_region"`
+	Currency           string                     `json:"currency"`
+	ExchangeRate       string                     `json:"exchange_rate"`
+	FreeShipping       bool                       `json:"free_shipping"`
+	ShippingAddress    models.PostalAddress       `json:"shipping_address"`
+	ShippingMethodID   *uint                      `json:"shipping_method_id,omitempty"`
+	ShippingMethodName string                     `json:"shipping_method_name,omitempty"`
+	Items              []models.OrderItem         `json:"items"`
+	Discounts          []models.OrderDiscount     `json:"discounts,omitempty"`
+	TaxLines           []models.OrderTaxLine      `json:"tax_lines,omitempty"`
+	Refunds            []models.Refund            `json:"refunds,omitempty"`
+	Changes            []models.OrderChange       `json:"changes,omitempty"`
+	Shipments          []models.Shipment          `json:"shipments,omitempty"`
+	History            []OrderStatusEventResponse `json:"history,omitempty"`
+}
+
+// OrderStatusEventResponse is a status change as customers see it; the actor
+// is only identified by ID and role, staff details stay with the admin API
+type OrderStatusEventResponse struct {
+	ID         uint               `json:"id"`
+	CreatedAt  time.Time          `json:"created_at"`
+	FromStatus models.OrderStatus `json:"from_status,omitempty"`
+	ToStatus   models.OrderStatus `json:"to_status"`
+	ActorID    uint               `json:"actor_id"`
+	Actor      OrderActorResponse `json:"actor"`
+	Note       string             `json:"note,omitempty"`
+}
+
+type OrderActorResponse struct {
+	ID   uint            `json:"id"`
+	Role models.UserRole `json:"role"`
 }
 
 func CreateOrder(c *gin.Context) {
@@ -47,131 +92,127 @@ func CreateOrder(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	// Validate stock availability for all items first
-	type InsufficientStock struct {
-		ProductID   uint   `json:"product_id"`
-		ProductName string `json:"product_name"`
-		Requested   int    `json:"requested"`
-		Available   int    `json:"available"`
+	lines, options := orderRequest(input)
+	order, err := services.CreateOrder(currentUser.ID, lines, options)
+	if err != nil {
+		respondOrderCreationError(c, err)
+		return
 	}
-	var insufficientStocks []InsufficientStock
 
-	for _, item := range input.Items {
-		var product models.Product
-		if err := initializers.DB.First(&product, item.ProductID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, ProductResponse{
-				Status:  "error",
-				Message: "Product not found",
-				Data:    fmt.Sprintf("Product ID: %d not found", item.ProductID),
-			})
-			return
-		}
+	// Load order items for response
+	initializers.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").First(&order, order.ID)
 
-		if product.Stock < item.Quantity {
-			insufficientStocks = append(insufficientStocks, InsufficientStock{
-				ProductID:   product.ID,
-				ProductName: product.Name,
-				Requested:   item.Quantity,
-				Available:   product.Stock,
-			})
-		}
-	}
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Order created successfully",
+		Data:    order,
+	})
+}
 
-	// If any products have insufficient stock, return error with details
-	if len(insufficientStocks) > 0 {
+// QuoteOrder previews the totals, discounts and taxes of an order without
+// placing it
+func QuoteOrder(c *gin.Context) {
+	var input CreateOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, ProductResponse{
 			Status:  "error",
-			Message: "Insufficient stock for some products",
-			Data:    insufficientStocks,
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
 		})
 		return
 	}
 
-	// Start database transaction
-	tx := initializers.DB.Begin()
-
-	// Create order
-	order := models.Order{
-		UserID: currentUser.ID,
-		Status: models.StatusPending,
-	}
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
 
-	if err := tx.Create(&order).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, ProductResponse{
-			Status:  "error",
-			Message: "Failed to create order",
-		})
+	lines, options := orderRequest(input)
+	order, err := services.QuoteOrder(currentUser.ID, lines, options)
+	if err != nil {
+		respondOrderCreationError(c, err)
 		return
 	}
 
-	var totalAmount float64 = 0
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Order quote calculated successfully",
+		Data:    toOrderResponse(order),
+	})
+}
 
-	// Process each order item
+func orderRequest(input CreateOrderInput) ([]services.OrderLine, services.OrderOptions) {
+	lines := make([]services.OrderLine, 0, len(input.Items))
 	for _, item := range input.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, ProductResponse{
-				Status:  "error",
-				Message: "Failed to process order items",
-			})
-			return
-		}
-
-		// Create order item
-		orderItem := models.OrderItem{
-			OrderID:   order.ID,
-			ProductID: product.ID,
+		lines = append(lines, services.OrderLine{
+			ProductID: item.ProductID,
+			VariantID: item.VariantID,
 			Quantity:  item.Quantity,
-			Price:     product.Price,
-		}
+		})
+	}
 
-		if err := tx.Create(&orderItem).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, ProductResponse{
-				Status:  "error",
-				Message: "Failed to create order item",
-			})
-			return
-		}
+	return lines, services.OrderOptions{
+		Currency:         input.Currency,
+		CouponCodes:      input.CouponCodes,
+		TaxRegion:        input.TaxRegion.toModel(),
+		AddressID:        input.AddressID,
+		ShippingMethodID: input.ShippingMethodID,
+	}
+}
 
-		// Update product stock
-		product.Stock -= item.Quantity
-		if err := tx.Save(&product).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, ProductResponse{
-				Status:  "error",
-				Message: "Failed to update product stock",
-			})
+// respondOrderCreationError maps errors from services.CreateOrder to responses
+func respondOrderCreationError(c *gin.Context, err error) {
+	switch e := err.(type) {
+	case services.ProductNotFoundError:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Product not found",
+			Data:    e.Error(),
+		})
+	case services.VariantError:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid product variant",
+			Data:    e.Error(),
+		})
+	case services.InsufficientStockError:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Insufficient stock for some products",
+			Data:    e.Items,
+		})
+	case services.CouponError:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid coupon",
+			Data:    []libs.ValidationError{{Field: "coupon_codes", Message: e.Error()}},
+		})
+	case services.ShippingError:
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid shipping",
+			Data:    []libs.ValidationError{{Field: e.Field, Message: e.Error()}},
+		})
+	case services.UnsupportedCurrencyError:
+		respondCurrencyError(c, e)
+	default:
+		if errors.Is(err, money.ErrInvalidCurrency) {
+			respondCurrencyError(c, err)
 			return
 		}
-
-		totalAmount += product.Price * float64(item.Quantity)
-	}
-
-	// Update order total
-	order.TotalAmount = totalAmount
-	if err := tx.Save(&order).Error; err != nil {
-		tx.Rollback()
+		log.Println("Failed to create order", err)
 		c.JSON(http.StatusInternalServerError, ProductResponse{
 			Status:  "error",
-			Message: "Failed to update order total",
+			Message: "Failed to create order",
 		})
-		return
 	}
+}
 
-	// Commit transaction
-	tx.Commit()
-
-	// Load order items for response
-	initializers.DB.Preload("Items.Product").First(&order, order.ID)
-
-	c.JSON(http.StatusCreated, ProductResponse{
-		Status:  "success",
-		Message: "Order created successfully",
-		Data:    order,
-	})
+// orderSortFields maps the accepted sort= fields to order columns
+var orderSortFields = map[string]string{
+	"id":           "orders.id",
+	"created_at":   "orders.created_at",
+	"updated_at":   "orders.updated_at",
+	"total_amount": "orders.total_amount_minor",
+	"status":       "orders.status",
 }
 
 func GetUserOrders(c *gin.Context) {
@@ -194,17 +235,46 @@ func GetUserOrders(c *gin.Context) {
 		pageSize = 100
 	}
 
-	var orders []models.Order
-	query := initializers.DB.Where("user_id = ?", currentUser.ID).
-		Preload("Items.Product").
-		Order("created_at DESC")
+	// Newest first by default, id breaks ties between equal sort values
+	sortFields, err := libs.ParseSort(c.DefaultQuery("sort", "-created_at"), orderSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid sort",
+			Data:    []libs.ValidationError{{Field: "sort", Message: err.Error()}},
+		})
+		return
+	}
+	if len(sortFields) == 0 {
+		sortFields = []libs.SortField{{Field: "created_at", Column: "orders.created_at", Desc: true}}
+	}
+	sortFields = libs.WithTiebreak(sortFields, libs.SortField{Field: "id", Column: "orders.id", Desc: true})
+
+	query := initializers.DB.Model(&models.Order{}).Where("user_id = ?", currentUser.ID).
+		Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines")
+
+	if usesCursor(c) {
+		orders, cursor, ok := fetchCursorPage(c, query, sortFields, pageSize, orderSortValues(sortFields))
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, ProductResponse{
+			Status:  "success",
+			Message: "Orders retrieved successfully",
+			Data:    toOrderResponses(orders),
+			Cursor:  cursor,
+		})
+		return
+	}
 
 	var total int64
-	query.Model(&models.Order{}).Count(&total)
+	query.Count(&total)
 
 	// Calculate offset and fetch paginated results
+	var orders []models.Order
 	offset := (page - 1) * pageSize
-	result := query.Offset(offset).Limit(pageSize).Find(&orders)
+	result := query.Order(libs.OrderClause(sortFields)).Offset(offset).Limit(pageSize).Find(&orders)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, ProductResponse{
 			Status:  "error",
@@ -213,17 +283,7 @@ func GetUserOrders(c *gin.Context) {
 		return
 	}
 
-	var orderResponses []OrderResponse
-	for _, order := range orders {
-		orderResponses = append(orderResponses, OrderResponse{
-			ID:          order.ID,
-			CreatedAt:   order.CreatedAt,
-			UpdatedAt:   order.UpdatedAt,
-			Status:      order.Status,
-			TotalAmount: order.TotalAmount,
-			Items:       order.Items,
-		})
-	}
+	orderResponses := toOrderResponses(orders)
 
 	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
 
@@ -240,55 +300,75 @@ func GetUserOrders(c *gin.Context) {
 	})
 }
 
-func CancelOrder(c *gin.Context) {
-	user, _ := c.Get("user")
-	currentUser := user.(models.User)
-
-	var order models.Order
-	if err := initializers.DB.Where("id = ? AND user_id = ?", c.Param("id"), currentUser.ID).
-		Preload("Items.Product").First(&order).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch order"})
-		}
-		return
+func toOrderResponse(order models.Order) OrderResponse {
+	return OrderResponse{
+		ID:                 order.ID,
+		CreatedAt:          order.CreatedAt,
+		UpdatedAt:          order.UpdatedAt,
+		Status:             order.Status,
+		PaymentStatus:      order.PaymentStatus,
+		Subtotal:           order.Subtotal,
+		DiscountTotal:      order.DiscountTotal,
+		TaxTotal:           order.TaxTotal,
+		ShippingTotal:      order.ShippingTotal,
+		TotalAmount:        order.TotalAmount,
+		RefundedTotal:      order.RefundedTotal,
+		TaxRegion:          order.TaxRegion,
+		Currency:           order.Currency,
+		ExchangeRate:       order.ExchangeRate,
+		FreeShipping:       order.FreeShipping,
+		ShippingAddress:    order.ShippingAddress,
+		ShippingMethodID:   order.ShippingMethodID,
+		ShippingMethodName: order.ShippingMethodName,
+		Items:              order.Items,
+		Discounts:          order.Discounts,
+		TaxLines:           order.TaxLines,
+		Refunds:            order.Refunds,
+		Changes:            order.Changes,
+		Shipments:          order.Shipments,
 	}
+}
 
-	if order.Status != models.StatusPending {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending orders can be cancelled"})
-		return
+func toOrderResponses(orders []models.Order) []OrderResponse {
+	var orderResponses []OrderResponse
+	for _, order := range orders {
+		orderResponses = append(orderResponses, toOrderResponse(order))
 	}
+	return orderResponses
+}
 
-	// Start transaction
-	tx := initializers.DB.Begin()
+func CancelOrder(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
 
-	// Update order status
-	order.Status = models.StatusCancelled
-	if err := tx.Save(&order).Error; err != nil {
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
-		return
+	// The reason is optional, so an empty body is fine
+	var input struct {
+		Reason string `json:"reason"`
 	}
-
-	// Restore product stock
-	for _, item := range order.Items {
-		var product models.Product
-		if err := tx.First(&product, item.ProductID).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore stock"})
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 			return
 		}
+	}
 
-		product.Stock += item.Quantity
-		if err := tx.Save(&product).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore stock"})
-			return
+	order, err := services.ChangeOrderStatus(parseID(c.Param("id")), currentUser.ID, models.StatusCancelled, currentUser, input.Reason)
+	if err != nil {
+		var transitionErr models.TransitionError
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Order not found"})
+		case errors.As(err, &transitionErr):
+			c.JSON(http.StatusBadRequest, gin.H{"error": transitionErr.Error()})
+		default:
+			log.Println("Failed to cancel order", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel order"})
 		}
+		return
 	}
 
-	tx.Commit()
+	// Load order items for response
+	initializers.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").First(&order, order.ID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"status":  "success",
@@ -300,6 +380,7 @@ func CancelOrder(c *gin.Context) {
 func UpdateOrderStatus(c *gin.Context) {
 	var input struct {
 		Status models.OrderStatus `json:"status" binding:"required"`
+		Note   string             `json:"note"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -324,52 +405,156 @@ func UpdateOrderStatus(c *gin.Context) {
 		return
 	}
 
-	var order models.Order
-	if err := initializers.DB.Preload("Items.Product").First(&order, c.Param("id")).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	// Validates the transition and applies its side effects (e.g. restocking)
+	order, err := services.ChangeOrderStatus(parseID(c.Param("id")), 0, input.Status, currentUser, input.Note)
+	if err != nil {
+		var transitionErr models.TransitionError
+		switch {
+		case err == gorm.ErrRecordNotFound:
 			c.JSON(http.StatusNotFound, ProductResponse{
 				Status:  "error",
 				Message: "Order not found",
 			})
-		} else {
+		case errors.As(err, &transitionErr):
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid status transition",
+				Data: []libs.ValidationError{{
+					Field:   "status",
+					Message: transitionErr.Error(),
+				}},
+			})
+		default:
+			log.Println("Failed to update order status", err)
 			c.JSON(http.StatusInternalServerError, ProductResponse{
 				Status:  "error",
-				Message: "Failed to fetch order",
+				Message: "Failed to update order status",
 			})
 		}
 		return
 	}
 
-	// Validate status transition
-	if err := order.Status.ValidateTransition(input.Status); err != nil {
-		c.JSON(http.StatusBadRequest, ProductResponse{
-			Status:  "error",
-			Message: "Invalid status transition",
-			Data: []libs.ValidationError{{
-				Field:   "status",
-				Message: err.Error(),
-			}},
-		})
+	// Load order items for response
+	initializers.DB.Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").First(&order, order.ID)
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Order status updated successfully",
+		Data:    order,
+	})
+}
+
+// GetOrderTransitions lists the statuses the current user may move the order to
+func GetOrderTransitions(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
 		return
 	}
 
-	// Update status
-	order.Status = input.Status
-	if err := initializers.DB.Save(&order).Error; err != nil {
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Order transitions retrieved successfully",
+		Data: gin.H{
+			"order_id": order.ID,
+			"status":   order.Status,
+			"allowed":  order.Status.AllowedTransitions(currentUser.Role),
+		},
+	})
+}
+
+// GetOrderHistory returns the status timeline of an order, oldest first
+func GetOrderHistory(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var history []models.OrderStatusEvent
+	if err := initializers.DB.Where("order_id = ?", order.ID).
+		Preload("Actor", selectActorRole).
+		Order("created_at ASC, id ASC").
+		Find(&history).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, ProductResponse{
 			Status:  "error",
-			Message: "Failed to update order status",
+			Message: "Failed to fetch order history",
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, ProductResponse{
 		Status:  "success",
-		Message: "Order status updated successfully",
-		Data:    order,
+		Message: "Order history retrieved successfully",
+		Data:    toOrderStatusEventResponses(history),
 	})
 }
 
+// selectActorRole loads only what OrderActorResponse shows of an actor
+func selectActorRole(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "role")
+}
+
+func toOrderStatusEventResponses(events []models.OrderStatusEvent) []OrderStatusEventResponse {
+	responses := make([]OrderStatusEventResponse, 0, len(events))
+	for _, event := range events {
+		responses = append(responses, OrderStatusEventResponse{
+			ID:         event.ID,
+			CreatedAt:  event.CreatedAt,
+			FromStatus: event.FromStatus,
+			ToStatus:   event.ToStatus,
+			ActorID:    event.ActorID,
+			Actor:      OrderActorResponse{ID: event.Actor.ID, Role: event.Actor.Role},
+			Note:       event.Note,
+		})
+	}
+	return responses
+}
+
+// findVisibleOrder loads the order from the :id path param. Admins can see any
+// order, customers only their own. It writes the error response itself.
+func findVisibleOrder(c *gin.Context, currentUser models.User) (models.Order, bool) {
+	query := initializers.DB.Where("id = ?", parseID(c.Param("id")))
+	if currentUser.Role != models.UserRoleAdmin {
+		query = query.Where("user_id = ?", currentUser.ID)
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Order not found",
+			})
+			return order, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch order",
+		})
+		return order, false
+	}
+
+	return order, true
+}
+
+// parseID converts a path parameter to an ID; anything invalid becomes 0,
+// which never matches a record
+func parseID(param string) uint {
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
 func GetOrder(c *gin.Context) {
 	// Get current user from context
 	user, _ := c.Get("user")
@@ -380,7 +565,11 @@ func GetOrder(c *gin.Context) {
 
 	var order models.Order
 	result := initializers.DB.Where("id = ? AND user_id = ?", orderID, currentUser.ID).
-		Preload("Items.Product").
+		Preload("Items.Product").Preload("Items.Variant").Preload("Discounts").Preload("TaxLines").Preload("Refunds.Items").Preload("Changes.Items").Preload("Shipments.Items").
+		Preload("History", func(db *gorm.DB) *gorm.DB {
+			return db.Order("created_at ASC, id ASC")
+		}).
+		Preload("History.Actor", selectActorRole).
 		First(&order)
 
 	if result.Error != nil {
@@ -399,14 +588,8 @@ func GetOrder(c *gin.Context) {
 	}
 
 	// Convert to response format
-	orderResponse := OrderResponse{
-		ID:          order.ID,
-		CreatedAt:   order.CreatedAt,
-		UpdatedAt:   order.UpdatedAt,
-		Status:      order.Status,
-		TotalAmount: order.TotalAmount,
-		Items:       order.Items,
-	}
+	orderResponse := toOrderResponse(order)
+	orderResponse.History = toOrderStatusEventResponses(order.History)
 
 	c.JSON(http.StatusOK, ProductResponse{
 		Status:  "success",
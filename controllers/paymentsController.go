@@ -0,0 +1,199 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/payments"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// maxWebhookSize caps the webhook body read before its signature is checked
+const maxWebhookSize = 1 << 20
+
+type PayOrderInput struct {
+	PaymentToken string `json:"payment_token" binding:"required"` // from the payment provider's client
+	Capture      *bool  `json:"capture"`                          // defaults to true, false only authorizes
+}
+
+// PayOrder pays one of the current user's orders
+func PayOrder(c *gin.Context) {
+	var input PayOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	capture := input.Capture == nil || *input.Capture
+	payment, err := services.PayOrder(c.Request.Context(), parseID(c.Param("id")), currentUser.ID, input.PaymentToken, capture)
+	if err != nil {
+		respondPaymentError(c, payment, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Order paid successfully",
+		Data:    payment,
+	})
+}
+
+// GetOrderPayments lists the payments of an order, for its owner or admins
+func GetOrderPayments(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var orderPayments []models.Payment
+	if err := initializers.DB.Where("order_id = ?", order.ID).Order("id ASC").Find(&orderPayments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch payments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Payments retrieved successfully",
+		Data:    orderPayments,
+	})
+}
+
+// CapturePayment takes the money of an order's authorized payment
+func CapturePayment(c *gin.Context) {
+	payment, err := services.CapturePayment(c.Request.Context(), parseID(c.Param("id")))
+	if err != nil {
+		respondPaymentError(c, payment, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Payment captured successfully",
+		Data:    payment,
+	})
+}
+
+// VoidPayment releases an order's authorized payment
+func VoidPayment(c *gin.Context) {
+	payment, err := services.VoidPayment(c.Request.Context(), parseID(c.Param("id")))
+	if err != nil {
+		respondPaymentError(c, payment, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Payment voided successfully",
+		Data:    payment,
+	})
+}
+
+// PaymentWebhook receives the payment provider's callbacks. Anything not
+// signed by the provider is refused; repeated deliveries are acknowledged
+// without being applied again.
+func PaymentWebhook(c *gin.Context) {
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookSize))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid webhook",
+		})
+		return
+	}
+
+	provider := initializers.Payments
+	event, err := provider.ParseWebhook(payload, c.Request.Header)
+	if err != nil {
+		if errors.Is(err, payments.ErrInvalidSignature) {
+			c.JSON(http.StatusUnauthorized, ProductResponse{
+				Status:  "error",
+				Message: "Invalid webhook signature",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid webhook",
+			Data:    err.Error(),
+		})
+		return
+	}
+
+	if err := services.HandlePaymentWebhook(provider.Name(), event); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Payment not found",
+			})
+			return
+		}
+		log.Println("Failed to handle payment webhook", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to handle webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Webhook processed successfully",
+	})
+}
+
+// respondPaymentError maps errors from the payment services to responses.
+// The payment is returned along when one was recorded, e.g. a declined one.
+func respondPaymentError(c *gin.Context, payment models.Payment, err error) {
+	var declined payments.DeclinedError
+	var paymentErr services.PaymentError
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: "Order not found",
+		})
+	case errors.As(err, &declined):
+		c.JSON(http.StatusPaymentRequired, ProductResponse{
+			Status:  "error",
+			Message: "Payment declined: " + declined.Message,
+			Data:    payment,
+		})
+	case errors.As(err, &paymentErr):
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Invalid payment",
+			Data:    []libs.ValidationError{{Field: "order", Message: paymentErr.Error()}},
+		})
+	default:
+		log.Println("Failed to process payment", err)
+		response := ProductResponse{
+			Status:  "error",
+			Message: "Failed to process payment",
+		}
+		// e.g. authorized but not captured
+		if payment.ID != 0 {
+			response.Data = payment
+		}
+		c.JSON(http.StatusInternalServerError, response)
+	}
+}
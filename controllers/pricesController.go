@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+)
+
+type SetExchangeRateInput struct {
+	Rate json.Number `json:"rate" binding:"required"` // units of the currency per unit of the base currency
+}
+
+type SetProductPriceInput struct {
+	Amount string `json:"amount" binding:"required"` // e.g. "17.50", in the currency of the path
+}
+
+// pricerFor prices the products in the currency asked for with currency=,
+// the base currency by default. It writes the error response itself.
+func pricerFor(c *gin.Context, products []models.Product) (services.Pricer, bool) {
+	currency := c.Query("currency")
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+
+	productIDs := make([]uint, 0, len(products))
+	for _, product := range products {
+		productIDs = append(productIDs, product.ID)
+	}
+
+	pricer, err := services.NewPricer(initializers.DB, currency, productIDs)
+	if err != nil {
+		respondCurrencyError(c, err)
+		return services.Pricer{}, false
+	}
+	return pricer, true
+}
+
+// respondCurrencyError answers an invalid or unsupported currency with 400
+// and anything else with 500
+func respondCurrencyError(c *gin.Context, err error) {
+	var unsupported services.UnsupportedCurrencyError
+	if errors.As(err, &unsupported) || errors.Is(err, money.ErrInvalidCurrency) {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid currency",
+			Data:    []libs.ValidationError{{Field: "currency", Message: err.Error()}},
+		})
+		return
+	}
+
+	log.Println("Failed to load prices", err)
+	c.JSON(http.StatusInternalServerError, ProductResponse{
+		Status:  "error",
+		Message: "Failed to load prices",
+	})
+}
+
+// pathCurrency reads a non-base currency from the :currency path param. It
+// writes the error response itself.
+func pathCurrency(c *gin.Context) (string, bool) {
+	currency, err := money.NormalizeCurrency(c.Param("currency"))
+	if err == nil && currency == money.BaseCurrency() {
+		err = errors.New("the base currency " + currency + " has no exchange rate or price list")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid currency",
+			Data:    []libs.ValidationError{{Field: "currency", Message: err.Error()}},
+		})
+		return "", false
+	}
+	return currency, true
+}
+
+// GetCurrencies lists the currencies prices can be asked for in
+func GetCurrencies(c *gin.Context) {
+	currencies, err := services.SupportedCurrencies(initializers.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch currencies",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Currencies retrieved successfully",
+		Data: gin.H{
+			"base":       money.BaseCurrency(),
+			"currencies": currencies,
+		},
+	})
+}
+
+func GetExchangeRates(c *gin.Context) {
+	var rates []models.ExchangeRate
+	if err := initializers.DB.Order("currency ASC").Find(&rates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch exchange rates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Exchange rates retrieved successfully",
+		Data:    rates,
+	})
+}
+
+// SetExchangeRate creates or replaces the rate of the :currency path param.
+// Existing orders keep the rate they were placed at.
+func SetExchangeRate(c *gin.Context) {
+	currency, ok := pathCurrency(c)
+	if !ok {
+		return
+	}
+
+	var input SetExchangeRateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	rate, err := money.ParseRate(input.Rate.String())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    []libs.ValidationError{{Field: "rate", Message: "rate must be a number greater than 0"}},
+		})
+		return
+	}
+
+	exchangeRate := models.ExchangeRate{Currency: currency}
+	if err := initializers.DB.Where("currency = ?", currency).FirstOrInit(&exchangeRate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save exchange rate",
+		})
+		return
+	}
+	exchangeRate.Rate = rate.FloatString(10)
+
+	if err := initializers.DB.Save(&exchangeRate).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save exchange rate",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Exchange rate saved successfully",
+		Data:    exchangeRate,
+	})
+}
+
+// DeleteExchangeRate stops selling in the currency. Its explicit product
+// prices are kept but unused until a rate is set again.
+func DeleteExchangeRate(c *gin.Context) {
+	currency, ok := pathCurrency(c)
+	if !ok {
+		return
+	}
+
+	result := initializers.DB.Where("currency = ?", currency).Delete(&models.ExchangeRate{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete exchange rate",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: "Exchange rate not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Exchange rate deleted successfully",
+	})
+}
+
+func GetProductPrices(c *gin.Context) {
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	var prices []models.ProductPrice
+	if err := initializers.DB.Where("product_id = ?", product.ID).Order("price_currency ASC").Find(&prices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product prices",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product prices retrieved successfully",
+		Data:    prices,
+	})
+}
+
+// SetProductPrice creates or replaces the product's explicit price in the
+// :currency path param, which must have an exchange rate
+func SetProductPrice(c *gin.Context) {
+	currency, ok := pathCurrency(c)
+	if !ok {
+		return
+	}
+
+	var input SetProductPriceInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	amount, err := money.Parse(input.Amount, currency)
+	if err == nil && !amount.IsPositive() {
+		err = errors.New("amount must be greater than 0")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    []libs.ValidationError{{Field: "amount", Message: err.Error()}},
+		})
+		return
+	}
+
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+	if _, err := services.NewPricer(initializers.DB, currency, nil); err != nil {
+		respondCurrencyError(c, err)
+		return
+	}
+
+	price := models.ProductPrice{ProductID: product.ID}
+	if err := initializers.DB.Where("product_id = ? AND price_currency = ?", product.ID, currency).
+		FirstOrInit(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save product price",
+		})
+		return
+	}
+	price.Price = amount
+
+	if err := initializers.DB.Save(&price).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save product price",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product price saved successfully",
+		Data:    price,
+	})
+}
+
+// DeleteProductPrice goes back to converting the base price for the currency
+func DeleteProductPrice(c *gin.Context) {
+	currency, ok := pathCurrency(c)
+	if !ok {
+		return
+	}
+
+	result := initializers.DB.
+		Where("product_id = ? AND price_currency = ?", parseID(c.Param("id")), currency).
+		Delete(&models.ProductPrice{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete product price",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: "Product price not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product price deleted successfully",
+	})
+}
@@ -0,0 +1,367 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead leaves room for the boundaries and form fields around the file
+const multipartOverhead = 1 << 20
+
+type ReorderProductImagesInput struct {
+	ImageIDs []uint `json:"image_ids" binding:"required,min=1"`
+}
+
+type UpdateProductImageInput struct {
+	Position  *int  `json:"position" binding:"omitempty,gte=0"`
+	IsPrimary *bool `json:"is_primary"`
+}
+
+// UploadProductImage accepts a multipart upload in the "image" field. The
+// optional "is_primary" form field makes the new image the primary one.
+func UploadProductImage(c *gin.Context) {
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	maxSize := services.MaxImageUploadSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondImageTooLarge(c, maxSize)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    []libs.ValidationError{{Field: "image", Message: "An image file is required"}},
+		})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		respondImageTooLarge(c, maxSize)
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded image",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded image",
+		})
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondImageTooLarge(c, maxSize)
+		return
+	}
+
+	makePrimary, _ := strconv.ParseBool(c.PostForm("is_primary"))
+
+	image, err := services.SaveProductImage(product.ID, data, makePrimary)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnsupportedImageType):
+			c.JSON(http.StatusUnsupportedMediaType, ProductResponse{
+				Status:  "error",
+				Message: err.Error(),
+			})
+		case errors.Is(err, services.ErrInvalidImage):
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: err.Error(),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to save product image",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Product image uploaded successfully",
+		Data:    image,
+	})
+}
+
+func GetProductImages(c *gin.Context) {
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	var images []models.ProductImage
+	if err := initializers.DB.Scopes(orderedImages).Where("product_id = ?", product.ID).
+		Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product images",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product images retrieved successfully",
+		Data:    images,
+	})
+}
+
+// ReorderProductImages sets the image order; image_ids must list every image
+// of the product exactly once
+func ReorderProductImages(c *gin.Context) {
+	var input ReorderProductImagesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    err.Error(),
+		})
+		return
+	}
+
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	var images []models.ProductImage
+	if err := initializers.DB.Where("product_id = ?", product.ID).Find(&images).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product images",
+		})
+		return
+	}
+
+	byID := make(map[uint]models.ProductImage, len(images))
+	for _, image := range images {
+		byID[image.ID] = image
+	}
+
+	ordered := make([]models.ProductImage, 0, len(input.ImageIDs))
+	for _, id := range input.ImageIDs {
+		image, found := byID[id]
+		if !found {
+			break
+		}
+		delete(byID, id)
+		ordered = append(ordered, image)
+	}
+	if len(ordered) != len(input.ImageIDs) || len(ordered) != len(images) {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "image_ids",
+				Message: "Must list every image of the product exactly once",
+			}},
+		})
+		return
+	}
+
+	tx := initializers.DB.Begin()
+	if err := saveImagePositions(tx, ordered); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to reorder product images",
+		})
+		return
+	}
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to reorder product images",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product images reordered successfully",
+		Data:    ordered,
+	})
+}
+
+// UpdateProductImage moves an image to a new position and/or makes it the
+// primary image. The primary flag can only be moved, not cleared.
+func UpdateProductImage(c *gin.Context) {
+	var input UpdateProductImageInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    err.Error(),
+		})
+		return
+	}
+
+	image, ok := findProductImage(c)
+	if !ok {
+		return
+	}
+
+	if input.IsPrimary != nil && !*input.IsPrimary && image.IsPrimary {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "A product always has a primary image, mark another image as primary instead",
+		})
+		return
+	}
+
+	tx := initializers.DB.Begin()
+
+	if input.Position != nil {
+		var images []models.ProductImage
+		if err := tx.Scopes(orderedImages).Where("product_id = ? AND id <> ?", image.ProductID, image.ID).
+			Find(&images).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product image",
+			})
+			return
+		}
+
+		position := min(*input.Position, len(images))
+		images = append(images[:position], append([]models.ProductImage{image}, images[position:]...)...)
+		if err := saveImagePositions(tx, images); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product image",
+			})
+			return
+		}
+		image.Position = position
+	}
+
+	if input.IsPrimary != nil && *input.IsPrimary && !image.IsPrimary {
+		if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", image.ProductID).
+			Update("is_primary", false).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product image",
+			})
+			return
+		}
+		if err := tx.Model(&image).Update("is_primary", true).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product image",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to update product image",
+		})
+		return
+	}
+
+	initializers.DB.First(&image, image.ID)
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product image updated successfully",
+		Data:    image,
+	})
+}
+
+func DeleteProductImage(c *gin.Context) {
+	image, ok := findProductImage(c)
+	if !ok {
+		return
+	}
+
+	if err := services.DeleteProductImage(image); err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete product image",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product image deleted successfully",
+	})
+}
+
+// orderedImages sorts images the way they are shown: by position, then upload order
+func orderedImages(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC, id ASC")
+}
+
+// saveImagePositions numbers the images in slice order
+func saveImagePositions(db *gorm.DB, images []models.ProductImage) error {
+	for i := range images {
+		images[i].Position = i
+		if err := db.Model(&models.ProductImage{}).Where("id = ?", images[i].ID).
+			Update("position", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findProductImage(c *gin.Context) (models.ProductImage, bool) {
+	var image models.ProductImage
+	err := initializers.DB.
+		Where("product_id = ?", parseID(c.Param("id"))).
+		First(&image, parseID(c.Param("image_id"))).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product image not found",
+			})
+			return image, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product image",
+		})
+		return image, false
+	}
+	return image, true
+}
+
+func respondImageTooLarge(c *gin.Context, maxSize int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, ProductResponse{
+		Status:  "error",
+		Message: "Image is too large, the limit is " + strconv.FormatInt(maxSize, 10) + " bytes",
+	})
+}
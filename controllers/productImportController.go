@@ -0,0 +1,305 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// ImportJobResponse adds the progress percentage to an import job
+type ImportJobResponse struct {
+	models.ProductImportJob
+	Progress float64 `json:"progress"`
+}
+
+// ProductExportRow is one product of an NDJSON export, in the same shape the
+// import reads
+type ProductExportRow struct {
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Stock       int         `json:"stock"`
+	IsActive    bool        `json:"is_active"`
+	CategoryIDs []uint      `json:"category_ids"`
+}
+
+func toImportJobResponse(job models.ProductImportJob) ImportJobResponse {
+	return ImportJobResponse{ProductImportJob: job, Progress: job.Progress()}
+}
+
+// ImportProducts accepts a CSV or NDJSON file in the multipart field "file"
+// and starts a background import. The format comes from the format field or
+// the file extension.
+func ImportProducts(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	maxSize := services.MaxImportSize()
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			respondImportTooLarge(c, maxSize)
+			return
+		}
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    []libs.ValidationError{{Field: "file", Message: "A csv or ndjson file is required"}},
+		})
+		return
+	}
+	if fileHeader.Size > maxSize {
+		respondImportTooLarge(c, maxSize)
+		return
+	}
+
+	format := strings.ToLower(c.PostForm("format"))
+	if format == "" {
+		switch strings.ToLower(filepath.Ext(fileHeader.Filename)) {
+		case ".csv":
+			format = "csv"
+		case ".ndjson", ".jsonl":
+			format = "ndjson"
+		}
+	}
+	if format != "csv" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "format",
+				Message: "format must be one of [csv, ndjson]",
+			}},
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded file",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to read uploaded file",
+		})
+		return
+	}
+	if int64(len(data)) > maxSize {
+		respondImportTooLarge(c, maxSize)
+		return
+	}
+
+	job, err := services.StartProductImport(currentUser.ID, fileHeader.Filename, format, data, validateProductImportRow)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to start product import",
+		})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("/admin/products/imports/%d", job.ID))
+	c.JSON(http.StatusAccepted, ProductResponse{
+		Status:  "success",
+		Message: "Product import started",
+		Data:    toImportJobResponse(job),
+	})
+}
+
+// GetProductImportJobs lists the latest imports without their row errors
+func GetProductImportJobs(c *gin.Context) {
+	var jobs []models.ProductImportJob
+	if err := initializers.DB.Omit("errors").Order("id DESC").Limit(50).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product imports",
+		})
+		return
+	}
+
+	responses := make([]ImportJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		job.Errors = nil
+		responses = append(responses, toImportJobResponse(job))
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product imports retrieved successfully",
+		Data:    responses,
+	})
+}
+
+func GetProductImportJob(c *gin.Context) {
+	var job models.ProductImportJob
+	if err := initializers.DB.First(&job, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product import not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product import",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product import retrieved successfully",
+		Data:    toImportJobResponse(job),
+	})
+}
+
+// ExportProducts streams the non-deleted products as CSV or NDJSON in the
+// format the import accepts. Supports the product listing filters.
+func ExportProducts(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "ndjson" {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid filters",
+			Data: []libs.ValidationError{{
+				Field:   "format",
+				Message: "format must be one of [csv, ndjson]",
+			}},
+		})
+		return
+	}
+
+	query := applyStockFilters(applyProductFilters(initializers.DB.Model(&models.Product{}).Where("products.deleted_at IS NULL"), c), c)
+
+	filename := fmt.Sprintf("products-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	var csvWriter *csv.Writer
+	var encoder *json.Encoder
+	if format == "csv" {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		csvWriter = csv.NewWriter(c.Writer)
+		csvWriter.Write(services.ProductImportColumns)
+	} else {
+		c.Header("Content-Type", "application/x-ndjson")
+		encoder = json.NewEncoder(c.Writer)
+	}
+	c.Status(http.StatusOK)
+
+	var batch []models.Product
+	result := query.Preload("Categories").
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, product := range batch {
+				row := ProductExportRow{
+					Name:        product.Name,
+					Description: product.Description,
+					Price:       product.Price,
+					Stock:       product.Stock,
+					IsActive:    product.IsActive,
+					CategoryIDs: make([]uint, 0, len(product.Categories)),
+				}
+				if product.SKU != nil {
+					row.SKU = *product.SKU
+				}
+				for _, category := range product.Categories {
+					row.CategoryIDs = append(row.CategoryIDs, category.ID)
+				}
+
+				if csvWriter != nil {
+					categoryIDs := make([]string, 0, len(row.CategoryIDs))
+					for _, id := range row.CategoryIDs {
+						categoryIDs = append(categoryIDs, strconv.FormatUint(uint64(id), 10))
+					}
+					csvWriter.Write([]string{
+						row.SKU,
+						row.Name,
+						row.Description,
+						row.Price.String(),
+						strconv.Itoa(row.Stock),
+						strconv.FormatBool(row.IsActive),
+						strings.Join(categoryIDs, "|"),
+					})
+				} else if err := encoder.Encode(row); err != nil {
+					return err
+				}
+			}
+
+			if csvWriter != nil {
+				csvWriter.Flush()
+				if err := csvWriter.Error(); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		})
+
+	// Headers are already sent, so a failure can only be logged
+	if result.Error != nil {
+		log.Println("Failed to export products", result.Error)
+	}
+}
+
+// ProductImportInput holds an imported row to the CreateProductInput rules,
+// except that stock may be 0: exports list sold out products with it
+type ProductImportInput struct {
+	SKU         string      `json:"sku" binding:"omitempty,max=64"`
+	Name        string      `json:"name" binding:"required"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price" binding:"required,gt=0"`
+	Stock       int         `json:"stock" binding:"gte=0"`
+	CategoryIDs []uint      `json:"category_ids"`
+}
+
+// validateProductImportRow applies the ProductImportInput rules to an
+// imported row
+func validateProductImportRow(row services.ProductImportRow) error {
+	input := ProductImportInput{
+		SKU:         row.SKU,
+		Name:        row.Name,
+		Description: row.Description,
+		Price:       row.Price,
+		Stock:       row.Stock,
+	}
+	if row.CategoryIDs != nil {
+		input.CategoryIDs = *row.CategoryIDs
+	}
+	return binding.Validator.ValidateStruct(&input)
+}
+
+func respondImportTooLarge(c *gin.Context, maxSize int64) {
+	c.JSON(http.StatusRequestEntityTooLarge, ProductResponse{
+		Status:  "error",
+		Message: "File is too large, the limit is " + strconv.FormatInt(maxSize, 10) + " bytes",
+	})
+}
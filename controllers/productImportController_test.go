@@ -0,0 +1,34 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
+	"github.com/roronoazor/goShopAPI/validators"
+)
+
+func TestValidateProductImportRow(t *testing.T) {
+	validators.RegisterMoney()
+
+	price := money.New(1999, "USD")
+	tests := []struct {
+		name  string
+		row   services.ProductImportRow
+		valid bool
+	}{
+		{"in stock", services.ProductImportRow{Name: "Mug", Price: price, Stock: 3}, true},
+		{"sold out", services.ProductImportRow{Name: "Mug", Price: price, Stock: 0}, true},
+		{"negative stock", services.ProductImportRow{Name: "Mug", Price: price, Stock: -1}, false},
+		{"no name", services.ProductImportRow{Price: price, Stock: 1}, false},
+		{"free", services.ProductImportRow{Name: "Mug", Price: money.New(0, "USD"), Stock: 1}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProductImportRow(tt.row)
+			if (err == nil) != tt.valid {
+				t.Errorf("error = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
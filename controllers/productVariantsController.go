@@ -0,0 +1,255 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+type CreateVariantInput struct {
+	SKU        string                   `json:"sku" binding:"required"`
+	Attributes models.VariantAttributes `json:"attributes" binding:"required,min=1"`
+	Price      *money.Money             `json:"price" binding:"omitempty,gt=0"`
+	Stock      int                      `json:"stock" binding:"gte=0"`
+}
+
+type UpdateVariantInput struct {
+	SKU        string                   `json:"sku"`
+	Attributes models.VariantAttributes `json:"attributes"`
+	Price      *money.Money             `json:"price" binding:"omitempty,gte=0"` // 0 clears the override
+	Stock      *int                     `json:"stock" binding:"omitempty,gte=0"`
+	IsActive   *bool                    `json:"is_active"`
+}
+
+func CreateVariant(c *gin.Context) {
+	var input CreateVariantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    err.Error(),
+		})
+		return
+	}
+	if input.Price != nil && !ensureBaseCurrency(c, "price", *input.Price) {
+		return
+	}
+
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	variant := models.ProductVariant{
+		ProductID:  product.ID,
+		SKU:        strings.TrimSpace(input.SKU),
+		Attributes: input.Attributes,
+		Stock:      input.Stock,
+		IsActive:   true,
+	}
+	if input.Price != nil {
+		variant.Price = *input.Price
+	}
+
+	if !ensureUniqueSKU(c, variant.SKU, 0) {
+		return
+	}
+
+	if err := initializers.DB.Create(&variant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to create product variant",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Product variant created successfully",
+		Data:    variant,
+	})
+}
+
+func GetVariants(c *gin.Context) {
+	product, ok := findVariantProduct(c)
+	if !ok {
+		return
+	}
+
+	var variants []models.ProductVariant
+	if err := initializers.DB.Where("product_id = ? AND deleted_at IS NULL", product.ID).
+		Order("id ASC").Find(&variants).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product variants",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product variants retrieved successfully",
+		Data:    variants,
+	})
+}
+
+func UpdateVariant(c *gin.Context) {
+	var input UpdateVariantInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    err.Error(),
+		})
+		return
+	}
+	if input.Price != nil && !ensureBaseCurrency(c, "price", *input.Price) {
+		return
+	}
+
+	variant, ok := findVariant(c)
+	if !ok {
+		return
+	}
+
+	// Only the fields provided are written: a full save would also write back
+	// the stock read above, undoing orders placed in the meantime
+	updates := map[string]interface{}{}
+	if sku := strings.TrimSpace(input.SKU); sku != "" && sku != variant.SKU {
+		if !ensureUniqueSKU(c, sku, variant.ID) {
+			return
+		}
+		updates["sku"] = sku
+	}
+	if len(input.Attributes) > 0 {
+		updates["attributes"] = input.Attributes
+	}
+	if input.Price != nil {
+		price := *input.Price
+		if price.IsZero() {
+			price = money.Money{}
+		}
+		updates["price_minor"] = price.Amount
+		updates["price_currency"] = price.Currency
+	}
+	if input.Stock != nil {
+		updates["stock"] = *input.Stock
+	}
+	if input.IsActive != nil {
+		updates["is_active"] = *input.IsActive
+	}
+
+	if len(updates) > 0 {
+		if err := initializers.DB.Model(&variant).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product variant",
+			})
+			return
+		}
+	}
+
+	if err := initializers.DB.First(&variant, variant.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product variant",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product variant updated successfully",
+		Data:    variant,
+	})
+}
+
+// DeleteVariant soft deletes the variant; order items keep referencing it
+func DeleteVariant(c *gin.Context) {
+	variant, ok := findVariant(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := initializers.DB.Model(&variant).Updates(map[string]interface{}{
+		"deleted_at": &now,
+		"is_active":  false,
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete product variant",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Product variant deleted successfully",
+	})
+}
+
+func findVariantProduct(c *gin.Context) (models.Product, bool) {
+	var product models.Product
+	if err := initializers.DB.First(&product, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product not found",
+			})
+			return product, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product",
+		})
+		return product, false
+	}
+	return product, true
+}
+
+func findVariant(c *gin.Context) (models.ProductVariant, bool) {
+	var variant models.ProductVariant
+	err := initializers.DB.
+		Where("product_id = ? AND deleted_at IS NULL", parseID(c.Param("id"))).
+		First(&variant, parseID(c.Param("variant_id"))).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Product variant not found",
+			})
+			return variant, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch product variant",
+		})
+		return variant, false
+	}
+	return variant, true
+}
+
+func ensureUniqueSKU(c *gin.Context, sku string, variantID uint) bool {
+	var count int64
+	initializers.DB.Model(&models.ProductVariant{}).Where("sku = ? AND id <> ?", sku, variantID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Product variant already exists",
+			Data: []libs.ValidationError{{
+				Field:   "sku",
+				Message: "This SKU is already used by another variant",
+			}},
+		})
+		return false
+	}
+	return true
+}
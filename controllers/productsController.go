@@ -1,36 +1,56 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roronoazor/goShopAPI/initializers"
 	"github.com/roronoazor/goShopAPI/libs"
 	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/services"
 	"gorm.io/gorm"
 )
 
 type CreateProductInput struct {
-	Name        string  `json:"name" binding:"required"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"required,gt=0"`
-	Stock       int     `json:"stock" binding:"required,gte=0"`
+	SKU         string      `json:"sku" binding:"omitempty,max=64"`
+	Name        string      `json:"name" binding:"required"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price" binding:"required,gt=0"`
+	Stock       int         `json:"stock" binding:"required,gte=0"`
+	TaxClass    string      `json:"tax_class" binding:"max=50"` // defaults to standard
+	Weight      int         `json:"weight" binding:"gte=0"`     // grams
+	Length      int         `json:"length" binding:"gte=0"`     // millimetres
+	Width       int         `json:"width" binding:"gte=0"`
+	Height      int         `json:"height" binding:"gte=0"`
+	CategoryIDs []uint      `json:"category_ids"`
 }
 
 type UpdateProductInput struct {
-	Name        string  `json:"name"`
-	Description string  `json:"description"`
-	Price       float64 `json:"price" binding:"omitempty,gt=0"`
-	Stock       int     `json:"stock" binding:"omitempty,gte=0"`
-	IsActive    *bool   `json:"is_active"`
+	SKU         string      `json:"sku" binding:"omitempty,max=64"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price" binding:"omitempty,gt=0"`
+	Stock       int         `json:"stock" binding:"omitempty,gte=0"`
+	IsActive    *bool       `json:"is_active"`
+	TaxClass    string      `json:"tax_class" binding:"max=50"`
+	Weight      *int        `json:"weight" binding:"omitempty,gte=0"`
+	Length      *int        `json:"length" binding:"omitempty,gte=0"`
+	Width       *int        `json:"width" binding:"omitempty,gte=0"`
+	Height      *int        `json:"height" binding:"omitempty,gte=0"`
+	CategoryIDs *[]uint     `json:"category_ids"` // replaces the assigned categories when present
 }
 
 type ProductResponse struct {
-	Status     string               `json:"status"`
-	Message    string               `json:"message"`
-	Data       interface{}          `json:"data"`
-	Pagination *libs.PaginationMeta `json:"pagination,omitempty"`
+	Status     string                  `json:"status"`
+	Message    string                  `json:"message"`
+	Data       interface{}             `json:"data"`
+	Pagination *libs.PaginationMeta    `json:"pagination,omitempty"`
+	Cursor     *libs.CursorMeta        `json:"cursor,omitempty"`
+	Facets     *services.ProductFacets `json:"facets,omitempty"`
 }
 
 func CreateProduct(c *gin.Context) {
@@ -43,13 +63,41 @@ func CreateProduct(c *gin.Context) {
 		})
 		return
 	}
+	if !ensureBaseCurrency(c, "price", input.Price) {
+		return
+	}
+
+	categories, ok := loadProductCategories(c, input.CategoryIDs)
+	if !ok {
+		return
+	}
+
+	var sku *string
+	if trimmed := strings.TrimSpace(input.SKU); trimmed != "" {
+		if !ensureUniqueProductSKU(c, trimmed, 0) {
+			return
+		}
+		sku = &trimmed
+	}
+
+	taxClass := strings.TrimSpace(input.TaxClass)
+	if taxClass == "" {
+		taxClass = models.DefaultTaxClass
+	}
 
 	product := models.Product{
+		SKU:         sku,
 		Name:        input.Name,
 		Description: input.Description,
 		Price:       input.Price,
 		Stock:       input.Stock,
+		TaxClass:    taxClass,
+		Weight:      input.Weight,
+		Length:      input.Length,
+		Width:       input.Width,
+		Height:      input.Height,
 		IsActive:    true,
+		Categories:  categories,
 	}
 
 	result := initializers.DB.Create(&product)
@@ -68,15 +116,17 @@ func CreateProduct(c *gin.Context) {
 	})
 }
 
-func GetProducts(c *gin.Context) {
-	// Parse query parameters
-	name := c.Query("name")
-	description := c.Query("description")
-	minPrice, _ := strconv.ParseFloat(c.Query("min_price"), 64)
-	maxPrice, _ := strconv.ParseFloat(c.Query("max_price"), 64)
-	minStock, _ := strconv.Atoi(c.Query("min_stock"))
-	isActive := c.Query("is_active")
+// productSortFields maps the accepted sort= fields to product columns
+var productSortFields = map[string]string{
+	"id":         "products.id",
+	"name":       "products.name",
+	"price":      "products.price_minor",
+	"stock":      "products.stock",
+	"created_at": "products.created_at",
+	"updated_at": "products.updated_at",
+}
 
+func GetProducts(c *gin.Context) {
 	// Pagination parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
@@ -87,30 +137,71 @@ func GetProducts(c *gin.Context) {
 		pageSize = 10
 	}
 
-	// Build query
-	query := initializers.DB.Model(&models.Product{})
-
-	// Apply filters
-	if name != "" {
-		query = query.Where("name ILIKE ?", "%"+name+"%")
+	// searches are ordered by relevance unless a sort is asked for
+	search, searching := services.NewProductSearch(c.Query("q"))
+	defaultSort := "id"
+	if searching {
+		defaultSort = ""
 	}
-	if description != "" {
-		query = query.Where("description ILIKE ?", "%"+description+"%")
-	}
-	if minPrice > 0 {
-		query = query.Where("price >= ?", minPrice)
+
+	sortFields, err := libs.ParseSort(c.DefaultQuery("sort", defaultSort), productSortFields)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid sort",
+			Data:    []libs.ValidationError{{Field: "sort", Message: err.Error()}},
+		})
+		return
 	}
-	if maxPrice > 0 {
-		query = query.Where("price <= ?", maxPrice)
+	if len(sortFields) == 0 && !searching {
+		sortFields = []libs.SortField{{Field: "id", Column: "products.id"}}
 	}
-	if minStock > 0 {
-		query = query.Where("stock >= ?", minStock)
+	if len(sortFields) > 0 {
+		sortFields = libs.WithTiebreak(sortFields, libs.SortField{Field: "id", Column: "products.id"})
 	}
+
+	// Build query
+	query := applyStockFilters(applyProductFilters(initializers.DB.Model(&models.Product{}), c), c)
+
+	isActive := c.Query("is_active")
 	if isActive != "" {
 		active := isActive == "true"
 		query = query.Where("is_active = ?", active)
 	}
 
+	var facets *services.ProductFacets
+	if c.Query("facets") == "true" {
+		if facets, err = services.ComputeProductFacets(query); err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to compute product facets",
+			})
+			return
+		}
+	}
+
+	if usesCursor(c) {
+		if len(sortFields) == 0 {
+			respondSearchWithCursor(c)
+			return
+		}
+
+		products, cursor, ok := fetchCursorPage(c, query.Preload("Categories").Preload("Images", orderedImages),
+			sortFields, min(pageSize, 100), productSortValues(sortFields))
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, ProductResponse{
+			Status:  "success",
+			Message: "Products retrieved successfully",
+			Data:    products,
+			Cursor:  cursor,
+			Facets:  facets,
+		})
+		return
+	}
+
 	// Count total items
 	var total int64
 	query.Count(&total)
@@ -119,9 +210,16 @@ func GetProducts(c *gin.Context) {
 	offset := (page - 1) * pageSize
 	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
 
+	// Best matches first when searching without an explicit sort
+	if len(sortFields) > 0 {
+		query = query.Order(libs.OrderClause(sortFields))
+	} else {
+		query = search.OrderByRank(query).Order("products.id ASC")
+	}
+
 	// Get paginated results
 	var products []models.Product
-	result := query.Offset(offset).Limit(pageSize).Find(&products)
+	result := query.Preload("Categories").Preload("Images", orderedImages).Offset(offset).Limit(pageSize).Find(&products)
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, ProductResponse{
 			Status:  "error",
@@ -130,6 +228,16 @@ func GetProducts(c *gin.Context) {
 		return
 	}
 
+	if searching {
+		if err := attachHighlights(search, products); err != nil {
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to fetch products",
+			})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, ProductResponse{
 		Status:  "success",
 		Message: "Products retrieved successfully",
@@ -140,9 +248,151 @@ func GetProducts(c *gin.Context) {
 			TotalItems:  total,
 			TotalPages:  totalPages,
 		},
+		Facets: facets,
 	})
 }
 
+// applyProductFilters applies the search, name, description, price and
+// category filters shared by the admin product listing and the public catalog.
+// Stock filters are admin only, see applyStockFilters.
+func applyProductFilters(query *gorm.DB, c *gin.Context) *gorm.DB {
+	if search, ok := services.NewProductSearch(c.Query("q")); ok {
+		query = search.Filter(query)
+	}
+
+	name := c.Query("name")
+	description := c.Query("description")
+	minPrice, _ := money.Parse(c.Query("min_price"), money.BaseCurrency())
+	maxPrice, _ := money.Parse(c.Query("max_price"), money.BaseCurrency())
+
+	if name != "" {
+		query = query.Where("name ILIKE ?", "%"+name+"%")
+	}
+	if description != "" {
+		query = query.Where("description ILIKE ?", "%"+description+"%")
+	}
+	if minPrice.IsPositive() {
+		query = query.Where("price_minor >= ?", minPrice.Amount)
+	}
+	if maxPrice.IsPositive() {
+		query = query.Where("price_minor <= ?", maxPrice.Amount)
+	}
+	// products in the category or any of its subcategories
+	if categoryID, err := strconv.ParseUint(c.Query("category_id"), 10, 64); err == nil {
+		query = query.Where(
+			"products.id IN (SELECT product_id FROM product_categories WHERE category_id IN ("+services.DescendantCategoriesSQL+"))",
+			categoryID,
+		)
+	}
+
+	return query
+}
+
+// applyStockFilters applies the min_stock filter of the admin listings. The
+// catalog doesn't offer it, as bisecting it would reveal exact stock levels.
+func applyStockFilters(query *gorm.DB, c *gin.Context) *gorm.DB {
+	if minStock, _ := strconv.Atoi(c.Query("min_stock")); minStock > 0 {
+		query = query.Where("stock >= ?", minStock)
+	}
+	return query
+}
+
+// ensureUniqueProductSKU checks the SKU against all products, deleted ones
+// included since they keep theirs
+func ensureUniqueProductSKU(c *gin.Context, sku string, productID uint) bool {
+	var count int64
+	initializers.DB.Model(&models.Product{}).Where("sku = ? AND id <> ?", sku, productID).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, ProductResponse{
+			Status:  "error",
+			Message: "Product already exists",
+			Data: []libs.ValidationError{{
+				Field:   "sku",
+				Message: "This SKU is already used by another product",
+			}},
+		})
+		return false
+	}
+	return true
+}
+
+// ensureBaseCurrency rejects a catalog price in another currency than the
+// store's base currency
+func ensureBaseCurrency(c *gin.Context, field string, price money.Money) bool {
+	if !price.IsSet() || price.Currency == money.BaseCurrency() {
+		return true
+	}
+	c.JSON(http.StatusBadRequest, ProductResponse{
+		Status:  "error",
+		Message: "Invalid input",
+		Data: []libs.ValidationError{{
+			Field:   field,
+			Message: "Prices must be in " + money.BaseCurrency(),
+		}},
+	})
+	return false
+}
+
+// respondSearchWithCursor rejects cursor mode for searches: relevance is not
+// a stable sort key
+func respondSearchWithCursor(c *gin.Context) {
+	c.JSON(http.StatusBadRequest, ProductResponse{
+		Status:  "error",
+		Message: "Invalid cursor",
+		Data: []libs.ValidationError{{
+			Field:   "cursor",
+			Message: "Results ordered by search relevance can only be paged with page",
+		}},
+	})
+}
+
+// attachHighlights sets the search snippets on each product
+func attachHighlights(search services.ProductSearch, products []models.Product) error {
+	ids := make([]uint, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ID)
+	}
+
+	highlights, err := search.Highlights(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range products {
+		if highlight, ok := highlights[products[i].ID]; ok {
+			products[i].Highlight = &highlight
+		}
+	}
+	return nil
+}
+
+// loadProductCategories resolves category IDs from product input. It writes
+// the error response itself.
+func loadProductCategories(c *gin.Context, ids []uint) ([]models.Category, bool) {
+	categories, missing, err := services.LoadCategories(ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch categories",
+		})
+		return nil, false
+	}
+
+	if len(missing) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data: []libs.ValidationError{{
+				Field:   "category_ids",
+				Message: fmt.Sprintf("Categories not found: %v", missing),
+			}},
+		})
+		return nil, false
+	}
+
+	return categories, true
+}
+
 func UpdateProduct(c *gin.Context) {
 	id := c.Param("id")
 
@@ -155,6 +405,9 @@ func UpdateProduct(c *gin.Context) {
 		})
 		return
 	}
+	if !ensureBaseCurrency(c, "price", input.Price) {
+		return
+	}
 
 	var product models.Product
 	if err := initializers.DB.First(&product, id).Error; err != nil {
@@ -173,13 +426,19 @@ func UpdateProduct(c *gin.Context) {
 	}
 
 	// Update fields if provided
+	if sku := strings.TrimSpace(input.SKU); sku != "" && (product.SKU == nil || sku != *product.SKU) {
+		if !ensureUniqueProductSKU(c, sku, product.ID) {
+			return
+		}
+		product.SKU = &sku
+	}
 	if input.Name != "" {
 		product.Name = input.Name
 	}
 	if input.Description != "" {
 		product.Description = input.Description
 	}
-	if input.Price > 0 {
+	if input.Price.IsPositive() {
 		product.Price = input.Price
 	}
 	if input.Stock >= 0 {
@@ -188,8 +447,34 @@ func UpdateProduct(c *gin.Context) {
 	if input.IsActive != nil {
 		product.IsActive = *input.IsActive
 	}
+	if taxClass := strings.TrimSpace(input.TaxClass); taxClass != "" {
+		product.TaxClass = taxClass
+	}
+	if input.Weight != nil {
+		product.Weight = *input.Weight
+	}
+	if input.Length != nil {
+		product.Length = *input.Length
+	}
+	if input.Width != nil {
+		product.Width = *input.Width
+	}
+	if input.Height != nil {
+		product.Height = *input.Height
+	}
 
-	if err := initializers.DB.Save(&product).Error; err != nil {
+	var categories []models.Category
+	if input.CategoryIDs != nil {
+		var ok bool
+		if categories, ok = loadProductCategories(c, *input.CategoryIDs); !ok {
+			return
+		}
+	}
+
+	tx := initializers.DB.Begin()
+
+	if err := tx.Save(&product).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, ProductResponse{
 			Status:  "error",
 			Message: "Failed to update product",
@@ -197,6 +482,21 @@ func UpdateProduct(c *gin.Context) {
 		return
 	}
 
+	if input.CategoryIDs != nil {
+		if err := tx.Model(&product).Association("Categories").Replace(categories); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to update product",
+			})
+			return
+		}
+	}
+
+	tx.Commit()
+
+	initializers.DB.Preload("Categories").First(&product, product.ID)
+
 	c.JSON(http.StatusOK, ProductResponse{
 		Status:  "success",
 		Message: "Product updated successfully",
@@ -243,7 +543,10 @@ func GetProduct(c *gin.Context) {
 	id := c.Param("id")
 
 	var product models.Product
-	if err := initializers.DB.First(&product, id).Error; err != nil {
+	if err := initializers.DB.Preload("Categories").
+		Preload("Variants", "deleted_at IS NULL").
+		Preload("Images", orderedImages).
+		First(&product, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, ProductResponse{
 				Status:  "error",
@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// RefundInput refunds units of order items, an amount, or both. An empty
+// body refunds everything not refunded yet.
+type RefundInput struct {
+	Items   []RefundItemInput `json:"items" binding:"dive"`
+	Amount  money.Money       `json:"amount" binding:"omitempty,gt=0"` // in the order's currency
+	Restock bool              `json:"restock"`                         // for full refunds
+	Reason  string            `json:"reason" binding:"max=500"`
+}
+
+type RefundItemInput struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+	Restock     bool `json:"restock"`
+}
+
+// RefundOrder refunds part or all of an order's payment
+func RefundOrder(c *gin.Context) {
+	var input RefundInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid input",
+				Data:    libs.NewValidationError(err),
+			})
+			return
+		}
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	options := services.RefundOptions{
+		Amount:  input.Amount,
+		Restock: input.Restock,
+		Reason:  input.Reason,
+	}
+	for _, item := range input.Items {
+		options.Lines = append(options.Lines, services.RefundLine{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+			Restock:     item.Restock,
+		})
+	}
+
+	refund, err := services.RefundOrder(c.Request.Context(), parseID(c.Param("id")), options, currentUser)
+	if err != nil {
+		var declined payments.DeclinedError
+		var refundErr services.RefundError
+		switch {
+		case err == gorm.ErrRecordNotFound:
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Order not found",
+			})
+		case errors.As(err, &refundErr):
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid refund",
+				Data:    []libs.ValidationError{{Field: refundErr.Field, Message: refundErr.Error()}},
+			})
+		case errors.As(err, &declined):
+			c.JSON(http.StatusBadGateway, ProductResponse{
+				Status:  "error",
+				Message: "Refund declined: " + declined.Message,
+				Data:    refund,
+			})
+		default:
+			log.Println("Failed to refund order", err)
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to refund order",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Order refunded successfully",
+		Data:    refund,
+	})
+}
+
+// GetOrderRefunds lists the refunds of an order, failed ones included
+func GetOrderRefunds(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var refunds []models.Refund
+	if err := initializers.DB.Where("order_id = ?", order.ID).Preload("Items").Order("id ASC").Find(&refunds).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch refunds",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Refunds retrieved successfully",
+		Data:    refunds,
+	})
+}
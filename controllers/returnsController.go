@@ -0,0 +1,354 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+type OpenReturnInput struct {
+	Items []ReturnItemInput `json:"items" binding:"required,min=1,dive"`
+	Note  string            `json:"note" binding:"max=1000"`
+}
+
+type ReturnItemInput struct {
+	OrderItemID uint                `json:"order_item_id" binding:"required"`
+	Quantity    int                 `json:"quantity" binding:"required,gt=0"`
+	Reason      models.ReturnReason `json:"reason" binding:"required,oneof=damaged defective wrong_item not_as_described no_longer_needed other"`
+	Comment     string              `json:"comment" binding:"max=500"`
+}
+
+// CompleteReturnInput is the inspection of a received return
+type CompleteReturnInput struct {
+	Items  []ReturnOutcomeInput `json:"items" binding:"required,min=1,dive"`
+	Refund *bool                `json:"refund"`                          // defaults to true
+	Amount money.Money          `json:"amount" binding:"omitempty,gt=0"` // refunded instead of what the items were charged
+}
+
+type ReturnOutcomeInput struct {
+	ReturnItemID uint                 `json:"return_item_id" binding:"required"`
+	Outcome      models.ReturnOutcome `json:"outcome" binding:"required,oneof=restock write_off"`
+}
+
+// OpenReturn requests the return of items of one of the current user's
+// delivered orders
+func OpenReturn(c *gin.Context) {
+	var input OpenReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	lines := make([]services.ReturnLine, 0, len(input.Items))
+	for _, item := range input.Items {
+		lines = append(lines, services.ReturnLine{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+			Reason:      item.Reason,
+			Comment:     item.Comment,
+		})
+	}
+
+	request, err := services.OpenReturn(parseID(c.Param("id")), currentUser.ID, lines, input.Note)
+	if err != nil {
+		respondReturnError(c, request, err, "Order not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Return requested successfully",
+		Data:    request,
+	})
+}
+
+// GetOrderReturns lists the returns of an order, for its owner or admins
+func GetOrderReturns(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var requests []models.ReturnRequest
+	if err := initializers.DB.Where("order_id = ?", order.ID).
+		Preload("Items").Preload("Refund.Items").
+		Order("id ASC").Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch returns",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Returns retrieved successfully",
+		Data:    requests,
+	})
+}
+
+// AdminGetReturns lists the returns of all orders, newest first, optionally
+// only those with the given statuses (comma separated)
+func AdminGetReturns(c *gin.Context) {
+	query := initializers.DB.Model(&models.ReturnRequest{})
+
+	if raw := c.Query("status"); raw != "" {
+		var statuses []models.ReturnStatus
+		for _, s := range strings.Split(raw, ",") {
+			status := models.ReturnStatus(strings.TrimSpace(s))
+			if !status.IsValid() {
+				c.JSON(http.StatusBadRequest, ProductResponse{
+					Status:  "error",
+					Message: "Invalid filters",
+					Data:    []libs.ValidationError{{Field: "status", Message: fmt.Sprintf("Invalid status %q", status)}},
+				})
+				return
+			}
+			statuses = append(statuses, status)
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(c.DefaultQuery("page_size", "10"))
+	if err != nil || pageSize < 1 {
+		pageSize = 10
+	}
+
+	// Cap maximum page size
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch returns",
+		})
+		return
+	}
+
+	var requests []models.ReturnRequest
+	offset := (page - 1) * pageSize
+	if err := query.Preload("Items").Order("id DESC").Offset(offset).Limit(pageSize).Find(&requests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch returns",
+		})
+		return
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Returns retrieved successfully",
+		Data:    requests,
+		Pagination: &libs.PaginationMeta{
+			CurrentPage: page,
+			PageSize:    pageSize,
+			TotalItems:  total,
+			TotalPages:  totalPages,
+		},
+	})
+}
+
+// AdminGetReturn returns a return with its order items and refund
+func AdminGetReturn(c *gin.Context) {
+	var request models.ReturnRequest
+	if err := initializers.DB.Preload("Items.OrderItem.Product").Preload("Items.OrderItem.Variant").Preload("Refund.Items").
+		First(&request, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Return not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch return",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Return retrieved successfully",
+		Data:    request,
+	})
+}
+
+func ApproveReturn(c *gin.Context) {
+	note, ok := bindReturnNote(c)
+	if !ok {
+		return
+	}
+
+	request, err := services.ApproveReturn(parseID(c.Param("id")), note)
+	if err != nil {
+		respondReturnError(c, request, err, "Return not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Return approved successfully",
+		Data:    request,
+	})
+}
+
+func RejectReturn(c *gin.Context) {
+	note, ok := bindReturnNote(c)
+	if !ok {
+		return
+	}
+
+	request, err := services.RejectReturn(parseID(c.Param("id")), note)
+	if err != nil {
+		respondReturnError(c, request, err, "Return not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Return rejected successfully",
+		Data:    request,
+	})
+}
+
+func ReceiveReturn(c *gin.Context) {
+	request, err := services.ReceiveReturn(parseID(c.Param("id")))
+	if err != nil {
+		respondReturnError(c, request, err, "Return not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Return received successfully",
+		Data:    request,
+	})
+}
+
+// CompleteReturn records the inspection of a received return and refunds it
+func CompleteReturn(c *gin.Context) {
+	var input CompleteReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	inspection := services.ReturnInspection{
+		Outcomes: make(map[uint]models.ReturnOutcome, len(input.Items)),
+		Refund:   input.Refund == nil || *input.Refund,
+		Amount:   input.Amount,
+	}
+	for _, item := range input.Items {
+		inspection.Outcomes[item.ReturnItemID] = item.Outcome
+	}
+
+	request, err := services.CompleteReturn(c.Request.Context(), parseID(c.Param("id")), inspection, currentUser)
+	if err != nil {
+		respondReturnError(c, request, err, "Return not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Return completed successfully",
+		Data:    request,
+	})
+}
+
+// bindReturnNote reads the optional note of an approval or rejection. It
+// writes the error response itself.
+func bindReturnNote(c *gin.Context) (string, bool) {
+	var input struct {
+		Note string `json:"note" binding:"max=1000"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid input",
+				Data:    libs.NewValidationError(err),
+			})
+			return "", false
+		}
+	}
+	return input.Note, true
+}
+
+// respondReturnError maps errors from the return services to responses. A
+// declined refund is returned with the return and its failed refund.
+func respondReturnError(c *gin.Context, request models.ReturnRequest, err error, notFound string) {
+	var declined payments.DeclinedError
+	var returnErr services.ReturnError
+	var refundErr services.RefundError
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: notFound,
+		})
+	case errors.As(err, &returnErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid return",
+			Data:    []libs.ValidationError{{Field: returnErr.Field, Message: returnErr.Error()}},
+		})
+	case errors.As(err, &refundErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid refund",
+			Data:    []libs.ValidationError{{Field: refundErr.Field, Message: refundErr.Error()}},
+		})
+	case errors.As(err, &declined):
+		c.JSON(http.StatusBadGateway, ProductResponse{
+			Status:  "error",
+			Message: "Refund declined: " + declined.Message,
+			Data:    request,
+		})
+	default:
+		log.Println("Failed to process return", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to process return",
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// ShipmentInput is the optional body of a shipment; without items all
+// unshipped units are shipped
+type ShipmentInput struct {
+	Carrier        string              `json:"carrier" binding:"max=100"` // defaults to the shipping method's
+	TrackingNumber string              `json:"tracking_number" binding:"max=100"`
+	Items          []ShipmentItemInput `json:"items" binding:"dive"`
+}
+
+type ShipmentItemInput struct {
+	OrderItemID uint `json:"order_item_id" binding:"required"`
+	Quantity    int  `json:"quantity" binding:"required,gt=0"`
+}
+
+func CreateShipment(c *gin.Context) {
+	var input ShipmentInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, ProductResponse{
+				Status:  "error",
+				Message: "Invalid input",
+				Data:    libs.NewValidationError(err),
+			})
+			return
+		}
+	}
+
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	options := services.ShipmentOptions{
+		Carrier:        input.Carrier,
+		TrackingNumber: input.TrackingNumber,
+	}
+	for _, item := range input.Items {
+		options.Lines = append(options.Lines, services.ShipmentLine{
+			OrderItemID: item.OrderItemID,
+			Quantity:    item.Quantity,
+		})
+	}
+
+	shipment, err := services.CreateShipment(parseID(c.Param("id")), options, currentUser)
+	if err != nil {
+		respondShipmentError(c, err, "Order not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Shipment created successfully",
+		Data:    shipment,
+	})
+}
+
+func DeliverShipment(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	shipment, err := services.DeliverShipment(parseID(c.Param("id")), currentUser)
+	if err != nil {
+		respondShipmentError(c, err, "Shipment not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipment delivered successfully",
+		Data:    shipment,
+	})
+}
+
+// GetOrderShipments lists the shipments of an order, for its owner or admins
+func GetOrderShipments(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	order, ok := findVisibleOrder(c, currentUser)
+	if !ok {
+		return
+	}
+
+	var shipments []models.Shipment
+	if err := initializers.DB.Where("order_id = ?", order.ID).Preload("Items").Order("id ASC").Find(&shipments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch shipments",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipments retrieved successfully",
+		Data:    shipments,
+	})
+}
+
+// respondShipmentError maps errors from the shipment services to responses
+func respondShipmentError(c *gin.Context, err error, notFound string) {
+	var shipmentErr services.ShipmentError
+	var transitionErr models.TransitionError
+	switch {
+	case err == gorm.ErrRecordNotFound:
+		c.JSON(http.StatusNotFound, ProductResponse{
+			Status:  "error",
+			Message: notFound,
+		})
+	case errors.As(err, &shipmentErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid shipment",
+			Data:    []libs.ValidationError{{Field: shipmentErr.Field, Message: shipmentErr.Error()}},
+		})
+	case errors.As(err, &transitionErr):
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid shipment",
+			Data:    []libs.ValidationError{{Field: "order", Message: transitionErr.Error()}},
+		})
+	default:
+		log.Println("Failed to update shipment", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to update shipment",
+		})
+	}
+}
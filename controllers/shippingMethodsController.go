@@ -0,0 +1,295 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// ShippingMethodInput creates a shipping method, or replaces all settings and
+// rates of one on update
+type ShippingMethodInput struct {
+	Name              string              `json:"name" binding:"required"`
+	Description       string              `json:"description"`
+	Carrier           string              `json:"carrier"`
+	VolumetricDivisor int                 `json:"volumetric_divisor" binding:"gte=0"` // e.g. 5000 cm³ per kg
+	IsActive          *bool               `json:"is_active"`                          // defaults to true
+	Rates             []ShippingRateInput `json:"rates" binding:"required,min=1,dive"`
+}
+
+type ShippingRateInput struct {
+	Country    string                  `json:"country" binding:"omitempty,len=2,alpha"` // everywhere when empty
+	State      string                  `json:"state" binding:"max=50"`
+	MinWeight  int                     `json:"min_weight" binding:"gte=0"` // grams
+	MaxWeight  int                     `json:"max_weight" binding:"gte=0"` // grams, 0 for no upper bound
+	Type       models.ShippingRateType `json:"type" binding:"required,oneof=flat weight"`
+	Price      money.Money             `json:"price" binding:"gte=0"`
+	PricePerKg money.Money             `json:"price_per_kg" binding:"omitempty,gt=0"` // weight rates
+	FreeOver   money.Money             `json:"free_over" binding:"omitempty,gte=0"`
+}
+
+func CreateShippingMethod(c *gin.Context) {
+	var input ShippingMethodInput
+	if !bindShippingMethodInput(c, &input) {
+		return
+	}
+
+	method := models.ShippingMethod{IsActive: true}
+	if !saveShippingMethod(c, &method, input) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Shipping method created successfully",
+		Data:    method,
+	})
+}
+
+func GetShippingMethods(c *gin.Context) {
+	var methods []models.ShippingMethod
+	if err := initializers.DB.Where("deleted_at IS NULL").Preload("Rates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Order("id ASC").Find(&methods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch shipping methods",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipping methods retrieved successfully",
+		Data:    methods,
+	})
+}
+
+// GetCatalogShippingMethods lists the active shipping methods with their
+// rates, to choose one at checkout
+func GetCatalogShippingMethods(c *gin.Context) {
+	var methods []models.ShippingMethod
+	if err := initializers.DB.Where("is_active = ? AND deleted_at IS NULL", true).Preload("Rates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Order("id ASC").Find(&methods).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch shipping methods",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipping methods retrieved successfully",
+		Data:    methods,
+	})
+}
+
+func GetShippingMethod(c *gin.Context) {
+	method, ok := findShippingMethod(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipping method retrieved successfully",
+		Data:    method,
+	})
+}
+
+// UpdateShippingMethod replaces the method's settings and rates. Placed orders
+// keep the shipping they were charged.
+func UpdateShippingMethod(c *gin.Context) {
+	var input ShippingMethodInput
+	if !bindShippingMethodInput(c, &input) {
+		return
+	}
+
+	method, ok := findShippingMethod(c)
+	if !ok {
+		return
+	}
+	if input.IsActive == nil {
+		input.IsActive = &method.IsActive
+	}
+	if !saveShippingMethod(c, &method, input) {
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipping method updated successfully",
+		Data:    method,
+	})
+}
+
+// DeleteShippingMethod soft deletes the method; orders keep their shipping
+func DeleteShippingMethod(c *gin.Context) {
+	method, ok := findShippingMethod(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if err := initializers.DB.Model(&method).Update("deleted_at", &now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete shipping method",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Shipping method deleted successfully",
+	})
+}
+
+// bindShippingMethodInput binds and checks the rates. It writes the error
+// response itself.
+func bindShippingMethodInput(c *gin.Context, input *ShippingMethodInput) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return false
+	}
+
+	var errors []libs.ValidationError
+	if strings.TrimSpace(input.Name) == "" {
+		errors = append(errors, libs.ValidationError{Field: "name", Message: "name is required"})
+	}
+	for i, rate := range input.Rates {
+		field := fmt.Sprintf("rates[%d]", i)
+		if rate.State != "" && rate.Country == "" {
+			errors = append(errors, libs.ValidationError{Field: field + ".state", Message: "a state needs a country"})
+		}
+		if rate.MaxWeight != 0 && rate.MaxWeight <= rate.MinWeight {
+			errors = append(errors, libs.ValidationError{Field: field + ".max_weight", Message: "max_weight must be above min_weight"})
+		}
+		if rate.Type == models.ShippingRateWeight && !rate.PricePerKg.IsSet() {
+			errors = append(errors, libs.ValidationError{Field: field + ".price_per_kg", Message: "price_per_kg is required for weight rates"})
+		}
+		amounts := []struct {
+			name   string
+			amount money.Money
+		}{{"price", rate.Price}, {"price_per_kg", rate.PricePerKg}, {"free_over", rate.FreeOver}}
+		for _, a := range amounts {
+			if a.amount.IsSet() && a.amount.Currency != money.BaseCurrency() {
+				errors = append(errors, libs.ValidationError{Field: field + "." + a.name, Message: "Amounts must be in " + money.BaseCurrency()})
+			}
+		}
+	}
+
+	if len(errors) > 0 {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    errors,
+		})
+		return false
+	}
+	return true
+}
+
+// saveShippingMethod applies the input to the method and saves it, replacing
+// its rates. It writes the error response itself.
+func saveShippingMethod(c *gin.Context, method *models.ShippingMethod, input ShippingMethodInput) bool {
+	method.Name = strings.TrimSpace(input.Name)
+	method.Description = input.Description
+	method.Carrier = strings.TrimSpace(input.Carrier)
+	method.VolumetricDivisor = input.VolumetricDivisor
+	if input.IsActive != nil {
+		method.IsActive = *input.IsActive
+	}
+
+	rates := make([]models.ShippingRate, 0, len(input.Rates))
+	for _, rate := range input.Rates {
+		region := TaxRegionInput{Country: rate.Country, State: rate.State}.toModel()
+		price := rate.Price
+		if !price.IsSet() {
+			price = money.New(0, money.BaseCurrency())
+		}
+		rates = append(rates, models.ShippingRate{
+			Country:    region.Country,
+			State:      region.State,
+			MinWeight:  rate.MinWeight,
+			MaxWeight:  rate.MaxWeight,
+			Type:       rate.Type,
+			Price:      price,
+			PricePerKg: rate.PricePerKg,
+			FreeOver:   rate.FreeOver,
+		})
+	}
+	method.Rates = nil
+
+	tx := initializers.DB.Begin()
+
+	// Save skips the false is_active on create because of its default
+	err := tx.Save(method).Error
+	if err == nil {
+		err = tx.Model(method).Update("is_active", method.IsActive).Error
+	}
+	if err == nil {
+		err = tx.Where("shipping_method_id = ?", method.ID).Delete(&models.ShippingRate{}).Error
+	}
+	for i := range rates {
+		if err != nil {
+			break
+		}
+		rates[i].ShippingMethodID = method.ID
+		err = tx.Create(&rates[i]).Error
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save shipping method",
+		})
+		return false
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to save shipping method",
+		})
+		return false
+	}
+
+	method.Rates = rates
+	return true
+}
+
+func findShippingMethod(c *gin.Context) (models.ShippingMethod, bool) {
+	var method models.ShippingMethod
+	if err := initializers.DB.Where("deleted_at IS NULL").Preload("Rates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).First(&method, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Shipping method not found",
+			})
+			return method, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch shipping method",
+		})
+		return method, false
+	}
+	return method, true
+}
@@ -0,0 +1,194 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+	"gorm.io/gorm"
+)
+
+// TaxRegionInput is where an order is taxed
+type TaxRegionInput struct {
+	Country    string `json:"country" binding:"omitempty,len=2,alpha"`
+	State      string `json:"state" binding:"max=50"`
+	PostalCode string `json:"postal_code" binding:"max=20"`
+}
+
+func (r TaxRegionInput) toModel() models.TaxRegion {
+	return services.NormalizeTaxRegion(models.TaxRegion{
+		Country:    r.Country,
+		State:      r.State,
+		PostalCode: r.PostalCode,
+	})
+}
+
+// TaxRuleInput creates a tax rule, or replaces all settings of one on update
+type TaxRuleInput struct {
+	Name         string      `json:"name" binding:"required"`
+	TaxClass     string      `json:"tax_class" binding:"max=50"` // defaults to standard
+	Country      string      `json:"country" binding:"required,len=2,alpha"`
+	State        string      `json:"state" binding:"max=50"`
+	PostalPrefix string      `json:"postal_prefix" binding:"max=20"`
+	Rate         json.Number `json:"rate" binding:"required"` // percent, e.g. 20 or "8.875"
+	Inclusive    bool        `json:"inclusive"`
+}
+
+func CreateTaxRule(c *gin.Context) {
+	var rule models.TaxRule
+	if !bindTaxRule(c, &rule) {
+		return
+	}
+
+	if err := initializers.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to create tax rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, ProductResponse{
+		Status:  "success",
+		Message: "Tax rule created successfully",
+		Data:    rule,
+	})
+}
+
+// GetTaxRules lists the rules, optionally only those of a country or tax class
+func GetTaxRules(c *gin.Context) {
+	query := initializers.DB.Model(&models.TaxRule{})
+	if country := c.Query("country"); country != "" {
+		query = query.Where("country = ?", strings.ToUpper(country))
+	}
+	if taxClass := c.Query("tax_class"); taxClass != "" {
+		query = query.Where("tax_class = ?", taxClass)
+	}
+
+	var rules []models.TaxRule
+	if err := query.Order("country ASC, state ASC, postal_prefix ASC, id ASC").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch tax rules",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Tax rules retrieved successfully",
+		Data:    rules,
+	})
+}
+
+// UpdateTaxRule replaces the rule's settings. Placed orders keep the tax they
+// were charged.
+func UpdateTaxRule(c *gin.Context) {
+	rule, ok := findTaxRule(c)
+	if !ok {
+		return
+	}
+	if !bindTaxRule(c, &rule) {
+		return
+	}
+
+	if err := initializers.DB.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to update tax rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Tax rule updated successfully",
+		Data:    rule,
+	})
+}
+
+func DeleteTaxRule(c *gin.Context) {
+	rule, ok := findTaxRule(c)
+	if !ok {
+		return
+	}
+
+	if err := initializers.DB.Delete(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to delete tax rule",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Tax rule deleted successfully",
+	})
+}
+
+// bindTaxRule binds a TaxRuleInput onto the rule. It writes the error
+// response itself.
+func bindTaxRule(c *gin.Context, rule *models.TaxRule) bool {
+	var input TaxRuleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return false
+	}
+
+	rate, err := services.ParseTaxRate(input.Rate.String())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    []libs.ValidationError{{Field: "rate", Message: "rate must be a percentage between 0 and 100"}},
+		})
+		return false
+	}
+
+	region := services.NormalizeTaxRegion(models.TaxRegion{
+		Country:    input.Country,
+		State:      input.State,
+		PostalCode: input.PostalPrefix,
+	})
+
+	rule.Name = strings.TrimSpace(input.Name)
+	rule.TaxClass = strings.TrimSpace(input.TaxClass)
+	if rule.TaxClass == "" {
+		rule.TaxClass = models.DefaultTaxClass
+	}
+	rule.Country = region.Country
+	rule.State = region.State
+	rule.PostalPrefix = region.PostalCode
+	rule.Rate = rate.FloatString(4)
+	rule.Inclusive = input.Inclusive
+	return true
+}
+
+func findTaxRule(c *gin.Context) (models.TaxRule, bool) {
+	var rule models.TaxRule
+	if err := initializers.DB.First(&rule, parseID(c.Param("id"))).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, ProductResponse{
+				Status:  "error",
+				Message: "Tax rule not found",
+			})
+			return rule, false
+		}
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to fetch tax rule",
+		})
+		return rule, false
+	}
+	return rule, true
+}
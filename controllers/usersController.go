@@ -206,3 +206,97 @@ func Login(c *gin.Context) {
 		Data:    tokenResponse,
 	})
 }
+
+func RefreshToken(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	tokenResponse, err := services.RefreshTokens(body.RefreshToken)
+	if err != nil {
+		switch err {
+		case services.ErrInvalidRefreshToken, services.ErrRefreshTokenExpired, services.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, ProductResponse{
+				Status:  "error",
+				Message: err.Error(),
+			})
+		default:
+			log.Println("Failed to refresh token", err)
+			c.JSON(http.StatusInternalServerError, ProductResponse{
+				Status:  "error",
+				Message: "Failed to refresh token",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Token refreshed successfully",
+		Data:    tokenResponse,
+	})
+}
+
+func Logout(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, ProductResponse{
+			Status:  "error",
+			Message: "Invalid input",
+			Data:    libs.NewValidationError(err),
+		})
+		return
+	}
+
+	if err := services.RevokeRefreshToken(body.RefreshToken); err != nil {
+		if err == services.ErrInvalidRefreshToken {
+			c.JSON(http.StatusUnauthorized, ProductResponse{
+				Status:  "error",
+				Message: err.Error(),
+			})
+			return
+		}
+		log.Println("Failed to revoke refresh token", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to logout",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Logged out successfully",
+	})
+}
+
+func LogoutAll(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	if err := services.RevokeAllTokens(currentUser); err != nil {
+		log.Println("Failed to revoke tokens", err)
+		c.JSON(http.StatusInternalServerError, ProductResponse{
+			Status:  "error",
+			Message: "Failed to logout",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, ProductResponse{
+		Status:  "success",
+		Message: "Logged out from all sessions successfully",
+	})
+}
@@ -0,0 +1,23 @@
+package initializers
+
+import (
+	"log"
+	"os"
+
+	"github.com/roronoazor/goShopAPI/payments"
+)
+
+var Payments payments.Provider
+
+// ConnectToPayments sets up the payment gateway selected by
+// PAYMENT_PROVIDER. Only "mock" (default), a local gateway that never moves
+// money, is built in; PAYMENT_WEBHOOK_SECRET signs its webhooks.
+func ConnectToPayments() {
+	switch provider := os.Getenv("PAYMENT_PROVIDER"); provider {
+	case "", "mock":
+		Payments = payments.NewMockProvider(os.Getenv("PAYMENT_WEBHOOK_SECRET"))
+	default:
+		log.Fatalf("Unknown PAYMENT_PROVIDER %q, must be mock", provider)
+	}
+	log.Println("Payment provider set up successfully")
+}
@@ -0,0 +1,47 @@
+package initializers
+
+import (
+	"log"
+	"os"
+
+	"github.com/roronoazor/goShopAPI/storage"
+)
+
+var Storage storage.Storage
+
+// ConnectToStorage sets up the file storage selected by STORAGE_DRIVER:
+// "local" (default) writes below STORAGE_LOCAL_DIR, "s3" uses an S3
+// compatible bucket configured through the S3_* variables
+func ConnectToStorage() {
+	var err error
+
+	switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+	case "", "local":
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		publicURL := os.Getenv("STORAGE_PUBLIC_URL")
+		if publicURL == "" {
+			publicURL = "/uploads"
+		}
+		Storage, err = storage.NewLocalStorage(dir, publicURL)
+	case "s3":
+		Storage, err = storage.NewS3Storage(
+			os.Getenv("S3_ENDPOINT"),
+			os.Getenv("S3_REGION"),
+			os.Getenv("S3_BUCKET"),
+			os.Getenv("S3_ACCESS_KEY_ID"),
+			os.Getenv("S3_SECRET_ACCESS_KEY"),
+			os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+			os.Getenv("STORAGE_PUBLIC_URL"),
+		)
+	default:
+		log.Fatalf("Unknown STORAGE_DRIVER %q, must be local or s3", driver)
+	}
+
+	if err != nil {
+		log.Fatal("Failed to set up storage", err)
+	}
+	log.Println("Storage set up successfully")
+}
@@ -1,23 +1,127 @@
 package initializers
 
 import (
+	"fmt"
 	"log"
 
 	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
 )
 
 func SyncDb() {
+	// orders placed before payments existed were paid outside the API
+	ordersBeforePayments := DB.Migrator().HasTable(&models.Order{}) && !DB.Migrator().HasColumn(&models.Order{}, "payment_status")
+
 	// Add all your models here
 	err := DB.AutoMigrate(
 		&models.User{},
 		&models.Product{},
+		&models.Category{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
 		&models.Order{},
 		&models.OrderItem{},
+		&models.OrderStatusEvent{},
+		&models.RefreshToken{},
+		&models.Cart{},
+		&models.CartItem{},
+		&models.IdempotencyKey{},
+		&models.ProductImportJob{},
+		&models.ExchangeRate{},
+		&models.ProductPrice{},
+		&models.Coupon{},
+		&models.CouponRedemption{},
+		&models.OrderDiscount{},
+		&models.TaxRule{},
+		&models.OrderTaxLine{},
+		&models.Payment{},
+		&models.PaymentWebhookEvent{},
+		&models.Refund{},
+		&models.RefundItem{},
+		&models.ReturnRequest{},
+		&models.ReturnItem{},
+		&models.OrderChange{},
+		&models.OrderChangeItem{},
+		&models.Address{},
+		&models.ShippingMethod{},
+		&models.ShippingRate{},
+		&models.Shipment{},
+		&models.ShipmentItem{},
 	)
 
 	if err != nil {
 		log.Fatal("Failed to sync database:", err)
 	}
 
+	// Schema changes AutoMigrate can't express, all safe to run repeatedly
+	statements := []string{
+		// a cart line is unique per product and variant (no variant counts as 0)
+		"DROP INDEX IF EXISTS idx_cart_product",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_cart_items_line ON cart_items (cart_id, product_id, COALESCE(variant_id, 0))",
+
+		// product search: a generated tsvector kept in sync by postgres, plus
+		// trigram matching on the name for typos
+		"CREATE EXTENSION IF NOT EXISTS pg_trgm",
+		"ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (" + models.ProductSearchVectorSQL + ") STORED",
+		"CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)",
+		"CREATE INDEX IF NOT EXISTS idx_products_name_trgm ON products USING GIN (name gin_trgm_ops)",
+	}
+
+	// amounts used to be float columns in the base currency; move them to
+	// minor units and drop the old column
+	statements = append(statements,
+		moneyColumnMigration("products", "price", "price_"),
+		moneyColumnMigration("product_variants", "price", "price_"),
+		moneyColumnMigration("cart_items", "price", "price_"),
+		moneyColumnMigration("order_items", "price", "price_"),
+		moneyColumnMigration("orders", "total_amount", "total_amount_"),
+
+		// one explicit price per product and currency; orders from before
+		// multi-currency are in the currency of their total
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_product_prices_currency ON product_prices (product_id, price_currency)",
+		"UPDATE orders SET currency = total_amount_currency WHERE currency = ''",
+
+		// orders from before coupons had no discounts
+		"UPDATE orders SET subtotal_minor = total_amount_minor, subtotal_currency = total_amount_currency, discount_total_currency = total_amount_currency WHERE subtotal_currency = ''",
+		"UPDATE order_items SET discount_currency = price_currency WHERE discount_currency = ''",
+
+		// orders from before taxes were untaxed
+		"UPDATE orders SET tax_total_currency = total_amount_currency WHERE tax_total_currency = ''",
+		"UPDATE order_items SET tax_currency = price_currency WHERE tax_currency = ''",
+
+		// what each line was charged, for refunds; lines of orders with
+		// inclusive taxes had theirs in the price already
+		`UPDATE order_items SET total_currency = price_currency, total_minor = price_minor * quantity - discount_minor +
+			CASE WHEN EXISTS (SELECT 1 FROM order_tax_lines WHERE order_tax_lines.order_id = order_items.order_id AND order_tax_lines.inclusive) THEN 0 ELSE tax_minor END
+			WHERE total_currency = ''`,
+		"UPDATE orders SET refunded_total_currency = total_amount_currency WHERE refunded_total_currency = ''",
+
+		// orders from before shipping methods shipped for free
+		"UPDATE orders SET shipping_total_currency = currency WHERE shipping_total_currency = ''",
+	)
+	if ordersBeforePayments {
+		statements = append(statements, "UPDATE orders SET payment_status = 'paid' WHERE status IN ('processing', 'shipped', 'delivered')")
+	}
+
+	for _, statement := range statements {
+		if err := DB.Exec(statement).Error; err != nil {
+			log.Fatal("Failed to sync database:", err)
+		}
+	}
+
 	log.Println("Database synced successfully")
 }
+
+// moneyColumnMigration copies a legacy float column into the embedded money
+// columns with the given prefix and drops it. A null amount (a variant
+// without its own price) stays unset.
+func moneyColumnMigration(table, column, prefix string) string {
+	currency := money.BaseCurrency()
+	return fmt.Sprintf(`DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = '%[1]s' AND column_name = '%[2]s') THEN
+		UPDATE %[1]s SET %[3]sminor = ROUND(%[2]s * %[4]d), %[3]scurrency = '%[5]s' WHERE %[2]s IS NOT NULL;
+		ALTER TABLE %[1]s DROP COLUMN %[2]s;
+	END IF;
+END $$`, table, column, prefix, money.MinorFactor(currency), currency)
+}
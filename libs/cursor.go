@@ -0,0 +1,128 @@
+package libs
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+var ErrInvalidCursor = errors.New("invalid or expired cursor")
+
+// Cursor points at a row of a keyset paginated listing. Clients only ever see
+// it as an opaque string.
+type Cursor struct {
+	Sort     string        `json:"s"`           // the ordering the cursor belongs to
+	Values   []interface{} `json:"v"`           // sort column values of the row, in sort order
+	Backward bool          `json:"b,omitempty"` // page towards the start of the listing
+}
+
+type CursorMeta struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
+// SortKey identifies an ordering so cursors from another sort are rejected
+func SortKey(fields []SortField) string {
+	return OrderClause(fields)
+}
+
+func EncodeCursor(cursor Cursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor for the given ordering. The fields must end in
+// a unique column so the position is unambiguous.
+func DecodeCursor(raw string, fields []SortField) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if cursor.Sort != SortKey(fields) || len(cursor.Values) != len(fields) {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// KeysetCondition renders the WHERE condition selecting the rows after the
+// cursor, or before it for a backward cursor. For a sort a ASC, b DESC that is
+// (a > ?) OR (a = ? AND b < ?).
+func KeysetCondition(fields []SortField, cursor Cursor) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	for i, field := range fields {
+		var parts []string
+		for j := 0; j < i; j++ {
+			parts = append(parts, fields[j].Column+" = ?")
+			args = append(args, cursor.Values[j])
+		}
+
+		op := ">"
+		if field.Desc != cursor.Backward {
+			op = "<"
+		}
+		parts = append(parts, field.Column+" "+op+" ?")
+		args = append(args, cursor.Values[i])
+
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")", args
+}
+
+// KeysetOrder is the ORDER BY for fetching a page: the sort itself, or the
+// reversed sort when paging backwards
+func KeysetOrder(fields []SortField, cursor *Cursor) string {
+	if cursor == nil || !cursor.Backward {
+		return OrderClause(fields)
+	}
+
+	reversed := make([]SortField, len(fields))
+	for i, field := range fields {
+		field.Desc = !field.Desc
+		reversed[i] = field
+	}
+	return OrderClause(reversed)
+}
+
+// CursorPage finishes a keyset page. rows must be fetched with KeysetOrder
+// and a limit of pageSize+1, the extra row only tells whether more rows
+// follow. values returns the sort column values of a row.
+func CursorPage[T any](rows []T, fields []SortField, cursor *Cursor, pageSize int, values func(T) []interface{}) ([]T, CursorMeta) {
+	meta := CursorMeta{PageSize: pageSize}
+
+	hasMore := len(rows) > pageSize
+	if hasMore {
+		rows = rows[:pageSize]
+	}
+
+	backward := cursor != nil && cursor.Backward
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	if len(rows) == 0 {
+		return rows, meta
+	}
+
+	sortKey := SortKey(fields)
+	// going forward there is a next page when the extra row showed up, and a
+	// previous one whenever we started from a cursor; backwards it's the reverse
+	if (!backward && hasMore) || backward {
+		meta.NextCursor = EncodeCursor(Cursor{Sort: sortKey, Values: values(rows[len(rows)-1])})
+	}
+	if (backward && hasMore) || (!backward && cursor != nil) {
+		meta.PrevCursor = EncodeCursor(Cursor{Sort: sortKey, Values: values(rows[0]), Backward: true})
+	}
+
+	return rows, meta
+}
@@ -0,0 +1,85 @@
+package libs
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SortField is one parsed entry of a sort= query parameter
+type SortField struct {
+	Field  string // name used in the query string
+	Column string // column it maps to
+	Desc   bool
+}
+
+// SortError reports a sort field that is not in the whitelist
+type SortError struct {
+	Field   string
+	Allowed []string
+}
+
+func (e SortError) Error() string {
+	return fmt.Sprintf("unknown sort field %q, allowed fields are: %s", e.Field, strings.Join(e.Allowed, ", "))
+}
+
+// ParseSort parses a comma separated list like "-price,name" against a
+// whitelist mapping query field names to columns. A leading "-" sorts
+// descending. Repeated fields are ignored after their first occurrence.
+func ParseSort(raw string, allowed map[string]string) ([]SortField, error) {
+	var fields []SortField
+	seen := make(map[string]bool)
+
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(strings.TrimPrefix(part, "-"), "+")
+
+		column, ok := allowed[name]
+		if !ok {
+			names := make([]string, 0, len(allowed))
+			for field := range allowed {
+				names = append(names, field)
+			}
+			sort.Strings(names)
+			return nil, SortError{Field: name, Allowed: names}
+		}
+
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+
+		fields = append(fields, SortField{Field: name, Column: column, Desc: desc})
+	}
+
+	return fields, nil
+}
+
+// OrderClause renders the fields as an ORDER BY expression
+func OrderClause(fields []SortField) string {
+	parts := make([]string, 0, len(fields))
+	for _, f := range fields {
+		direction := "ASC"
+		if f.Desc {
+			direction = "DESC"
+		}
+		parts = append(parts, f.Column+" "+direction)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// WithTiebreak appends a unique column to the fields unless it is already
+// sorted on, so rows with equal sort values keep a stable order
+func WithTiebreak(fields []SortField, tiebreak SortField) []SortField {
+	for _, field := range fields {
+		if field.Field == tiebreak.Field {
+			return fields
+		}
+	}
+	return append(fields[:len(fields):len(fields)], tiebreak)
+}
@@ -1,28 +1,67 @@
 package main
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/roronoazor/goShopAPI/controllers"
 	"github.com/roronoazor/goShopAPI/initializers"
 	"github.com/roronoazor/goShopAPI/middlewares"
+	"github.com/roronoazor/goShopAPI/services"
+	"github.com/roronoazor/goShopAPI/storage"
+	"github.com/roronoazor/goShopAPI/validators"
 )
 
 func init() {
 	initializers.LoadEnvVariables()
 	initializers.ConnectToDb()
 	initializers.SyncDb()
+	initializers.ConnectToStorage()
+	initializers.ConnectToPayments()
+	validators.RegisterMoney()
+	services.FailInterruptedImports()
 }
 
 func main() {
 	r := gin.Default()
 
+	// serve uploaded files when they are stored on the local disk and exposed
+	// under a path of this API
+	if local, ok := initializers.Storage.(*storage.LocalStorage); ok && strings.HasPrefix(local.BaseURL, "/") {
+		r.Static(local.BaseURL, local.Dir)
+	}
+
 	// auth routes under /auth
 	auth := r.Group("/auth")
 	{
 		auth.POST("/signup", controllers.SignUp)
 		auth.POST("/login", controllers.Login)
+		auth.POST("/refresh", controllers.RefreshToken)
+		auth.POST("/logout", controllers.Logout)
+		auth.POST("/logout-all", middlewares.RequireAuth, controllers.LogoutAll)
+	}
+
+	// public read-only catalog, only active products
+	catalog := r.Group("/catalog")
+	{
+		catalog.GET("/products", controllers.GetCatalogProducts)
+		catalog.GET("/products/:id", controllers.GetCatalogProduct)
+		catalog.GET("/categories", controllers.GetCategoryTree)
+		catalog.GET("/currencies", controllers.GetCurrencies)
+		catalog.GET("/shipping-methods", controllers.GetCatalogShippingMethods)
+	}
+
+	// category management
+	categories := r.Group("/categories")
+	categories.Use(middlewares.RequireAuth)
+	categories.Use(middlewares.RequireAdmin())
+	{
+		categories.POST("/", controllers.CreateCategory)
+		categories.GET("/", controllers.GetCategories)
+		categories.GET("/:id", controllers.GetCategory)
+		categories.PUT("/:id", controllers.UpdateCategory)
+		categories.DELETE("/:id", controllers.DeleteCategory)
 	}
 
 	// products routes under /products
@@ -35,25 +74,120 @@ func main() {
 		products.GET("/:id", controllers.GetProduct)
 		products.PUT("/:id", controllers.UpdateProduct)
 		products.DELETE("/:id", controllers.DeleteProduct)
+		products.POST("/:id/variants", controllers.CreateVariant)
+		products.GET("/:id/variants", controllers.GetVariants)
+		products.PUT("/:id/variants/:variant_id", controllers.UpdateVariant)
+		products.DELETE("/:id/variants/:variant_id", controllers.DeleteVariant)
+		products.POST("/:id/images", controllers.UploadProductImage)
+		products.GET("/:id/images", controllers.GetProductImages)
+		products.PUT("/:id/images", controllers.ReorderProductImages)
+		products.PUT("/:id/images/:image_id", controllers.UpdateProductImage)
+		products.DELETE("/:id/images/:image_id", controllers.DeleteProductImage)
+		products.GET("/:id/prices", controllers.GetProductPrices)
+		products.PUT("/:id/prices/:currency", controllers.SetProductPrice)
+		products.DELETE("/:id/prices/:currency", controllers.DeleteProductPrice)
 	}
 
 	// Order routes
 	orders := r.Group("/orders")
 	orders.Use(middlewares.RequireAuth)
+	orders.Use(middlewares.Idempotency())
 	{
 		orders.POST("/", controllers.CreateOrder)
+		orders.POST("/quote", controllers.QuoteOrder)
 		orders.GET("/", controllers.GetUserOrders)
 		orders.GET("/:id", controllers.GetOrder) // Add this line
+		orders.PATCH("/:id/items", controllers.EditOrder)
+		orders.GET("/:id/changes", controllers.GetOrderChanges)
 		orders.POST("/:id/cancel", controllers.CancelOrder)
+		orders.POST("/:id/pay", controllers.PayOrder)
+		orders.GET("/:id/payments", controllers.GetOrderPayments)
+		orders.GET("/:id/refunds", controllers.GetOrderRefunds)
+		orders.POST("/:id/returns", controllers.OpenReturn)
+		orders.GET("/:id/returns", controllers.GetOrderReturns)
+		orders.GET("/:id/shipments", controllers.GetOrderShipments)
+		orders.GET("/:id/transitions", controllers.GetOrderTransitions)
+		orders.GET("/:id/history", controllers.GetOrderHistory)
 
 		// Admin only routes
 		admin := orders.Group("/")
 		admin.Use(middlewares.RequireAdmin())
 		{
 			admin.PUT("/:id/status", controllers.UpdateOrderStatus)
+			admin.POST("/:id/items/cancel", controllers.CancelOrderItems)
+			admin.POST("/:id/payment/capture", controllers.CapturePayment)
+			admin.POST("/:id/payment/void", controllers.VoidPayment)
 		}
 	}
 
+	// payment provider callbacks, authenticated by their signature
+	r.POST("/payments/webhook", controllers.PaymentWebhook)
+
+	// Admin routes across all users
+	admin := r.Group("/admin")
+	admin.Use(middlewares.RequireAuth)
+	admin.Use(middlewares.RequireAdmin())
+	admin.Use(middlewares.Idempotency())
+	{
+		admin.GET("/orders", controllers.AdminGetOrders)
+		admin.GET("/orders/export", controllers.AdminExportOrders)
+		admin.GET("/orders/:id", controllers.AdminGetOrder)
+		admin.POST("/orders/:id/refunds", controllers.RefundOrder)
+		admin.POST("/orders/:id/shipments", controllers.CreateShipment)
+		admin.POST("/shipments/:id/deliver", controllers.DeliverShipment)
+		admin.GET("/returns", controllers.AdminGetReturns)
+		admin.GET("/returns/:id", controllers.AdminGetReturn)
+		admin.POST("/returns/:id/approve", controllers.ApproveReturn)
+		admin.POST("/returns/:id/reject", controllers.RejectReturn)
+		admin.POST("/returns/:id/receive", controllers.ReceiveReturn)
+		admin.POST("/returns/:id/complete", controllers.CompleteReturn)
+		admin.POST("/products/import", controllers.ImportProducts)
+		admin.GET("/products/imports", controllers.GetProductImportJobs)
+		admin.GET("/products/imports/:id", controllers.GetProductImportJob)
+		admin.GET("/products/export", controllers.ExportProducts)
+		admin.GET("/exchange-rates", controllers.GetExchangeRates)
+		admin.PUT("/exchange-rates/:currency", controllers.SetExchangeRate)
+		admin.DELETE("/exchange-rates/:currency", controllers.DeleteExchangeRate)
+		admin.POST("/coupons", controllers.CreateCoupon)
+		admin.GET("/coupons", controllers.GetCoupons)
+		admin.GET("/coupons/:id", controllers.GetCoupon)
+		admin.PUT("/coupons/:id", controllers.UpdateCoupon)
+		admin.DELETE("/coupons/:id", controllers.DeleteCoupon)
+		admin.POST("/tax-rules", controllers.CreateTaxRule)
+		admin.GET("/tax-rules", controllers.GetTaxRules)
+		admin.PUT("/tax-rules/:id", controllers.UpdateTaxRule)
+		admin.DELETE("/tax-rules/:id", controllers.DeleteTaxRule)
+		admin.POST("/shipping-methods", controllers.CreateShippingMethod)
+		admin.GET("/shipping-methods", controllers.GetShippingMethods)
+		admin.GET("/shipping-methods/:id", controllers.GetShippingMethod)
+		admin.PUT("/shipping-methods/:id", controllers.UpdateShippingMethod)
+		admin.DELETE("/shipping-methods/:id", controllers.DeleteShippingMethod)
+	}
+
+	// Address book routes
+	addresses := r.Group("/addresses")
+	addresses.Use(middlewares.RequireAuth)
+	{
+		addresses.POST("/", controllers.CreateAddress)
+		addresses.GET("/", controllers.GetAddresses)
+		addresses.GET("/:id", controllers.GetAddress)
+		addresses.PUT("/:id", controllers.UpdateAddress)
+		addresses.DELETE("/:id", controllers.DeleteAddress)
+	}
+
+	// Cart routes
+	cart := r.Group("/cart")
+	cart.Use(middlewares.RequireAuth)
+	cart.Use(middlewares.Idempotency())
+	{
+		cart.GET("/", controllers.GetCart)
+		cart.DELETE("/", controllers.ClearCart)
+		cart.POST("/items", controllers.AddCartItem)
+		cart.PUT("/items/:id", controllers.UpdateCartItem)
+		cart.DELETE("/items/:id", controllers.RemoveCartItem)
+		cart.POST("/checkout", controllers.Checkout)
+	}
+
 	// Custom 404 handler
 	r.NoRoute(func(c *gin.Context) {
 		c.JSON(404, gin.H{
@@ -0,0 +1,142 @@
+package middlewares
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// responseRecorder keeps a copy of everything written to the client
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry: the first response per user and key is stored and replayed for
+// identical retries. Must run after RequireAuth.
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || c.Request.Method != http.MethodPost {
+			c.Next()
+			return
+		}
+
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"status":  "error",
+				"message": "Unauthorized",
+			})
+			c.Abort()
+			return
+		}
+		currentUser := user.(models.User)
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"status":  "error",
+				"message": "Failed to read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		fingerprint := services.RequestFingerprint(c.Request.Method, c.Request.URL.Path, body)
+
+		stored, err := services.ReserveIdempotencyKey(currentUser.ID, key, fingerprint)
+		switch err {
+		case nil:
+		case services.ErrIdempotencyKeyMismatch:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		case services.ErrIdempotencyKeyInProgress:
+			c.JSON(http.StatusConflict, gin.H{
+				"status":  "error",
+				"message": err.Error(),
+			})
+			c.Abort()
+			return
+		default:
+			log.Println("Failed to reserve idempotency key:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"status":  "error",
+				"message": "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+
+		// Replay the stored response of the first request
+		if stored != nil {
+			c.Header("Idempotent-Replayed", "true")
+			c.Data(stored.StatusCode, stored.ContentType, stored.ResponseBody)
+			c.Abort()
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+
+		// Server errors and panics are not remembered so the client can retry them
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			if err := services.ReleaseIdempotencyKey(currentUser.ID, key); err != nil {
+				log.Println("Failed to release idempotency key:", err)
+			}
+		}()
+
+		c.Next()
+
+		if recorder.Status() >= http.StatusInternalServerError {
+			return
+		}
+		completed = true
+
+		if err := services.CompleteIdempotencyKey(
+			currentUser.ID,
+			key,
+			recorder.Status(),
+			recorder.Header().Get("Content-Type"),
+			recorder.body.Bytes(),
+		); err != nil {
+			log.Println("Failed to store idempotent response:", err)
+		}
+	}
+}
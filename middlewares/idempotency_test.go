@@ -0,0 +1,83 @@
+package middlewares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/roronoazor/goShopAPI/models"
+)
+
+// serveIdempotency sends a request through the middleware to a handler that
+// answers 201, with the user set as RequireAuth would
+func serveIdempotency(method string, key string, user *models.User) (*httptest.ResponseRecorder, bool) {
+	gin.SetMode(gin.TestMode)
+
+	handled := false
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user != nil {
+			c.Set("user", *user)
+		}
+	})
+	r.Use(Idempotency())
+	r.Handle(method, "/orders", func(c *gin.Context) {
+		handled = true
+		c.String(http.StatusCreated, "created")
+	})
+
+	req := httptest.NewRequest(method, "/orders", strings.NewReader(`{}`))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w, handled
+}
+
+func TestIdempotencyPassesThrough(t *testing.T) {
+	user := &models.User{ID: 1}
+
+	// requests without a key, and other methods than POST, are left alone
+	for _, tt := range []struct {
+		method string
+		key    string
+	}{
+		{http.MethodPost, ""},
+		{http.MethodGet, "key"},
+		{http.MethodPut, "key"},
+	} {
+		w, handled := serveIdempotency(tt.method, tt.key, user)
+		if !handled || w.Code != http.StatusCreated {
+			t.Errorf("%s with key %q: status %d, handled %v", tt.method, tt.key, w.Code, handled)
+		}
+	}
+}
+
+func TestIdempotencyRejects(t *testing.T) {
+	w, handled := serveIdempotency(http.MethodPost, strings.Repeat("k", 256), &models.User{ID: 1})
+	if handled || w.Code != http.StatusBadRequest {
+		t.Errorf("long key: status %d, handled %v", w.Code, handled)
+	}
+
+	w, handled = serveIdempotency(http.MethodPost, "key", nil)
+	if handled || w.Code != http.StatusUnauthorized {
+		t.Errorf("without a user: status %d, handled %v", w.Code, handled)
+	}
+}
+
+func TestResponseRecorder(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	recorder.Write([]byte("hello, "))
+	recorder.WriteString("world")
+
+	if recorder.body.String() != "hello, world" || w.Body.String() != "hello, world" {
+		t.Errorf("recorded %q, sent %q", recorder.body.String(), w.Body.String())
+	}
+}
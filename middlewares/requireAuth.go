@@ -1,6 +1,7 @@
 package middlewares
 
 import (
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/roronoazor/goShopAPI/initializers"
 	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/services"
 )
 
 func RequireAuth(c *gin.Context) {
@@ -31,6 +33,9 @@ func RequireAuth(c *gin.Context) {
 	}
 
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
 		return []byte(os.Getenv("JWT_SECRET")), nil
 	})
 
@@ -66,6 +71,37 @@ func RequireAuth(c *gin.Context) {
 			return
 		}
 
+		// tokens issued before the last logout-all carry an older version
+		version, _ := claims["ver"].(float64)
+		if int(version) != user.TokenVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "Token revoked",
+			})
+			c.Abort()
+			return
+		}
+
+		// the session (refresh token family) may have been logged out
+		if familyID, ok := claims["fam"].(string); ok && familyID != "" {
+			revoked, err := services.IsTokenFamilyRevoked(familyID)
+			if err != nil {
+				log.Println("Error checking token revocation:", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to authenticate",
+				})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": "Token revoked",
+				})
+				c.Abort()
+				return
+			}
+			c.Set("token_family", familyID)
+		}
+
 		// attach user to request
 		c.Set("user", user)
 
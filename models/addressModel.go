@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// PostalAddress is where goods are shipped to
+type PostalAddress struct {
+	Name       string `json:"name" gorm:"column:name;not null;default:''"` // recipient
+	Line1      string `json:"line1" gorm:"column:line1;not null;default:''"`
+	Line2      string `json:"line2" gorm:"column:line2;not null;default:''"`
+	City       string `json:"city" gorm:"column:city;not null;default:''"`
+	State      string `json:"state" gorm:"column:state;type:varchar(50);not null;default:''"`
+	PostalCode string `json:"postal_code" gorm:"column:postal_code;type:varchar(20);not null;default:''"`
+	Country    string `json:"country" gorm:"column:country;type:varchar(2);not null;default:''"` // ISO 3166-1 alpha-2
+	Phone      string `json:"phone" gorm:"column:phone;type:varchar(30);not null;default:''"`
+}
+
+// IsSet reports whether an address was given at all
+func (a PostalAddress) IsSet() bool {
+	return a.Country != ""
+}
+
+// TaxRegion is the region goods shipped to the address are taxed in
+func (a PostalAddress) TaxRegion() TaxRegion {
+	return TaxRegion{Country: a.Country, State: a.State, PostalCode: a.PostalCode}
+}
+
+// Address is an entry of a user's address book. Orders keep a copy of the
+// address they ship to, so editing or deleting the entry doesn't change them.
+type Address struct {
+	ID        uint       `gorm:"primarykey;autoIncrement:true;sequence:addresses_id_seq" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Label     string     `json:"label"` // e.g. "Home"
+	PostalAddress
+	IsDefault bool `json:"is_default"` // at most one per user
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// Cart is the persistent shopping cart of a user, one per user
+type Cart struct {
+	ID        uint       `gorm:"primarykey;autoIncrement:true;sequence:carts_id_seq" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UserID    uint       `json:"user_id" gorm:"not null;uniqueIndex"`
+	Items     []CartItem `json:"items"`
+}
+
+type CartItem struct {
+	ID        uint            `gorm:"primarykey;autoIncrement:true;sequence:cart_items_id_seq" json:"id"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	CartID    uint            `json:"cart_id" gorm:"not null;index"`
+	ProductID uint            `json:"product_id" gorm:"not null"`
+	Product   Product         `json:"product"`
+	VariantID *uint           `json:"variant_id,omitempty"`
+	Variant   *ProductVariant `json:"variant,omitempty"`
+	Quantity  int             `json:"quantity" gorm:"not null"`
+	Price     money.Money     `json:"price" gorm:"embedded;embeddedPrefix:price_"` // price when the line was last added or updated
+}
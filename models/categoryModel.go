@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+)
+
+// Category groups products. Categories nest through ParentID and products can
+// belong to several categories.
+type Category struct {
+	ID          uint       `gorm:"primarykey;autoIncrement:true;sequence:categories_id_seq" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Name        string     `json:"name" gorm:"not null"`
+	Slug        string     `json:"slug" gorm:"type:varchar(255);uniqueIndex;not null"`
+	Description string     `json:"description"`
+	ParentID    *uint      `json:"parent_id" gorm:"index"`
+	Parent      *Category  `json:"parent,omitempty"`
+	Children    []Category `json:"children,omitempty" gorm:"foreignKey:ParentID"`
+	Products    []Product  `json:"-" gorm:"many2many:product_categories"`
+}
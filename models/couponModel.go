@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+type CouponType string
+
+const (
+	CouponPercentage   CouponType = "percentage"
+	CouponFixedAmount  CouponType = "fixed_amount"
+	CouponFreeShipping CouponType = "free_shipping"
+)
+
+// Coupon is an admin managed discount code. AmountOff and MinOrderValue are
+// in the base currency and converted for orders in other currencies.
+//
+// A coupon restricted to products or categories (including their
+// subcategories) only discounts the matching order items.
+type Coupon struct {
+	ID            uint        `gorm:"primarykey;autoIncrement:true;sequence:coupons_id_seq" json:"id"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+	DeletedAt     *time.Time  `json:"deleted_at,omitempty" gorm:"index"`
+	Code          string      `json:"code" gorm:"type:varchar(64);uniqueIndex;not null"` // stored upper-case
+	Description   string      `json:"description"`
+	Type          CouponType  `json:"type" gorm:"type:varchar(20);not null"`
+	Percentage    int         `json:"percentage"` // 1-100 for percentage coupons
+	AmountOff     money.Money `json:"amount_off" gorm:"embedded;embeddedPrefix:amount_off_"`
+	MinOrderValue money.Money `json:"min_order_value" gorm:"embedded;embeddedPrefix:min_order_value_"` // unset for no minimum
+	StartsAt      *time.Time  `json:"starts_at"`
+	EndsAt        *time.Time  `json:"ends_at"`
+	UsageLimit    *int        `json:"usage_limit"`    // total redemptions, nil for unlimited
+	PerUserLimit  *int        `json:"per_user_limit"` // redemptions per user, nil for unlimited
+	TimesUsed     int         `json:"times_used" gorm:"not null;default:0"`
+	Stackable     bool        `json:"stackable"` // can be combined with other stackable coupons
+	IsActive      bool        `json:"is_active" gorm:"default:true"`
+	Products      []Product   `json:"products,omitempty" gorm:"many2many:coupon_products"`
+	Categories    []Category  `json:"categories,omitempty" gorm:"many2many:coupon_categories"`
+}
+
+// IsRestricted reports whether the coupon only applies to some products
+func (c Coupon) IsRestricted() bool {
+	return len(c.Products) > 0 || len(c.Categories) > 0
+}
+
+// CouponRedemption counts a use of a coupon by a user towards its limits
+type CouponRedemption struct {
+	ID        uint      `gorm:"primarykey;autoIncrement:true;sequence:coupon_redemptions_id_seq" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	CouponID  uint      `json:"coupon_id" gorm:"not null;index:idx_coupon_redemptions_user"`
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_coupon_redemptions_user"`
+	OrderID   uint      `json:"order_id" gorm:"not null;index"`
+}
+
+// OrderDiscount is a coupon applied to an order and the amount it took off,
+// kept as it was at order time even if the coupon changes later
+type OrderDiscount struct {
+	ID        uint        `gorm:"primarykey;autoIncrement:true;sequence:order_discounts_id_seq" json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	OrderID   uint        `json:"order_id" gorm:"not null;index"`
+	CouponID  uint        `json:"coupon_id" gorm:"not null"`
+	Code      string      `json:"code" gorm:"type:varchar(64);not null"`
+	Type      CouponType  `json:"type" gorm:"type:varchar(20);not null"`
+	Amount    money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"math/big"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// ExchangeRate is how many units of Currency one unit of the base currency
+// buys. Prices in currencies without an explicit price are converted with it.
+type ExchangeRate struct {
+	ID        uint      `gorm:"primarykey;autoIncrement:true;sequence:exchange_rates_id_seq" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Currency  string    `json:"currency" gorm:"type:varchar(3);not null;uniqueIndex"`
+	Rate      string    `json:"rate" gorm:"type:numeric(20,10);not null"`
+}
+
+// Ratio is the rate as an exact fraction
+func (r ExchangeRate) Ratio() (*big.Rat, error) {
+	return money.ParseRate(r.Rate)
+}
+
+// ProductPrice is an explicit price of a product in another currency than the
+// base one. It wins over converting the base price.
+type ProductPrice struct {
+	ID        uint        `gorm:"primarykey;autoIncrement:true;sequence:product_prices_id_seq" json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	ProductID uint        `json:"product_id" gorm:"not null;index"`
+	Price     money.Money `json:"price" gorm:"embedded;embeddedPrefix:price_"` // unique per product and currency
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// IdempotencyKey remembers the first response to a request sent with an
+// Idempotency-Key header, so retries of the same request can be replayed
+type IdempotencyKey struct {
+	ID           uint       `gorm:"primarykey;autoIncrement:true;sequence:idempotency_keys_id_seq" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_key"`
+	Key          string     `json:"key" gorm:"type:varchar(255);not null;uniqueIndex:idx_idempotency_user_key"`
+	RequestHash  string     `json:"-" gorm:"type:char(64);not null"` // SHA-256 of method, path and body
+	StatusCode   int        `json:"status_code"`
+	ContentType  string     `json:"-"`
+	ResponseBody []byte     `json:"-"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"` // nil while the first request is still running
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null;index"`
+}
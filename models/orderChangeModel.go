@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+type OrderChangeType string
+
+const (
+	OrderChangeEdit        OrderChangeType = "edit"         // items of a pending order added, removed or changed
+	OrderChangeCancelItems OrderChangeType = "cancel_items" // units of a processing order cancelled
+)
+
+// OrderChange records a change to the items of a placed order and what it
+// did to the total. A cancellation on a paid order links the refund of the
+// difference.
+type OrderChange struct {
+	ID            uint              `gorm:"primarykey;autoIncrement:true;sequence:order_changes_id_seq" json:"id"`
+	CreatedAt     time.Time         `json:"created_at"`
+	OrderID       uint              `json:"order_id" gorm:"not null;index"`
+	Type          OrderChangeType   `json:"type" gorm:"type:varchar(20);not null"`
+	ActorID       uint              `json:"actor_id" gorm:"not null"`
+	Note          string            `json:"note,omitempty"`
+	PreviousTotal money.Money       `json:"previous_total" gorm:"embedded;embeddedPrefix:previous_total_"`
+	NewTotal      money.Money       `json:"new_total" gorm:"embedded;embeddedPrefix:new_total_"`
+	RefundID      *uint             `json:"refund_id,omitempty"`
+	Items         []OrderChangeItem `json:"items"`
+}
+
+// OrderChangeItem is the quantity change of one order item. Items removed
+// from a pending order are gone, the product and variant say what they were.
+type OrderChangeItem struct {
+	ID               uint  `gorm:"primarykey;autoIncrement:true;sequence:order_change_items_id_seq" json:"id"`
+	OrderChangeID    uint  `json:"order_change_id" gorm:"not null;index"`
+	OrderItemID      uint  `json:"order_item_id" gorm:"not null"`
+	ProductID        uint  `json:"product_id" gorm:"not null"`
+	VariantID        *uint `json:"variant_id,omitempty"`
+	PreviousQuantity int   `json:"previous_quantity" gorm:"not null"` // 0 for added items
+	NewQuantity      int   `json:"new_quantity" gorm:"not null"`      // 0 for removed items
+}
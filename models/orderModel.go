@@ -2,7 +2,10 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
 )
 
 type OrderStatus string
@@ -24,49 +27,170 @@ func (s OrderStatus) IsValid() bool {
 	return false
 }
 
+// TransitionEffect is a side effect applied when an order changes status
+type TransitionEffect string
+
+const (
+	// EffectRestock puts the ordered quantities back into product stock
+	EffectRestock TransitionEffect = "restock"
+	// EffectVoidPayment releases an authorized, not yet captured payment
+	EffectVoidPayment TransitionEffect = "void_payment"
+)
+
+// StatusTransition is an allowed edge of the order status graph
+type StatusTransition struct {
+	From            OrderStatus
+	To              OrderStatus
+	CustomerAllowed bool // customers may apply it to their own orders, admins always can
+	RequiresPayment bool // the order must be paid
+	Effects         []TransitionEffect
+}
+
+// OrderStatusTransitions is the complete order status graph:
+//
+//	pending -> processing -> shipped -> delivered
+//	pending -> cancelled     (customer or admin, restocks)
+//	processing -> cancelled  (admin only, restocks)
+//
+// Only paid orders can be shipped. Cancelling voids an authorized payment.
+// Delivered and cancelled are final.
+var OrderStatusTransitions = []StatusTransition{
+	{From: StatusPending, To: StatusProcessing},
+	{From: StatusPending, To: StatusCancelled, CustomerAllowed: true, Effects: []TransitionEffect{EffectRestock, EffectVoidPayment}},
+	{From: StatusProcessing, To: StatusShipped, RequiresPayment: true},
+	{From: StatusProcessing, To: StatusCancelled, Effects: []TransitionEffect{EffectRestock, EffectVoidPayment}},
+	{From: StatusShipped, To: StatusDelivered},
+}
+
+// TransitionError describes a status change that the graph does not allow
+type TransitionError struct {
+	From   OrderStatus
+	To     OrderStatus
+	Reason string
+}
+
+func (e TransitionError) Error() string {
+	return e.Reason
+}
+
+// IsFinal reports whether no transition leaves the status
+func (s OrderStatus) IsFinal() bool {
+	for _, t := range OrderStatusTransitions {
+		if t.From == s {
+			return false
+		}
+	}
+	return true
+}
+
+// Transition looks up the edge from s to newStatus
+func (s OrderStatus) Transition(newStatus OrderStatus) (StatusTransition, bool) {
+	for _, t := range OrderStatusTransitions {
+		if t.From == s && t.To == newStatus {
+			return t, true
+		}
+	}
+	return StatusTransition{}, false
+}
+
+// AllowedTransitions lists the statuses the given role may move an order to
+func (s OrderStatus) AllowedTransitions(role UserRole) []OrderStatus {
+	allowed := []OrderStatus{}
+	for _, t := range OrderStatusTransitions {
+		if t.From == s && (role == UserRoleAdmin || t.CustomerAllowed) {
+			allowed = append(allowed, t.To)
+		}
+	}
+	return allowed
+}
+
 // Controls changing of order status
 func (s OrderStatus) ValidateTransition(newStatus OrderStatus) error {
 	if !newStatus.IsValid() {
-		return fmt.Errorf("invalid status: must be one of [pending, processing, shipped, delivered, cancelled]")
+		return TransitionError{From: s, To: newStatus, Reason: "invalid status: must be one of [pending, processing, shipped, delivered, cancelled]"}
+	}
+
+	if s.IsFinal() {
+		return TransitionError{From: s, To: newStatus, Reason: fmt.Sprintf("cannot change status of %s order", s)}
 	}
 
-	// we can control changing of order status here
-	// For example if an order had been completed and payment made, we can't cancel it
-	// etc
+	if _, ok := s.Transition(newStatus); !ok {
+		return TransitionError{From: s, To: newStatus, Reason: fmt.Sprintf("cannot change order status from %s to %s", s, newStatus)}
+	}
+
+	return nil
+}
 
-	// we can more rules as needed in this function
-	if s == StatusCancelled {
-		return fmt.Errorf("cannot change status of cancelled order")
+// ValidateTransitionFor additionally checks that the role may apply the transition
+func (s OrderStatus) ValidateTransitionFor(newStatus OrderStatus, role UserRole) error {
+	if err := s.ValidateTransition(newStatus); err != nil {
+		return err
 	}
 
-	// Can't change status of delivered orders
-	if s == StatusDelivered {
-		return fmt.Errorf("cannot change status of delivered order")
+	if t, _ := s.Transition(newStatus); role != UserRoleAdmin && !t.CustomerAllowed {
+		var from []string
+		for _, t := range OrderStatusTransitions {
+			if t.To == newStatus && t.CustomerAllowed {
+				from = append(from, string(t.From))
+			}
+		}
+		if len(from) == 0 {
+			return TransitionError{From: s, To: newStatus, Reason: fmt.Sprintf("only admins can change an order to %s", newStatus)}
+		}
+		return TransitionError{From: s, To: newStatus, Reason: fmt.Sprintf("only %s orders can be %s", strings.Join(from, " or "), newStatus)}
 	}
 
 	return nil
 }
 
 type Order struct {
-	CreatedAt   time.Time   `json:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at"`
-	DeletedAt   *time.Time  `json:"deleted_at,omitempty" gorm:"index"`
-	ID          uint        `gorm:"primarykey;autoIncrement:true;sequence:orders_id_seq" json:"id"`
-	UserID      uint        `json:"user_id" gorm:"not null"`
-	User        User        `json:"user"`
-	Status      OrderStatus `json:"status" gorm:"type:varchar(20);default:'pending'"`
-	TotalAmount float64     `json:"total_amount"`
-	Items       []OrderItem `json:"items"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	DeletedAt          *time.Time         `json:"deleted_at,omitempty" gorm:"index"`
+	ID                 uint               `gorm:"primarykey;autoIncrement:true;sequence:orders_id_seq" json:"id"`
+	UserID             uint               `json:"user_id" gorm:"not null"`
+	User               User               `json:"user"`
+	Status             OrderStatus        `json:"status" gorm:"type:varchar(20);default:'pending'"`
+	PaymentStatus      OrderPaymentStatus `json:"payment_status" gorm:"type:varchar(20);not null;default:'unpaid'"`
+	Subtotal           money.Money        `json:"subtotal" gorm:"embedded;embeddedPrefix:subtotal_"`             // items before discounts
+	DiscountTotal      money.Money        `json:"discount_total" gorm:"embedded;embeddedPrefix:discount_total_"` // sum of Discounts
+	TaxTotal           money.Money        `json:"tax_total" gorm:"embedded;embeddedPrefix:tax_total_"`           // inclusive and exclusive taxes
+	TotalAmount        money.Money        `json:"total_amount" gorm:"embedded;embeddedPrefix:total_amount_"`     // with shipping
+	RefundedTotal      money.Money        `json:"refunded_total" gorm:"embedded;embeddedPrefix:refunded_total_"`
+	ShippingTotal      money.Money        `json:"shipping_total" gorm:"embedded;embeddedPrefix:shipping_total_"` // zero with a free shipping coupon
+	TaxRegion          TaxRegion          `json:"tax_region" gorm:"embedded;embeddedPrefix:tax_"`
+	FreeShipping       bool               `json:"free_shipping"`                                         // set by a free shipping coupon
+	ShippingAddress    PostalAddress      `json:"shipping_address" gorm:"embedded;embeddedPrefix:ship_"` // copied from the address book
+	ShippingMethodID   *uint              `json:"shipping_method_id,omitempty"`
+	ShippingMethodName string             `json:"shipping_method_name,omitempty"`
+	Currency           string             `json:"currency" gorm:"type:varchar(3);not null;default:''"`         // locked at creation
+	ExchangeRate       string             `json:"exchange_rate" gorm:"type:numeric(20,10);not null;default:1"` // base currency to Currency, locked at creation
+	Items              []OrderItem        `json:"items"`
+	Discounts          []OrderDiscount    `json:"discounts,omitempty"`
+	TaxLines           []OrderTaxLine     `json:"tax_lines,omitempty"`
+	Payments           []Payment          `json:"payments,omitempty"`
+	Refunds            []Refund           `json:"refunds,omitempty"`
+	Changes            []OrderChange      `json:"changes,omitempty"`
+	Shipments          []Shipment         `json:"shipments,omitempty"`
+	History            []OrderStatusEvent `json:"history,omitempty"`
 }
 
 type OrderItem struct {
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at,omitempty" gorm:"index"`
-	ID        uint       `gorm:"primarykey;autoIncrement:true;sequence:order_items_id_seq" json:"id"`
-	OrderID   uint       `json:"order_id" gorm:"not null"`
-	ProductID uint       `json:"product_id" gorm:"not null"`
-	Product   Product    `json:"product"`
-	Quantity  int        `json:"quantity" gorm:"not null"`
-	Price     float64    `json:"price" gorm:"not null"` // price at time of order
+	CreatedAt         time.Time       `json:"created_at"`
+	UpdatedAt         time.Time       `json:"updated_at"`
+	DeletedAt         *time.Time      `json:"deleted_at,omitempty" gorm:"index"`
+	ID                uint            `gorm:"primarykey;autoIncrement:true;sequence:order_items_id_seq" json:"id"`
+	OrderID           uint            `json:"order_id" gorm:"not null"`
+	ProductID         uint            `json:"product_id" gorm:"not null"`
+	Product           Product         `json:"product"`
+	VariantID         *uint           `json:"variant_id,omitempty" gorm:"index"`
+	Variant           *ProductVariant `json:"variant,omitempty"`
+	Quantity          int             `json:"quantity" gorm:"not null"`                          // cancelled units excluded
+	Price             money.Money     `json:"price" gorm:"embedded;embeddedPrefix:price_"`       // price at time of order
+	Discount          money.Money     `json:"discount" gorm:"embedded;embeddedPrefix:discount_"` // share of the order discounts, for the whole line
+	Tax               money.Money     `json:"tax" gorm:"embedded;embeddedPrefix:tax_"`           // tax on the discounted line
+	Total             money.Money     `json:"total" gorm:"embedded;embeddedPrefix:total_"`       // charged for the line: after discount, with exclusive tax
+	RefundedQuantity  int             `json:"refunded_quantity" gorm:"not null;default:0"`
+	CancelledQuantity int             `json:"cancelled_quantity" gorm:"not null;default:0"` // cancelled while the order was processing
+	RestockedQuantity int             `json:"restocked_quantity" gorm:"not null;default:0"` // put back into stock by refunds, returns or cancelling the order
 }
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var allStatuses = []OrderStatus{StatusPending, StatusProcessing, StatusShipped, StatusDelivered, StatusCancelled}
+
+func TestValidateTransitionFor(t *testing.T) {
+	// every edge of the graph, and who may take it
+	type edge struct{ from, to OrderStatus }
+	customerAllowed := map[edge]bool{
+		{StatusPending, StatusCancelled}: true,
+	}
+	adminAllowed := map[edge]bool{
+		{StatusPending, StatusProcessing}:   true,
+		{StatusPending, StatusCancelled}:    true,
+		{StatusProcessing, StatusShipped}:   true,
+		{StatusProcessing, StatusCancelled}: true,
+		{StatusShipped, StatusDelivered}:    true,
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			e := edge{from, to}
+			for role, allowed := range map[UserRole]map[edge]bool{
+				UserRoleAdmin:    adminAllowed,
+				UserRoleCustomer: customerAllowed,
+			} {
+				err := from.ValidateTransitionFor(to, role)
+				if allowed[e] && err != nil {
+					t.Errorf("%s: %s -> %s refused: %v", role, from, to, err)
+				}
+				if !allowed[e] {
+					var transitionErr TransitionError
+					if !errors.As(err, &transitionErr) || transitionErr.From != from || transitionErr.To != to {
+						t.Errorf("%s: %s -> %s error = %v, want a TransitionError", role, from, to, err)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestValidateTransitionReasons(t *testing.T) {
+	tests := []struct {
+		from   OrderStatus
+		to     OrderStatus
+		role   UserRole
+		reason string
+	}{
+		{StatusPending, "lost", UserRoleAdmin, "invalid status: must be one of [pending, processing, shipped, delivered, cancelled]"},
+		{StatusDelivered, StatusPending, UserRoleAdmin, "cannot change status of delivered order"},
+		{StatusCancelled, StatusPending, UserRoleAdmin, "cannot change status of cancelled order"},
+		{StatusPending, StatusShipped, UserRoleAdmin, "cannot change order status from pending to shipped"},
+		{StatusPending, StatusProcessing, UserRoleCustomer, "only admins can change an order to processing"},
+		{StatusProcessing, StatusCancelled, UserRoleCustomer, "only pending orders can be cancelled"},
+	}
+	for _, tt := range tests {
+		err := tt.from.ValidateTransitionFor(tt.to, tt.role)
+		if err == nil || err.Error() != tt.reason {
+			t.Errorf("%s: %s -> %s error = %v, want %q", tt.role, tt.from, tt.to, err, tt.reason)
+		}
+	}
+}
+
+func TestAllowedTransitions(t *testing.T) {
+	tests := []struct {
+		from OrderStatus
+		role UserRole
+		want []OrderStatus
+	}{
+		{StatusPending, UserRoleAdmin, []OrderStatus{StatusProcessing, StatusCancelled}},
+		{StatusPending, UserRoleCustomer, []OrderStatus{StatusCancelled}},
+		{StatusProcessing, UserRoleAdmin, []OrderStatus{StatusShipped, StatusCancelled}},
+		{StatusProcessing, UserRoleCustomer, []OrderStatus{}},
+		{StatusShipped, UserRoleAdmin, []OrderStatus{StatusDelivered}},
+		{StatusDelivered, UserRoleAdmin, []OrderStatus{}},
+		{StatusCancelled, UserRoleAdmin, []OrderStatus{}},
+	}
+	for _, tt := range tests {
+		if got := tt.from.AllowedTransitions(tt.role); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s from %s = %v, want %v", tt.role, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestTransitionEffects(t *testing.T) {
+	for _, from := range []OrderStatus{StatusPending, StatusProcessing} {
+		transition, ok := from.Transition(StatusCancelled)
+		if !ok || !reflect.DeepEqual(transition.Effects, []TransitionEffect{EffectRestock, EffectVoidPayment}) {
+			t.Errorf("%s -> cancelled = %+v, want restock and void", from, transition)
+		}
+	}
+
+	shipping, _ := StatusProcessing.Transition(StatusShipped)
+	if !shipping.RequiresPayment {
+		t.Error("shipping doesn't require payment")
+	}
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			if transition, ok := from.Transition(to); ok && transition.RequiresPayment && to != StatusShipped {
+				t.Errorf("%s -> %s requires payment", from, to)
+			}
+		}
+	}
+}
+
+func TestIsFinal(t *testing.T) {
+	for _, status := range allStatuses {
+		want := status == StatusDelivered || status == StatusCancelled
+		if got := status.IsFinal(); got != want {
+			t.Errorf("%s.IsFinal() = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+)
+
+// OrderStatusEvent records a single status change of an order. The event
+// written when the order is created has an empty FromStatus.
+type OrderStatusEvent struct {
+	ID         uint        `gorm:"primarykey;autoIncrement:true;sequence:order_status_events_id_seq" json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	OrderID    uint        `json:"order_id" gorm:"not null;index"`
+	FromStatus OrderStatus `json:"from_status,omitempty" gorm:"type:varchar(20)"`
+	ToStatus   OrderStatus `json:"to_status" gorm:"type:varchar(20);not null"`
+	ActorID    uint        `json:"actor_id" gorm:"not null"`
+	Actor      User        `json:"actor"`
+	Note       string      `json:"note,omitempty"`
+}
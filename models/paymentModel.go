@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// OrderPaymentStatus sums up the payments of an order
+type OrderPaymentStatus string
+
+const (
+	OrderUnpaid            OrderPaymentStatus = "unpaid"
+	OrderPaymentAuthorized OrderPaymentStatus = "authorized" // reserved, not taken yet
+	OrderPaid              OrderPaymentStatus = "paid"
+	OrderPartiallyRefunded OrderPaymentStatus = "partially_refunded"
+	OrderRefunded          OrderPaymentStatus = "refunded"
+)
+
+// IsValid checks if the order payment status is valid
+func (s OrderPaymentStatus) IsValid() bool {
+	switch s {
+	case OrderUnpaid, OrderPaymentAuthorized, OrderPaid, OrderPartiallyRefunded, OrderRefunded:
+		return true
+	}
+	return false
+}
+
+// IsPaid reports whether the order's payment was captured and not entirely
+// refunded
+func (s OrderPaymentStatus) IsPaid() bool {
+	return s == OrderPaid || s == OrderPartiallyRefunded
+}
+
+// PaymentStatus is the state of a single payment at the gateway
+type PaymentStatus string
+
+const (
+	PaymentPending    PaymentStatus = "pending" // sent to the gateway, no answer yet
+	PaymentAuthorized PaymentStatus = "authorized"
+	PaymentCaptured   PaymentStatus = "captured"
+	PaymentVoided     PaymentStatus = "voided"
+	PaymentFailed     PaymentStatus = "failed"
+)
+
+// Payment is an attempt to pay an order through the payment provider. An
+// order has at most one pending, authorized or captured payment; declined
+// attempts stay as failed payments.
+type Payment struct {
+	ID            uint          `gorm:"primarykey;autoIncrement:true;sequence:payments_id_seq" json:"id"`
+	CreatedAt     time.Time     `json:"created_at"`
+	UpdatedAt     time.Time     `json:"updated_at"`
+	OrderID       uint          `json:"order_id" gorm:"not null;index"`
+	Provider      string        `json:"provider" gorm:"type:varchar(50);not null"`
+	Reference     string        `json:"reference" gorm:"type:varchar(255);index"` // the provider's ID, set once authorized
+	Status        PaymentStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Amount        money.Money   `json:"amount" gorm:"embedded;embeddedPrefix:amount_"` // in the order's currency
+	FailureReason string        `json:"failure_reason,omitempty"`
+	AuthorizedAt  *time.Time    `json:"authorized_at,omitempty"`
+	CapturedAt    *time.Time    `json:"captured_at,omitempty"`
+	VoidedAt      *time.Time    `json:"voided_at,omitempty"`
+}
+
+// PaymentWebhookEvent records the provider callbacks already handled, so
+// retried deliveries are only applied once
+type PaymentWebhookEvent struct {
+	ID        uint      `gorm:"primarykey;autoIncrement:true;sequence:payment_webhook_events_id_seq" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Provider  string    `json:"provider" gorm:"type:varchar(50);not null;uniqueIndex:idx_payment_webhook_events_event"`
+	EventID   string    `json:"event_id" gorm:"type:varchar(255);not null;uniqueIndex:idx_payment_webhook_events_event"`
+	Type      string    `json:"type" gorm:"type:varchar(50);not null"`
+	Reference string    `json:"reference" gorm:"type:varchar(255)"`
+}
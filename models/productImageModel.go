@@ -0,0 +1,65 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ImageVariant is one stored rendition of an image (a thumbnail size)
+type ImageVariant struct {
+	Key    string `json:"key"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// ImageThumbnails maps a thumbnail name (small, medium, large) to its
+// rendition. Stored as jsonb.
+type ImageThumbnails map[string]ImageVariant
+
+func (t ImageThumbnails) Value() (driver.Value, error) {
+	if t == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(map[string]ImageVariant(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (t *ImageThumbnails) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*t = ImageThumbnails{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ImageThumbnails", value)
+	}
+	return json.Unmarshal(data, (*map[string]ImageVariant)(t))
+}
+
+// ProductImage is an uploaded product picture. Position orders the images of
+// a product and exactly one of them is the primary image.
+type ProductImage struct {
+	ID          uint            `gorm:"primarykey;autoIncrement:true;sequence:product_images_id_seq" json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	ProductID   uint            `json:"product_id" gorm:"not null;index"`
+	Key         string          `json:"key" gorm:"not null"`
+	URL         string          `json:"url" gorm:"not null"`
+	ContentType string          `json:"content_type"`
+	Size        int64           `json:"size"`
+	Width       int             `json:"width"`
+	Height      int             `json:"height"`
+	Position    int             `json:"position" gorm:"not null;default:0"`
+	IsPrimary   bool            `json:"is_primary" gorm:"not null;default:false"`
+	Thumbnails  ImageThumbnails `json:"thumbnails" gorm:"type:jsonb;not null;default:'{}'"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type ImportJobStatus string
+
+const (
+	ImportJobQueued    ImportJobStatus = "queued"
+	ImportJobRunning   ImportJobStatus = "running"
+	ImportJobCompleted ImportJobStatus = "completed"
+	ImportJobFailed    ImportJobStatus = "failed"
+)
+
+// ImportRowError reports why a row of an import was skipped. Row counts data
+// rows from 1, not lines, so a CSV header is not counted.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportRowErrors is stored as jsonb
+type ImportRowErrors []ImportRowError
+
+func (e ImportRowErrors) Value() (driver.Value, error) {
+	if e == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal([]ImportRowError(e))
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (e *ImportRowErrors) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*e = ImportRowErrors{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into ImportRowErrors", value)
+	}
+	return json.Unmarshal(data, (*[]ImportRowError)(e))
+}
+
+// ProductImportJob tracks a bulk product import running in the background.
+// Error is set when the whole file was rejected, Errors lists skipped rows.
+type ProductImportJob struct {
+	ID            uint            `gorm:"primarykey;autoIncrement:true;sequence:product_import_jobs_id_seq" json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	UserID        uint            `json:"user_id" gorm:"not null;index"`
+	Filename      string          `json:"filename"`
+	Format        string          `json:"format" gorm:"not null"`
+	Status        ImportJobStatus `json:"status" gorm:"type:varchar(20);not null;default:'queued'"`
+	TotalRows     int             `json:"total_rows"`
+	ProcessedRows int             `json:"processed_rows"`
+	CreatedCount  int             `json:"created_count"`
+	UpdatedCount  int             `json:"updated_count"`
+	FailedCount   int             `json:"failed_count"`
+	Error         string          `json:"error,omitempty"`
+	Errors        ImportRowErrors `json:"errors" gorm:"type:jsonb;not null;default:'[]'"`
+	StartedAt     *time.Time      `json:"started_at"`
+	FinishedAt    *time.Time      `json:"finished_at"`
+}
+
+// Progress is the share of rows processed, from 0 to 100
+func (j ProductImportJob) Progress() float64 {
+	if j.TotalRows == 0 {
+		if j.Status == ImportJobCompleted {
+			return 100
+		}
+		return 0
+	}
+	return float64(j.ProcessedRows) * 100 / float64(j.TotalRows)
+}
@@ -2,19 +2,52 @@ package models
 
 import (
 	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
 )
 
+// ProductSearchConfig is the text search configuration used for the
+// search_vector column and for every query against it
+const ProductSearchConfig = "english"
+
+// ProductSearchVectorSQL generates products.search_vector, weighing the name
+// over the description. The column itself is created in SyncDb.
+const ProductSearchVectorSQL = "setweight(to_tsvector('" + ProductSearchConfig + "', coalesce(name, '')), 'A') || " +
+	"setweight(to_tsvector('" + ProductSearchConfig + "', coalesce(description, '')), 'B')"
+
 type Product struct {
-	ID          uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
-	CreatedAt   time.Time  `json:"created_at"`
-	UpdatedAt   time.Time  `json:"updated_at"`
-	DeletedAt   *time.Time `json:"deleted_at,omitempty" gorm:"index"`
-	Name        string     `json:"name"`
-	Description string     `json:"description"`
-	Price       float64    `json:"price"`
-	Stock       int        `json:"stock"`
-	IsActive    bool       `json:"is_active" gorm:"default:true"`
-
-	// we can add more fields like images, categories, etc.
-	// but for now we will keep it simple
+	ID          uint             `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+	DeletedAt   *time.Time       `json:"deleted_at,omitempty" gorm:"index"`
+	SKU         *string          `json:"sku" gorm:"uniqueIndex"` // optional, identifies the product in bulk imports
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Price       money.Money      `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	Stock       int              `json:"stock"`
+	TaxClass    string           `json:"tax_class" gorm:"type:varchar(50);not null;default:'standard'"`
+	Weight      int              `json:"weight" gorm:"not null;default:0"` // grams, per unit
+	Length      int              `json:"length" gorm:"not null;default:0"` // millimetres, of the packed unit
+	Width       int              `json:"width" gorm:"not null;default:0"`
+	Height      int              `json:"height" gorm:"not null;default:0"`
+	IsActive    bool             `json:"is_active" gorm:"default:true"`
+	Categories  []Category       `json:"categories,omitempty" gorm:"many2many:product_categories"`
+	Variants    []ProductVariant `json:"variants,omitempty"`
+	Images      []ProductImage   `json:"images,omitempty"`
+	Prices      []ProductPrice   `json:"prices,omitempty"` // explicit prices in other currencies
+
+	// Highlight is only set on search results
+	Highlight *ProductHighlight `json:"highlight,omitempty" gorm:"-"`
+}
+
+// IsAvailable reports whether the product can be ordered at all
+func (p Product) IsAvailable() bool {
+	return p.IsActive && p.DeletedAt == nil
+}
+
+// ProductHighlight holds search snippets with the matched words wrapped in
+// <mark> tags
+type ProductHighlight struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
 }
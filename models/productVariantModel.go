@@ -0,0 +1,69 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// VariantAttributes holds the options that tell variants apart,
+// e.g. {"size": "M", "color": "red"}. Stored as jsonb.
+type VariantAttributes map[string]string
+
+func (a VariantAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+func (a *VariantAttributes) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*a = VariantAttributes{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("cannot scan %T into VariantAttributes", value)
+	}
+	return json.Unmarshal(data, a)
+}
+
+// ProductVariant is a purchasable version of a product (a size, a color...)
+// with its own SKU and stock. Price overrides the product price when set.
+type ProductVariant struct {
+	ID         uint              `gorm:"primarykey;autoIncrement:true;sequence:product_variants_id_seq" json:"id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty" gorm:"index"`
+	ProductID  uint              `json:"product_id" gorm:"not null;index"`
+	SKU        string            `json:"sku" gorm:"type:varchar(100);uniqueIndex;not null"`
+	Attributes VariantAttributes `json:"attributes" gorm:"type:jsonb;not null;default:'{}'"`
+	Price      money.Money       `json:"price" gorm:"embedded;embeddedPrefix:price_"` // unset uses the product price
+	Stock      int               `json:"stock" gorm:"not null;default:0"`
+	IsActive   bool              `json:"is_active" gorm:"default:true"`
+}
+
+// EffectivePrice returns the variant price, falling back to the product price
+func (v ProductVariant) EffectivePrice(product Product) money.Money {
+	if v.Price.IsSet() {
+		return v.Price
+	}
+	return product.Price
+}
+
+// IsAvailable reports whether the variant can be ordered at all
+func (v ProductVariant) IsAvailable() bool {
+	return v.IsActive && v.DeletedAt == nil
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken is a single long-lived token handed out next to an access token.
+// Only the SHA-256 hash of the token is stored. Every token created by rotating
+// an earlier one shares the same FamilyID, so a whole login session can be
+// revoked at once.
+type RefreshToken struct {
+	ID           uint       `gorm:"primarykey;autoIncrement:true;sequence:refresh_tokens_id_seq" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	TokenHash    string     `json:"-" gorm:"type:char(64);uniqueIndex;not null"`
+	FamilyID     string     `json:"family_id" gorm:"type:varchar(64);index;not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ReplacedByID *uint      `json:"replaced_by_id,omitempty"`
+}
+
+// IsActive reports whether the token can still be exchanged for a new pair
+func (t RefreshToken) IsActive() bool {
+	return t.RevokedAt == nil && time.Now().Before(t.ExpiresAt)
+}
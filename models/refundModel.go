@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+type RefundStatus string
+
+const (
+	RefundPending   RefundStatus = "pending" // sent to the payment provider, no answer yet
+	RefundSucceeded RefundStatus = "succeeded"
+	RefundFailed    RefundStatus = "failed"
+)
+
+// Refund gives back part or all of an order's captured payment through the
+// provider that captured it. Items lists the refunded units; a refund without
+// items is for an amount only, e.g. a goodwill gesture.
+type Refund struct {
+	ID            uint         `gorm:"primarykey;autoIncrement:true;sequence:refunds_id_seq" json:"id"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	OrderID       uint         `json:"order_id" gorm:"not null;index"`
+	PaymentID     uint         `json:"payment_id" gorm:"not null;index"`
+	Provider      string       `json:"provider" gorm:"type:varchar(50);not null"`
+	Reference     string       `json:"reference" gorm:"type:varchar(255)"` // the provider's ID, set once refunded
+	Status        RefundStatus `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Amount        money.Money  `json:"amount" gorm:"embedded;embeddedPrefix:amount_"` // in the order's currency
+	Reason        string       `json:"reason"`
+	FailureReason string       `json:"failure_reason,omitempty"`
+	ActorID       uint         `json:"actor_id"` // the admin who refunded
+	Items         []RefundItem `json:"items,omitempty"`
+}
+
+// RefundItem is the refunded quantity of one order item
+type RefundItem struct {
+	ID          uint        `gorm:"primarykey;autoIncrement:true;sequence:refund_items_id_seq" json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	RefundID    uint        `json:"refund_id" gorm:"not null;index"`
+	OrderItemID uint        `json:"order_item_id" gorm:"not null;index"`
+	Quantity    int         `json:"quantity" gorm:"not null"`
+	Amount      money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"` // what the units were charged
+	Restocked   bool        `json:"restocked"`
+}
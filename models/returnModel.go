@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+)
+
+type ReturnStatus string
+
+const (
+	ReturnRequested ReturnStatus = "requested"
+	ReturnApproved  ReturnStatus = "approved" // the customer may send the goods
+	ReturnRejected  ReturnStatus = "rejected"
+	ReturnReceived  ReturnStatus = "received" // the goods arrived, waiting for inspection
+	ReturnCompleted ReturnStatus = "completed"
+)
+
+// IsValid checks if the return status is valid
+func (s ReturnStatus) IsValid() bool {
+	switch s {
+	case ReturnRequested, ReturnApproved, ReturnRejected, ReturnReceived, ReturnCompleted:
+		return true
+	}
+	return false
+}
+
+// ReturnStatusTransitions is the return workflow:
+//
+//	requested -> approved -> received -> completed
+//	requested -> rejected
+//
+// Rejected and completed are final.
+var ReturnStatusTransitions = map[ReturnStatus][]ReturnStatus{
+	ReturnRequested: {ReturnApproved, ReturnRejected},
+	ReturnApproved:  {ReturnReceived},
+	ReturnReceived:  {ReturnCompleted},
+}
+
+// CanMoveTo reports whether the workflow allows going from s to status
+func (s ReturnStatus) CanMoveTo(status ReturnStatus) bool {
+	for _, next := range ReturnStatusTransitions[s] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+type ReturnReason string
+
+const (
+	ReturnDamaged        ReturnReason = "damaged"
+	ReturnDefective      ReturnReason = "defective"
+	ReturnWrongItem      ReturnReason = "wrong_item"
+	ReturnNotAsDescribed ReturnReason = "not_as_described"
+	ReturnNoLongerNeeded ReturnReason = "no_longer_needed"
+	ReturnOtherReason    ReturnReason = "other"
+)
+
+// ReturnOutcome is what happens to returned goods after inspection
+type ReturnOutcome string
+
+const (
+	ReturnRestock  ReturnOutcome = "restock"   // back into stock
+	ReturnWriteOff ReturnOutcome = "write_off" // not sellable, stock is unchanged
+)
+
+// ReturnRequest is a customer's request to send items of a delivered order
+// back (an RMA). Completing it after inspection can refund the items; the
+// refund is linked by RefundID.
+type ReturnRequest struct {
+	ID          uint         `gorm:"primarykey;autoIncrement:true;sequence:return_requests_id_seq" json:"id"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+	OrderID     uint         `json:"order_id" gorm:"not null;index"`
+	UserID      uint         `json:"user_id" gorm:"not null;index"`
+	Status      ReturnStatus `json:"status" gorm:"type:varchar(20);not null;default:'requested'"`
+	Note        string       `json:"note"`       // from the customer
+	AdminNote   string       `json:"admin_note"` // e.g. why it was rejected
+	RefundID    *uint        `json:"refund_id,omitempty"`
+	Refund      *Refund      `json:"refund,omitempty"`
+	ApprovedAt  *time.Time   `json:"approved_at,omitempty"`
+	ReceivedAt  *time.Time   `json:"received_at,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty"`
+	Items       []ReturnItem `json:"items"`
+}
+
+type ReturnItem struct {
+	ID              uint          `gorm:"primarykey;autoIncrement:true;sequence:return_items_id_seq" json:"id"`
+	CreatedAt       time.Time     `json:"created_at"`
+	ReturnRequestID uint          `json:"return_request_id" gorm:"not null;index"`
+	OrderItemID     uint          `json:"order_item_id" gorm:"not null;index"`
+	OrderItem       *OrderItem    `json:"order_item,omitempty"`
+	Quantity        int           `json:"quantity" gorm:"not null"`
+	Reason          ReturnReason  `json:"reason" gorm:"type:varchar(30);not null"`
+	Comment         string        `json:"comment"`
+	Outcome         ReturnOutcome `json:"outcome,omitempty" gorm:"type:varchar(20);not null;default:''"` // set on inspection
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+)
+
+type ShipmentStatus string
+
+const (
+	ShipmentShipped   ShipmentStatus = "shipped" // handed to the carrier
+	ShipmentDelivered ShipmentStatus = "delivered"
+)
+
+// Shipment is a parcel of an order handed to a carrier. An order is shipped
+// once shipments hold all its units, and delivered once they all are.
+type Shipment struct {
+	ID             uint           `gorm:"primarykey;autoIncrement:true;sequence:shipments_id_seq" json:"id"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	OrderID        uint           `json:"order_id" gorm:"not null;index"`
+	Carrier        string         `json:"carrier" gorm:"not null"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         ShipmentStatus `json:"status" gorm:"type:varchar(20);not null;default:'shipped'"`
+	ActorID        uint           `json:"actor_id" gorm:"not null"`
+	DeliveredAt    *time.Time     `json:"delivered_at,omitempty"`
+	Items          []ShipmentItem `json:"items"`
+}
+
+type ShipmentItem struct {
+	ID          uint `gorm:"primarykey;autoIncrement:true;sequence:shipment_items_id_seq" json:"id"`
+	ShipmentID  uint `json:"shipment_id" gorm:"not null;index"`
+	OrderItemID uint `json:"order_item_id" gorm:"not null;index"`
+	Quantity    int  `json:"quantity" gorm:"not null"`
+}
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+type ShippingRateType string
+
+const (
+	ShippingRateFlat   ShippingRateType = "flat"   // Price per order
+	ShippingRateWeight ShippingRateType = "weight" // Price plus PricePerKg for every started kilogram
+)
+
+// ShippingMethod is an admin managed way of shipping orders, e.g. "Standard"
+// or "Express", priced by its rates
+type ShippingMethod struct {
+	ID                uint           `gorm:"primarykey;autoIncrement:true;sequence:shipping_methods_id_seq" json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	UpdatedAt         time.Time      `json:"updated_at"`
+	DeletedAt         *time.Time     `json:"deleted_at,omitempty" gorm:"index"`
+	Name              string         `json:"name" gorm:"not null"`
+	Description       string         `json:"description"`
+	Carrier           string         `json:"carrier"`            // default carrier of its shipments
+	VolumetricDivisor int            `json:"volumetric_divisor"` // cm³ per kg of volumetric weight, 0 to charge the actual weight only
+	IsActive          bool           `json:"is_active" gorm:"default:true"`
+	Rates             []ShippingRate `json:"rates"`
+}
+
+// ShippingRate prices a shipping method in a zone for a weight bracket.
+// Amounts are in the base currency and converted for orders in other
+// currencies.
+//
+// A zone is a country, optionally narrowed to a state, or everywhere when the
+// country is empty. The most specific zone matching the destination wins, and
+// within a zone the first rate whose bracket holds the weight.
+type ShippingRate struct {
+	ID               uint             `gorm:"primarykey;autoIncrement:true;sequence:shipping_rates_id_seq" json:"id"`
+	CreatedAt        time.Time        `json:"created_at"`
+	ShippingMethodID uint             `json:"shipping_method_id" gorm:"not null;index"`
+	Country          string           `json:"country" gorm:"type:varchar(2);not null;default:''"`
+	State            string           `json:"state" gorm:"type:varchar(50);not null;default:''"`
+	MinWeight        int              `json:"min_weight" gorm:"not null;default:0"` // grams, inclusive
+	MaxWeight        int              `json:"max_weight" gorm:"not null;default:0"` // grams, exclusive; 0 for no upper bound
+	Type             ShippingRateType `json:"type" gorm:"type:varchar(20);not null"`
+	Price            money.Money      `json:"price" gorm:"embedded;embeddedPrefix:price_"`
+	PricePerKg       money.Money      `json:"price_per_kg" gorm:"embedded;embeddedPrefix:price_per_kg_"` // weight rates only
+	FreeOver         money.Money      `json:"free_over" gorm:"embedded;embeddedPrefix:free_over_"`       // free from this discounted subtotal, unset for never
+}
+
+// Holds reports whether the rate's bracket holds the weight in grams
+func (r ShippingRate) Holds(weight int) bool {
+	return weight >= r.MinWeight && (r.MaxWeight == 0 || weight < r.MaxWeight)
+}
+
+// Covers reports how specifically the rate's zone covers the address: 0 when
+// it doesn't, then 1 for everywhere, 2 for the country and 3 for the state
+func (r ShippingRate) Covers(address PostalAddress) int {
+	switch {
+	case r.Country == "":
+		return 1
+	case r.Country != address.Country:
+		return 0
+	case r.State == "":
+		return 2
+	case r.State == address.State:
+		return 3
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// DefaultTaxClass is the tax class of products that don't name one
+const DefaultTaxClass = "standard"
+
+// TaxRegion is where an order is taxed
+type TaxRegion struct {
+	Country    string `json:"country" gorm:"column:country;type:varchar(2);not null;default:''"` // ISO 3166-1 alpha-2
+	State      string `json:"state" gorm:"column:state;type:varchar(50);not null;default:''"`
+	PostalCode string `json:"postal_code" gorm:"column:postal_code;type:varchar(20);not null;default:''"`
+}
+
+// IsSet reports whether a region was given at all
+func (r TaxRegion) IsSet() bool {
+	return r.Country != ""
+}
+
+// TaxRule is a tax rate for a tax class in a region. A rule without a state
+// or postal prefix covers the whole country; every rule matching an item
+// applies, so a state rate and a city rate can add up.
+//
+// Inclusive rules are already contained in the price, exclusive ones are
+// added on top of it.
+type TaxRule struct {
+	ID           uint      `gorm:"primarykey;autoIncrement:true;sequence:tax_rules_id_seq" json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	Name         string    `json:"name" gorm:"not null"` // shown on the order, e.g. "VAT" or "CA state tax"
+	TaxClass     string    `json:"tax_class" gorm:"type:varchar(50);not null;index"`
+	Country      string    `json:"country" gorm:"type:varchar(2);not null"`
+	State        string    `json:"state" gorm:"type:varchar(50);not null;default:''"`
+	PostalPrefix string    `json:"postal_prefix" gorm:"type:varchar(20);not null;default:''"`
+	Rate         string    `json:"rate" gorm:"type:numeric(7,4);not null"` // percent, e.g. "20.0000"
+	Inclusive    bool      `json:"inclusive"`
+}
+
+// OrderTaxLine is the tax an order pays for one rule, summed over its items
+type OrderTaxLine struct {
+	ID        uint        `gorm:"primarykey;autoIncrement:true;sequence:order_tax_lines_id_seq" json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	OrderID   uint        `json:"order_id" gorm:"not null;index"`
+	TaxRuleID uint        `json:"tax_rule_id" gorm:"not null"`
+	Name      string      `json:"name" gorm:"not null"`
+	Rate      string      `json:"rate" gorm:"type:numeric(7,4);not null"`
+	Inclusive bool        `json:"inclusive"`
+	Amount    money.Money `json:"amount" gorm:"embedded;embeddedPrefix:amount_"`
+}
@@ -42,6 +42,10 @@ type User struct {
 	Password  string     `json:"-"` // Hide from JSON responses
 	Role      UserRole   `json:"role" gorm:"type:varchar(20);default:'customer'"`
 
+	// TokenVersion is embedded in every access token; bumping it invalidates
+	// all access tokens issued before (used by logout-all)
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+
 	// we can add more fields here like first name, last name, phone number, etc
 	// but we will keep it simple for now
 }
@@ -0,0 +1,289 @@
+package money
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+	ErrInvalidCurrency  = errors.New("currency must be a three letter ISO 4217 code")
+)
+
+// Money is an exact amount in the minor units of its currency (cents for
+// USD, yen for JPY). A Money without a currency is "no amount".
+//
+// In the database it is embedded as two columns, e.g. with
+// `gorm:"embedded;embeddedPrefix:price_"` as price_minor and price_currency.
+type Money struct {
+	Amount   int64  `gorm:"column:minor;not null;default:0"`
+	Currency string `gorm:"column:currency;type:varchar(3);not null;default:''"`
+}
+
+// currencies without the usual two decimals
+var exponents = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// BaseCurrency is the store currency from BASE_CURRENCY, USD by default
+func BaseCurrency() string {
+	if currency, err := NormalizeCurrency(os.Getenv("BASE_CURRENCY")); err == nil {
+		return currency
+	}
+	return "USD"
+}
+
+// NormalizeCurrency upper-cases a currency code and checks its shape
+func NormalizeCurrency(currency string) (string, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if len(currency) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return currency, nil
+}
+
+// Exponent is the number of decimals of the currency's minor unit
+func Exponent(currency string) int {
+	if exponent, ok := exponents[currency]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// MinorFactor is the number of minor units in one major unit
+func MinorFactor(currency string) int64 {
+	factor := int64(1)
+	for i := 0; i < Exponent(currency); i++ {
+		factor *= 10
+	}
+	return factor
+}
+
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: currency}
+}
+
+// Parse reads a decimal amount such as "19.99" in the given currency.
+// More decimals than the currency has are rejected rather than rounded.
+func Parse(value string, currency string) (Money, error) {
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	value = strings.TrimSpace(value)
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimPrefix(value, "-")
+
+	whole, fraction, _ := strings.Cut(value, ".")
+	exponent := Exponent(currency)
+	if (whole == "" && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return Money{}, fmt.Errorf("%q is not a valid amount", value)
+	}
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("%s amounts have at most %d decimals", currency, exponent)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	amount, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("%q is not a valid amount", value)
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsSet reports whether the amount has a currency, i.e. is not "no amount"
+func (m Money) IsSet() bool {
+	return m.Currency != ""
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// Add sums two amounts of the same currency. Adding to an unset Money takes
+// the other currency, so a zero value can start a sum.
+func (m Money) Add(other Money) (Money, error) {
+	if !m.IsSet() {
+		return other, nil
+	}
+	if !other.IsSet() {
+		return m, nil
+	}
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(other.Neg())
+}
+
+func (m Money) Neg() Money {
+	return Money{Amount: -m.Amount, Currency: m.Currency}
+}
+
+// Mul multiplies by a whole quantity
+func (m Money) Mul(quantity int) Money {
+	return Money{Amount: m.Amount * int64(quantity), Currency: m.Currency}
+}
+
+// Cmp compares two amounts of the same currency: -1, 0 or 1. Like Add, an
+// unset Money counts as zero in the other currency; amounts in different
+// currencies are an ErrCurrencyMismatch rather than compared as numbers.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.IsSet() && other.IsSet() && m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// String formats the amount as a decimal without the currency, e.g. "19.99"
+func (m Money) String() string {
+	exponent := Exponent(m.Currency)
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exponent {
+		digits = strings.Repeat("0", exponent-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exponent] + "." + digits[len(digits)-exponent:]
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// MarshalJSON writes {"amount": "19.99", "currency": "USD"}; the amount is a
+// string so clients never round it through a float. Unset amounts are null.
+func (m Money) MarshalJSON() ([]byte, error) {
+	if !m.IsSet() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(moneyJSON{Amount: m.String(), Currency: m.Currency})
+}
+
+// UnmarshalJSON accepts {"amount": "19.99", "currency": "USD"} as well as a
+// bare "19.99" or 19.99. Without a currency the base currency is assumed.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	var input struct {
+		Amount   json.RawMessage `json:"amount"`
+		Currency string          `json:"currency"`
+	}
+	if len(data) > 0 && data[0] == '{' {
+		if err := json.Unmarshal(data, &input); err != nil {
+			return err
+		}
+	} else {
+		input.Amount = data
+	}
+
+	currency := input.Currency
+	if currency == "" {
+		currency = BaseCurrency()
+	}
+
+	// the amount is kept as text, a JSON number is never read into a float
+	raw := string(bytes.TrimSpace(input.Amount))
+	if unquoted, err := strconv.Unquote(raw); err == nil {
+		raw = unquoted
+	} else if _, err := strconv.ParseFloat(raw, 64); err != nil || strings.ContainsAny(raw, "eE") {
+		return fmt.Errorf("%s is not a valid amount", raw)
+	}
+
+	parsed, err := Parse(raw, currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// ParseRate reads a positive exchange rate such as "0.9213"
+func ParseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || rate.Sign() <= 0 {
+		return nil, fmt.Errorf("%q is not a valid exchange rate", value)
+	}
+	return rate, nil
+}
+
+// Convert changes the amount to another currency at rate, the units of
+// currency that one unit of m's currency buys. The result is rounded half
+// away from zero to the minor unit.
+func (m Money) Convert(currency string, rate *big.Rat) Money {
+	factor := new(big.Rat).Mul(rate, new(big.Rat).SetFrac64(MinorFactor(currency), MinorFactor(m.Currency)))
+	return Money{Amount: m.MulRat(factor).Amount, Currency: currency}
+}
+
+// MulRat multiplies by an exact fraction, rounding half away from zero to the
+// minor unit
+func (m Money) MulRat(factor *big.Rat) Money {
+	value := new(big.Rat).SetInt64(m.Amount)
+	value.Mul(value, factor)
+
+	num := new(big.Int).Abs(value.Num())
+	den := value.Denom()
+	// (2n + d) / 2d is n/d rounded half up
+	num.Add(num.Lsh(num, 1), den)
+	amount := num.Quo(num, new(big.Int).Lsh(den, 1)).Int64()
+	if value.Sign() < 0 {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: m.Currency}
+}
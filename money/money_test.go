@@ -0,0 +1,272 @@
+package money
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestParseAndString(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+		amount   int64
+		want     string // String() of the parsed amount
+	}{
+		// two decimals
+		{"19.99", "USD", 1999, "19.99"},
+		{"0.5", "USD", 50, "0.50"},
+		{"5", "USD", 500, "5.00"},
+		{"5.", "USD", 500, "5.00"},
+		{".05", "USD", 5, "0.05"},
+		{"-3", "USD", -300, "-3.00"},
+		{" 7.10 ", "usd", 710, "7.10"},
+		{"0", "EUR", 0, "0.00"},
+		// no decimals
+		{"1500", "JPY", 1500, "1500"},
+		{"-20", "KRW", -20, "-20"},
+		// three decimals
+		{"1.234", "KWD", 1234, "1.234"},
+		{"0.001", "BHD", 1, "0.001"},
+		{"2.5", "JOD", 2500, "2.500"},
+	}
+	for _, tt := range tests {
+		m, err := Parse(tt.value, tt.currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %s): %v", tt.value, tt.currency, err)
+			continue
+		}
+		if m.Amount != tt.amount {
+			t.Errorf("Parse(%q, %s) = %d, want %d", tt.value, tt.currency, m.Amount, tt.amount)
+		}
+		if got := m.String(); got != tt.want {
+			t.Errorf("Parse(%q, %s).String() = %q, want %q", tt.value, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	tests := []struct {
+		value    string
+		currency string
+	}{
+		{"19.999", "USD"}, // more decimals than the currency has
+		{"15.5", "JPY"},
+		{"1.2345", "KWD"},
+		{"", "USD"},
+		{".", "USD"},
+		{"-", "USD"},
+		{"abc", "USD"},
+		{"1,50", "USD"},
+		{"1e3", "USD"},
+		{"+5", "USD"},
+		{"--5", "USD"},
+		{"99999999999999999999", "USD"},
+		{"5", "US"},
+		{"5", "U1D"},
+	}
+	for _, tt := range tests {
+		if m, err := Parse(tt.value, tt.currency); err == nil {
+			t.Errorf("Parse(%q, %s) = %v, want an error", tt.value, tt.currency, m)
+		}
+	}
+}
+
+func TestStringSmallAmounts(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(5, "USD"), "0.05"},
+		{New(-5, "USD"), "-0.05"},
+		{New(5, "KWD"), "0.005"},
+		{New(-1005, "KWD"), "-1.005"},
+		{New(5, "JPY"), "5"},
+	}
+	for _, tt := range tests {
+		if got := tt.money.String(); got != tt.want {
+			t.Errorf("%#v.String() = %q, want %q", tt.money, got, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	t.Setenv("BASE_CURRENCY", "USD")
+
+	tests := []struct {
+		json string
+		want Money
+	}{
+		{`"19.99"`, New(1999, "USD")},
+		{`19.99`, New(1999, "USD")},
+		{`0`, New(0, "USD")},
+		{`{"amount": "5", "currency": "eur"}`, New(500, "EUR")},
+		{`{"amount": 1500, "currency": "JPY"}`, New(1500, "JPY")},
+		{`{"amount": "1.5"}`, New(150, "USD")},
+		{`null`, Money{}},
+	}
+	for _, tt := range tests {
+		var m Money
+		if err := json.Unmarshal([]byte(tt.json), &m); err != nil {
+			t.Errorf("Unmarshal(%s): %v", tt.json, err)
+			continue
+		}
+		if m != tt.want {
+			t.Errorf("Unmarshal(%s) = %#v, want %#v", tt.json, m, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalJSONRejects(t *testing.T) {
+	t.Setenv("BASE_CURRENCY", "USD")
+
+	for _, input := range []string{
+		`"19.999"`,
+		`19.999`,
+		`1e3`,
+		`"1e3"`,
+		`"abc"`,
+		`""`,
+		`true`,
+		`[1]`,
+		`{"amount": "15.5", "currency": "JPY"}`,
+		`{"amount": "1", "currency": "US"}`,
+		`{"amount": true}`,
+		`{"amount": "1"`,
+	} {
+		var m Money
+		if err := json.Unmarshal([]byte(input), &m); err == nil {
+			t.Errorf("Unmarshal(%s) = %#v, want an error", input, m)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(1999, "USD"), `{"amount":"19.99","currency":"USD"}`},
+		{New(1234, "KWD"), `{"amount":"1.234","currency":"KWD"}`},
+		{Money{}, `null`},
+	}
+	for _, tt := range tests {
+		got, err := json.Marshal(tt.money)
+		if err != nil {
+			t.Errorf("Marshal(%#v): %v", tt.money, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Marshal(%#v) = %s, want %s", tt.money, got, tt.want)
+		}
+	}
+}
+
+func mustRate(t *testing.T, value string) *big.Rat {
+	t.Helper()
+	rate, err := ParseRate(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rate
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		money    Money
+		currency string
+		rate     string
+		want     int64
+	}{
+		{New(1000, "USD"), "EUR", "0.9213", 921},   // 921.3 cents
+		{New(1999, "USD"), "JPY", "151.234", 3023}, // 3023.16 yen
+		{New(100, "USD"), "JPY", "0.5", 1},         // 0.5 yen rounds away from zero
+		{New(-100, "USD"), "JPY", "0.5", -1},       // and so does -0.5
+		{New(100, "USD"), "JPY", "0.49", 0},        // 0.49 yen
+		{New(1000, "KWD"), "USD", "3.25", 325},     // 1.000 KWD
+		{New(5000, "JPY"), "KWD", "0.002", 10000},  // 10.000 KWD
+		{New(1, "USD"), "EUR", "1", 1},
+	}
+	for _, tt := range tests {
+		got := tt.money.Convert(tt.currency, mustRate(t, tt.rate))
+		if got != New(tt.want, tt.currency) {
+			t.Errorf("%v %s at %s = %#v, want %d %s", tt.money, tt.money.Currency, tt.rate, got, tt.want, tt.currency)
+		}
+	}
+}
+
+func TestMulRat(t *testing.T) {
+	tests := []struct {
+		amount int64
+		factor *big.Rat
+		want   int64
+	}{
+		{5, big.NewRat(1, 2), 3},         // 2.5 rounds half up
+		{-5, big.NewRat(1, 2), -3},       // and half down below zero
+		{7, big.NewRat(1, 3), 2},         // 2.33
+		{10, big.NewRat(2, 3), 7},        // 6.67
+		{1999, big.NewRat(20, 100), 400}, // 399.8
+		{0, big.NewRat(7, 3), 0},
+	}
+	for _, tt := range tests {
+		got := New(tt.amount, "USD").MulRat(tt.factor)
+		if got != New(tt.want, "USD") {
+			t.Errorf("%d * %s = %#v, want %d", tt.amount, tt.factor, got, tt.want)
+		}
+	}
+}
+
+func TestParseRateRejects(t *testing.T) {
+	for _, value := range []string{"", "0", "-1", "abc"} {
+		if _, err := ParseRate(value); err == nil {
+			t.Errorf("ParseRate(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestAddSub(t *testing.T) {
+	usd, eur := New(1000, "USD"), New(500, "EUR")
+
+	if got, err := usd.Add(New(250, "USD")); err != nil || got != New(1250, "USD") {
+		t.Errorf("Add = %#v, %v", got, err)
+	}
+	if got, err := usd.Sub(New(1250, "USD")); err != nil || got != New(-250, "USD") {
+		t.Errorf("Sub = %#v, %v", got, err)
+	}
+	if got, err := (Money{}).Add(eur); err != nil || got != eur {
+		t.Errorf("unset Add = %#v, %v, want the other amount", got, err)
+	}
+	if got, err := eur.Sub(Money{}); err != nil || got != eur {
+		t.Errorf("Sub unset = %#v, %v, want the amount", got, err)
+	}
+	if _, err := usd.Add(eur); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("USD + EUR error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := usd.Sub(eur); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("USD - EUR error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestCmp(t *testing.T) {
+	tests := []struct {
+		a, b Money
+		want int
+	}{
+		{New(100, "USD"), New(200, "USD"), -1},
+		{New(200, "USD"), New(100, "USD"), 1},
+		{New(100, "USD"), New(100, "USD"), 0},
+		{Money{}, New(1, "USD"), -1},
+		{New(0, "EUR"), Money{}, 0},
+	}
+	for _, tt := range tests {
+		got, err := tt.a.Cmp(tt.b)
+		if err != nil || got != tt.want {
+			t.Errorf("%#v.Cmp(%#v) = %d, %v, want %d", tt.a, tt.b, got, err, tt.want)
+		}
+	}
+
+	if _, err := New(100, "USD").Cmp(New(100, "EUR")); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("USD Cmp EUR error = %v, want ErrCurrencyMismatch", err)
+	}
+}
@@ -0,0 +1,139 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// Tokens that make the mock gateway decline an authorization; any other
+// token is approved
+const (
+	MockTokenDeclined          = "tok_declined"
+	MockTokenInsufficientFunds = "tok_insufficient_funds"
+)
+
+// MockSignatureHeader carries the signature of mock webhooks:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<payload>">"
+const MockSignatureHeader = "X-Mock-Signature"
+
+// mockWebhookTolerance is how old a webhook may be, against replays
+const mockWebhookTolerance = 5 * time.Minute
+
+// MockProvider is a local gateway for development and tests. It keeps no
+// state and never calls out: the outcome of an authorization depends only on
+// its token, and references are derived from our own record IDs, so the same
+// request always gives the same result.
+type MockProvider struct {
+	WebhookSecret string // webhooks are refused without one
+	Now           func() time.Time
+}
+
+func NewMockProvider(webhookSecret string) *MockProvider {
+	return &MockProvider{WebhookSecret: webhookSecret, Now: time.Now}
+}
+
+func (p *MockProvider) Name() string {
+	return "mock"
+}
+
+func (p *MockProvider) Authorize(ctx context.Context, request AuthorizeRequest) (Result, error) {
+	if !request.Amount.IsPositive() {
+		return Result{}, fmt.Errorf("mock: amount must be positive")
+	}
+
+	switch request.Token {
+	case MockTokenDeclined:
+		return Result{}, DeclinedError{Code: "card_declined", Message: "the card was declined"}
+	case MockTokenInsufficientFunds:
+		return Result{}, DeclinedError{Code: "insufficient_funds", Message: "the card has insufficient funds"}
+	}
+	return Result{Reference: fmt.Sprintf("mock_pay_%d", request.PaymentID)}, nil
+}
+
+func (p *MockProvider) Capture(ctx context.Context, reference string, amount money.Money) (Result, error) {
+	if !strings.HasPrefix(reference, "mock_pay_") {
+		return Result{}, fmt.Errorf("mock: unknown payment %q", reference)
+	}
+	if !amount.IsPositive() {
+		return Result{}, fmt.Errorf("mock: amount must be positive")
+	}
+	return Result{Reference: reference}, nil
+}
+
+func (p *MockProvider) Void(ctx context.Context, reference string) (Result, error) {
+	if !strings.HasPrefix(reference, "mock_pay_") {
+		return Result{}, fmt.Errorf("mock: unknown payment %q", reference)
+	}
+	return Result{Reference: reference}, nil
+}
+
+func (p *MockProvider) Refund(ctx context.Context, request RefundRequest) (Result, error) {
+	if !strings.HasPrefix(request.Reference, "mock_pay_") {
+		return Result{}, fmt.Errorf("mock: unknown payment %q", request.Reference)
+	}
+	if !request.Amount.IsPositive() {
+		return Result{}, fmt.Errorf("mock: amount must be positive")
+	}
+	return Result{Reference: fmt.Sprintf("mock_re_%d", request.RefundID)}, nil
+}
+
+// Sign returns the MockSignatureHeader value for a webhook payload sent at
+// the given time
+func (p *MockProvider) Sign(payload []byte, at time.Time) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+	return "t=" + timestamp + ",v1=" + p.signature(timestamp, payload)
+}
+
+func (p *MockProvider) ParseWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+	if p.WebhookSecret == "" {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var timestamp, signature string
+	for _, part := range strings.Split(header.Get(MockSignatureHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(p.signature(timestamp, payload))) {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+	if age := p.Now().Sub(time.Unix(unix, 0)); age > mockWebhookTolerance || age < -mockWebhookTolerance {
+		return WebhookEvent{}, ErrInvalidSignature
+	}
+
+	var event WebhookEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return WebhookEvent{}, fmt.Errorf("mock: invalid webhook payload: %w", err)
+	}
+	if event.ID == "" || event.Reference == "" {
+		return WebhookEvent{}, fmt.Errorf("mock: webhook without id or reference")
+	}
+	return event, nil
+}
+
+func (p *MockProvider) signature(timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(p.WebhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
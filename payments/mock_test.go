@@ -0,0 +1,100 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+func TestMockAuthorize(t *testing.T) {
+	p := NewMockProvider("secret")
+	amount := money.New(1999, "USD")
+
+	result, err := p.Authorize(context.Background(), AuthorizeRequest{PaymentID: 7, OrderID: 3, Amount: amount, Token: "tok_visa"})
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if result.Reference != "mock_pay_7" {
+		t.Errorf("reference = %q, want mock_pay_7", result.Reference)
+	}
+
+	tests := []struct {
+		token string
+		code  string
+	}{
+		{MockTokenDeclined, "card_declined"},
+		{MockTokenInsufficientFunds, "insufficient_funds"},
+	}
+	for _, tt := range tests {
+		_, err := p.Authorize(context.Background(), AuthorizeRequest{PaymentID: 7, Amount: amount, Token: tt.token})
+		var declined DeclinedError
+		if !errors.As(err, &declined) || declined.Code != tt.code {
+			t.Errorf("Authorize(%s) error = %v, want a %s decline", tt.token, err, tt.code)
+		}
+	}
+
+	if _, err := p.Authorize(context.Background(), AuthorizeRequest{PaymentID: 7, Amount: money.New(0, "USD")}); err == nil {
+		t.Error("Authorize of a zero amount succeeded")
+	}
+}
+
+func TestMockParseWebhook(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	p := &MockProvider{WebhookSecret: "secret", Now: func() time.Time { return now }}
+	payload := []byte(`{"id":"evt_1","type":"payment.captured","reference":"mock_pay_7","amount":{"amount":"19.99","currency":"USD"}}`)
+
+	header := http.Header{}
+	header.Set(MockSignatureHeader, p.Sign(payload, now.Add(-time.Minute)))
+	event, err := p.ParseWebhook(payload, header)
+	if err != nil {
+		t.Fatalf("ParseWebhook: %v", err)
+	}
+	if event.ID != "evt_1" || event.Type != EventCaptured || event.Reference != "mock_pay_7" || event.Amount != money.New(1999, "USD") {
+		t.Errorf("event = %+v", event)
+	}
+
+	otherSecret := &MockProvider{WebhookSecret: "other", Now: p.Now}
+	tests := []struct {
+		name      string
+		payload   []byte
+		signature string
+	}{
+		{"tampered payload", []byte(`{"id":"evt_1","type":"payment.captured","reference":"mock_pay_8"}`), p.Sign(payload, now)},
+		{"other secret", payload, otherSecret.Sign(payload, now)},
+		{"stale", payload, p.Sign(payload, now.Add(-mockWebhookTolerance-time.Second))},
+		{"from the future", payload, p.Sign(payload, now.Add(mockWebhookTolerance+time.Second))},
+		{"no timestamp", payload, "v1=" + p.signature("", payload)},
+		{"no header", payload, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set(MockSignatureHeader, tt.signature)
+			if _, err := p.ParseWebhook(tt.payload, header); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("error = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+
+	t.Run("no secret", func(t *testing.T) {
+		unsigned := &MockProvider{Now: p.Now}
+		header := http.Header{}
+		header.Set(MockSignatureHeader, unsigned.Sign(payload, now))
+		if _, err := unsigned.ParseWebhook(payload, header); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("error = %v, want ErrInvalidSignature", err)
+		}
+	})
+
+	t.Run("without reference", func(t *testing.T) {
+		payload := []byte(`{"id":"evt_2","type":"payment.captured"}`)
+		header := http.Header{}
+		header.Set(MockSignatureHeader, p.Sign(payload, now))
+		if _, err := p.ParseWebhook(payload, header); err == nil || errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("error = %v, want an invalid payload", err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// Provider is a payment gateway. A payment is authorized first, which
+// reserves the amount, then either captured to take the money or voided to
+// release it. Captured payments can be refunded, in several parts.
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, request AuthorizeRequest) (Result, error)
+	Capture(ctx context.Context, reference string, amount money.Money) (Result, error)
+	Void(ctx context.Context, reference string) (Result, error)
+	Refund(ctx context.Context, request RefundRequest) (Result, error)
+
+	// ParseWebhook verifies the signature of a callback from the gateway
+	// and reads the event in it
+	ParseWebhook(payload []byte, header http.Header) (WebhookEvent, error)
+}
+
+type AuthorizeRequest struct {
+	PaymentID uint // our payment record, lets the gateway deduplicate retries
+	OrderID   uint
+	Amount    money.Money
+	Token     string // the payment method, as tokenized by the gateway's client
+}
+
+type RefundRequest struct {
+	RefundID  uint   // our refund record, lets the gateway deduplicate retries
+	Reference string // of the captured payment
+	Amount    money.Money
+}
+
+// Result is a successful call to the gateway
+type Result struct {
+	Reference string // the gateway's ID of the payment or refund
+}
+
+// DeclinedError is a payment or refund the gateway refused, as opposed to a
+// failure to reach it
+type DeclinedError struct {
+	Code    string
+	Message string
+}
+
+func (e DeclinedError) Error() string {
+	return fmt.Sprintf("payment declined: %s", e.Message)
+}
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+type EventType string
+
+const (
+	EventAuthorized EventType = "payment.authorized"
+	EventCaptured   EventType = "payment.captured"
+	EventVoided     EventType = "payment.voided"
+	EventFailed     EventType = "payment.failed"
+	EventRefunded   EventType = "payment.refunded"
+)
+
+// WebhookEvent is a change to a payment reported by the gateway
+type WebhookEvent struct {
+	ID            string      `json:"id"` // unique per event, retries repeat it
+	Type          EventType   `json:"type"`
+	Reference     string      `json:"reference"` // of the payment
+	Amount        money.Money `json:"amount"`
+	FailureReason string      `json:"failure_reason,omitempty"`
+}
@@ -0,0 +1,180 @@
+package services
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+)
+
+var (
+	ErrCategoryCycle         = errors.New("a category cannot be moved below itself or one of its descendants")
+	ErrCategoryParentMissing = errors.New("parent category not found")
+)
+
+// DescendantCategoriesSQL selects the id of a category (the single bind
+// parameter) and of every category below it
+const DescendantCategoriesSQL = `WITH RECURSIVE subtree AS (
+	SELECT id FROM categories WHERE id = ?
+	UNION ALL
+	SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+) SELECT id FROM subtree`
+
+// CategoryNode is a category in the category tree
+type CategoryNode struct {
+	ID                 uint            `json:"id"`
+	Name               string          `json:"name"`
+	Slug               string          `json:"slug"`
+	Description        string          `json:"description"`
+	ParentID           *uint           `json:"parent_id"`
+	DirectProductCount int             `json:"direct_product_count"` // products assigned to this category
+	ProductCount       int             `json:"product_count"`        // distinct products here or in any descendant
+	Children           []*CategoryNode `json:"children"`
+}
+
+// CategoryTree returns the root categories with their nested children and
+// product counts. Only active, non-deleted products are counted.
+func CategoryTree() ([]*CategoryNode, error) {
+	var categories []models.Category
+	if err := initializers.DB.Order("name ASC, id ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	var assignments []struct {
+		CategoryID uint
+		ProductID  uint
+	}
+	err := initializers.DB.Table("product_categories").
+		Select("product_categories.category_id, product_categories.product_id").
+		Joins("JOIN products ON products.id = product_categories.product_id").
+		Where("products.is_active = ? AND products.deleted_at IS NULL", true).
+		Scan(&assignments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	products := make(map[uint][]uint)
+	for _, a := range assignments {
+		products[a.CategoryID] = append(products[a.CategoryID], a.ProductID)
+	}
+
+	nodes := make(map[uint]*CategoryNode, len(categories))
+	for _, category := range categories {
+		nodes[category.ID] = &CategoryNode{
+			ID:                 category.ID,
+			Name:               category.Name,
+			Slug:               category.Slug,
+			Description:        category.Description,
+			ParentID:           category.ParentID,
+			DirectProductCount: len(products[category.ID]),
+			Children:           []*CategoryNode{},
+		}
+	}
+
+	roots := []*CategoryNode{}
+	for _, category := range categories {
+		node := nodes[category.ID]
+		if category.ParentID != nil {
+			if parent, ok := nodes[*category.ParentID]; ok {
+				parent.Children = append(parent.Children, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+
+	for _, root := range roots {
+		countSubtree(root, products)
+	}
+
+	return roots, nil
+}
+
+// countSubtree fills ProductCount and returns the distinct product IDs of the subtree
+func countSubtree(node *CategoryNode, products map[uint][]uint) map[uint]bool {
+	seen := make(map[uint]bool)
+	for _, id := range products[node.ID] {
+		seen[id] = true
+	}
+	for _, child := range node.Children {
+		for id := range countSubtree(child, products) {
+			seen[id] = true
+		}
+	}
+	node.ProductCount = len(seen)
+	return seen
+}
+
+// ValidateCategoryParent checks that parentID exists and that making it the
+// parent of categoryID (0 for a new category) doesn't create a cycle
+func ValidateCategoryParent(categoryID uint, parentID uint) error {
+	var ancestorIDs []uint
+	err := initializers.DB.Raw(`WITH RECURSIVE ancestors AS (
+		SELECT id, parent_id FROM categories WHERE id = ?
+		UNION ALL
+		SELECT categories.id, categories.parent_id FROM categories JOIN ancestors ON categories.id = ancestors.parent_id
+	) SELECT id FROM ancestors`, parentID).Scan(&ancestorIDs).Error
+	if err != nil {
+		return err
+	}
+
+	if len(ancestorIDs) == 0 {
+		return ErrCategoryParentMissing
+	}
+
+	for _, id := range ancestorIDs {
+		if id == categoryID {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+// Slugify turns a category name into a URL friendly slug. Letters and digits
+// of any script are kept; a name without any gives an empty slug, which
+// callers must reject.
+func Slugify(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// LoadCategories fetches the categories with the given IDs and reports the
+// IDs that don't exist
+func LoadCategories(ids []uint) ([]models.Category, []uint, error) {
+	if len(ids) == 0 {
+		return []models.Category{}, nil, nil
+	}
+
+	var categories []models.Category
+	if err := initializers.DB.Where("id IN ?", ids).Find(&categories).Error; err != nil {
+		return nil, nil, err
+	}
+
+	found := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		found[category.ID] = true
+	}
+
+	var missing []uint
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	sort.Slice(missing, func(i, j int) bool { return missing[i] < missing[j] })
+
+	return categories, missing, nil
+}
@@ -0,0 +1,25 @@
+package services
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Men's Shoes", "men-s-shoes"},
+		{"  Home & Garden  ", "home-garden"},
+		{"T-Shirts", "t-shirts"},
+		{"4K TVs!", "4k-tvs"},
+		{"Café", "café"},
+		{"日本", "日本"},
+		{"—", ""},
+		{"!!!", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.name); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CouponError reports a coupon code that can't be applied to an order
+type CouponError struct {
+	Code   string
+	Reason string
+}
+
+func (e CouponError) Error() string {
+	return fmt.Sprintf("coupon %s %s", e.Code, e.Reason)
+}
+
+// couponProductsSQL selects which of the given products a coupon applies to:
+// its products and the products in its categories or their subcategories
+const couponProductsSQL = `WITH RECURSIVE subtree AS (
+	SELECT category_id AS id FROM coupon_categories WHERE coupon_id = @coupon
+	UNION ALL
+	SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+)
+SELECT product_id FROM coupon_products WHERE coupon_id = @coupon AND product_id IN @products
+UNION
+SELECT product_id FROM product_categories WHERE category_id IN (SELECT id FROM subtree) AND product_id IN @products`
+
+// NormalizeCouponCode is the form codes are stored and looked up in
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// AppliedCoupons is the outcome of applying coupon codes to an order
+type AppliedCoupons struct {
+	Coupons      []models.Coupon
+	Discounts    []models.OrderDiscount // one per coupon, OrderID still unset
+	FreeShipping bool
+}
+
+// ApplyCoupons checks the codes for the user and spreads their discounts over
+// the priced items, setting each item's Discount. Coupons apply in the order
+// given, each on what the previous ones left, so no line goes below zero.
+//
+// The coupon rows are locked until the caller's transaction ends, so usage
+// limits hold when the same coupon is redeemed concurrently.
+func ApplyCoupons(tx *gorm.DB, userID uint, codes []string, items []models.OrderItem, pricer Pricer) (AppliedCoupons, error) {
+	if len(codes) == 0 {
+		return discountItems(tx, nil, items, pricer, true)
+	}
+
+	coupons, err := lockCoupons(tx, codes)
+	if err != nil {
+		return AppliedCoupons{}, err
+	}
+
+	subtotal, err := itemsSubtotal(items, pricer.Currency)
+	if err != nil {
+		return AppliedCoupons{}, err
+	}
+
+	now := time.Now()
+	for _, coupon := range coupons {
+		if err := checkCoupon(tx, coupon, userID, len(coupons), subtotal, pricer, now); err != nil {
+			return AppliedCoupons{}, err
+		}
+	}
+
+	return discountItems(tx, coupons, items, pricer, true)
+}
+
+// reapplyOrderCoupons spreads the discounts of the coupons an order already
+// redeemed over its changed items, setting each item's Discount, and returns
+// the order's discounts with their new amounts. The coupons were checked and
+// counted when the order was placed; with strict set their minimum order
+// value is checked again and a coupon that no longer applies to any item is
+// an error, otherwise it just discounts nothing.
+func reapplyOrderCoupons(tx *gorm.DB, orderID uint, items []models.OrderItem, pricer Pricer, strict bool) ([]models.OrderDiscount, error) {
+	var discounts []models.OrderDiscount
+	if err := tx.Where("order_id = ?", orderID).Order("id ASC").Find(&discounts).Error; err != nil {
+		return nil, err
+	}
+
+	var coupons []models.Coupon
+	if len(discounts) > 0 {
+		ids := make([]uint, 0, len(discounts))
+		for _, discount := range discounts {
+			ids = append(ids, discount.CouponID)
+		}
+
+		// deleted coupons included, the order keeps what it was given
+		var found []models.Coupon
+		if err := tx.Preload("Products").Preload("Categories").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.Coupon, len(found))
+		for _, coupon := range found {
+			byID[coupon.ID] = coupon
+		}
+		for _, discount := range discounts {
+			coupon, ok := byID[discount.CouponID]
+			if !ok {
+				return nil, fmt.Errorf("coupon %d of order %d not found", discount.CouponID, orderID)
+			}
+			coupons = append(coupons, coupon)
+		}
+	}
+
+	if strict {
+		subtotal, err := itemsSubtotal(items, pricer.Currency)
+		if err != nil {
+			return nil, err
+		}
+		for _, coupon := range coupons {
+			if !coupon.MinOrderValue.IsSet() {
+				continue
+			}
+			minimum := pricer.Convert(coupon.MinOrderValue)
+			cmp, err := subtotal.Cmp(minimum)
+			if err != nil {
+				return nil, err
+			}
+			if cmp < 0 {
+				return nil, CouponError{Code: coupon.Code, Reason: fmt.Sprintf("requires an order of at least %s %s", minimum, minimum.Currency)}
+			}
+		}
+	}
+
+	applied, err := discountItems(tx, coupons, items, pricer, strict)
+	if err != nil {
+		return nil, err
+	}
+	for i := range discounts {
+		discounts[i].Amount = applied.Discounts[i].Amount
+	}
+	return discounts, nil
+}
+
+// discountItems applies the coupons in order to the items. With strict set a
+// restricted coupon that applies to none of the items is an error.
+func discountItems(tx *gorm.DB, coupons []models.Coupon, items []models.OrderItem, pricer Pricer, strict bool) (AppliedCoupons, error) {
+	var applied AppliedCoupons
+	for i := range items {
+		items[i].Discount = money.New(0, pricer.Currency)
+	}
+	if len(coupons) == 0 {
+		return applied, nil
+	}
+
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	for _, coupon := range coupons {
+		eligible, err := couponEligibleProducts(tx, coupon, productIDs)
+		if err != nil {
+			return applied, err
+		}
+
+		// what is left to discount on each eligible line
+		remaining := make([]int64, len(items))
+		var eligibleTotal int64
+		for i, item := range items {
+			if eligible == nil || eligible[item.ProductID] {
+				remaining[i] = item.Price.Mul(item.Quantity).Amount - item.Discount.Amount
+				eligibleTotal += remaining[i]
+			}
+		}
+		if strict && eligible != nil && eligibleTotal == 0 {
+			return applied, CouponError{Code: coupon.Code, Reason: "does not apply to any item in the order"}
+		}
+
+		var shares []int64
+		switch coupon.Type {
+		case models.CouponPercentage:
+			shares = make([]int64, len(items))
+			for i, amount := range remaining {
+				shares[i] = mulDiv(amount, int64(coupon.Percentage), 100)
+			}
+		case models.CouponFixedAmount:
+			amount := pricer.Convert(coupon.AmountOff).Amount
+			if amount > eligibleTotal {
+				amount = eligibleTotal
+			}
+			shares = allocate(amount, remaining)
+		case models.CouponFreeShipping:
+			applied.FreeShipping = true
+			shares = make([]int64, len(items))
+		}
+
+		discount := models.OrderDiscount{
+			CouponID: coupon.ID,
+			Code:     coupon.Code,
+			Type:     coupon.Type,
+			Amount:   money.New(0, pricer.Currency),
+		}
+		for i, share := range shares {
+			items[i].Discount.Amount += share
+			discount.Amount.Amount += share
+		}
+
+		applied.Coupons = append(applied.Coupons, coupon)
+		applied.Discounts = append(applied.Discounts, discount)
+	}
+
+	return applied, nil
+}
+
+// itemsSubtotal is what the items cost before discounts
+func itemsSubtotal(items []models.OrderItem, currency string) (money.Money, error) {
+	subtotal := money.New(0, currency)
+	for _, item := range items {
+		var err error
+		if subtotal, err = subtotal.Add(item.Price.Mul(item.Quantity)); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return subtotal, nil
+}
+
+// RecordCouponRedemptions stores the applied discounts on the order and
+// counts the coupon uses towards their limits
+func RecordCouponRedemptions(tx *gorm.DB, applied AppliedCoupons, userID uint, orderID uint) error {
+	for i, coupon := range applied.Coupons {
+		discount := applied.Discounts[i]
+		discount.OrderID = orderID
+		if err := tx.Create(&discount).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Create(&models.CouponRedemption{CouponID: coupon.ID, UserID: userID, OrderID: orderID}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Coupon{}).Where("id = ?", coupon.ID).
+			Update("times_used", gorm.Expr("times_used + 1")).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lockCoupons loads and row-locks the coupons for the codes, in the order the
+// codes were given
+func lockCoupons(tx *gorm.DB, codes []string) ([]models.Coupon, error) {
+	normalized := make([]string, 0, len(codes))
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		code = NormalizeCouponCode(code)
+		if seen[code] {
+			return nil, CouponError{Code: code, Reason: "is applied more than once"}
+		}
+		seen[code] = true
+		normalized = append(normalized, code)
+	}
+
+	var ids []uint
+	if err := tx.Model(&models.Coupon{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("code IN ? AND deleted_at IS NULL", normalized).
+		Order("id ASC").
+		Pluck("id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	var found []models.Coupon
+	if len(ids) > 0 {
+		if err := tx.Preload("Products").Preload("Categories").Where("id IN ?", ids).Find(&found).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	byCode := make(map[string]models.Coupon, len(found))
+	for _, coupon := range found {
+		byCode[coupon.Code] = coupon
+	}
+
+	coupons := make([]models.Coupon, 0, len(normalized))
+	for _, code := range normalized {
+		coupon, ok := byCode[code]
+		if !ok {
+			return nil, CouponError{Code: code, Reason: "does not exist"}
+		}
+		coupons = append(coupons, coupon)
+	}
+	return coupons, nil
+}
+
+func checkCoupon(tx *gorm.DB, coupon models.Coupon, userID uint, couponCount int, subtotal money.Money, pricer Pricer, now time.Time) error {
+	switch {
+	case !coupon.IsActive:
+		return CouponError{Code: coupon.Code, Reason: "is not active"}
+	case coupon.StartsAt != nil && now.Before(*coupon.StartsAt):
+		return CouponError{Code: coupon.Code, Reason: "is not valid yet"}
+	case coupon.EndsAt != nil && now.After(*coupon.EndsAt):
+		return CouponError{Code: coupon.Code, Reason: "has expired"}
+	case coupon.UsageLimit != nil && coupon.TimesUsed >= *coupon.UsageLimit:
+		return CouponError{Code: coupon.Code, Reason: "has reached its usage limit"}
+	case couponCount > 1 && !coupon.Stackable:
+		return CouponError{Code: coupon.Code, Reason: "cannot be combined with other coupons"}
+	}
+
+	if coupon.PerUserLimit != nil {
+		var used int64
+		if err := tx.Model(&models.CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ?", coupon.ID, userID).
+			Count(&used).Error; err != nil {
+			return err
+		}
+		if used >= int64(*coupon.PerUserLimit) {
+			return CouponError{Code: coupon.Code, Reason: "has already been used the maximum number of times"}
+		}
+	}
+
+	if coupon.MinOrderValue.IsSet() {
+		minimum := pricer.Convert(coupon.MinOrderValue)
+		cmp, err := subtotal.Cmp(minimum)
+		if err != nil {
+			return err
+		}
+		if cmp < 0 {
+			return CouponError{Code: coupon.Code, Reason: fmt.Sprintf("requires an order of at least %s %s", minimum, minimum.Currency)}
+		}
+	}
+
+	return nil
+}
+
+// couponEligibleProducts returns which of the products a restricted coupon
+// applies to, or nil when it applies to everything
+func couponEligibleProducts(tx *gorm.DB, coupon models.Coupon, productIDs []uint) (map[uint]bool, error) {
+	if !coupon.IsRestricted() {
+		return nil, nil
+	}
+
+	var ids []uint
+	if err := tx.Raw(couponProductsSQL, map[string]interface{}{
+		"coupon":   coupon.ID,
+		"products": productIDs,
+	}).Scan(&ids).Error; err != nil {
+		return nil, err
+	}
+
+	eligible := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		eligible[id] = true
+	}
+	return eligible, nil
+}
+
+// allocate splits amount over the weights proportionally, in whole minor
+// units; the units lost to rounding go to the first lines with room left
+func allocate(amount int64, weights []int64) []int64 {
+	shares := make([]int64, len(weights))
+	var total int64
+	for _, weight := range weights {
+		total += weight
+	}
+	if total == 0 {
+		return shares
+	}
+
+	left := amount
+	for i, weight := range weights {
+		shares[i] = new(big.Int).Div(
+			new(big.Int).Mul(big.NewInt(amount), big.NewInt(weight)),
+			big.NewInt(total),
+		).Int64()
+		left -= shares[i]
+	}
+	for i := 0; left > 0 && i < len(weights); i++ {
+		if shares[i] < weights[i] {
+			shares[i]++
+			left--
+		}
+	}
+	return shares
+}
+
+// mulDiv returns a*b/c rounded half up, for non-negative values
+func mulDiv(a, b, c int64) int64 {
+	product := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	product.Add(product, big.NewInt(c/2))
+	return product.Div(product, big.NewInt(c)).Int64()
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+var usdPricer = Pricer{Currency: "USD", Rate: big.NewRat(1, 1)}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		amount  int64
+		weights []int64
+		want    []int64
+	}{
+		{100, []int64{100, 100, 100}, []int64{34, 33, 33}},
+		{10, []int64{3, 7}, []int64{3, 7}},
+		{1000, []int64{1999, 1500}, []int64{572, 428}},
+		{2, []int64{1, 100}, []int64{1, 1}}, // the remainder goes to the first line with room
+		{3, []int64{1, 1, 1}, []int64{1, 1, 1}},
+		{5, []int64{0, 10}, []int64{0, 5}},
+		{0, []int64{5, 5}, []int64{0, 0}},
+		{7, []int64{0, 0}, []int64{0, 0}},
+		{7, nil, []int64{}},
+	}
+	for _, tt := range tests {
+		got := allocate(tt.amount, tt.weights)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("allocate(%d, %v) = %v, want %v", tt.amount, tt.weights, got, tt.want)
+		}
+	}
+}
+
+func TestAllocateSumsToAmount(t *testing.T) {
+	weights := []int64{333, 1, 17, 2500, 99, 4}
+	var total int64
+	for _, weight := range weights {
+		total += weight
+	}
+
+	for amount := int64(0); amount <= total; amount++ {
+		var sum int64
+		for i, share := range allocate(amount, weights) {
+			if share < 0 || share > weights[i] {
+				t.Fatalf("allocate(%d) gives line %d %d of %d", amount, i, share, weights[i])
+			}
+			sum += share
+		}
+		if sum != amount {
+			t.Fatalf("allocate(%d) shares add up to %d", amount, sum)
+		}
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		a, b, c int64
+		want    int64
+	}{
+		{5, 1, 2, 3},         // 2.5 rounds up
+		{4, 1, 3, 1},         // 1.33
+		{5, 1, 3, 2},         // 1.67
+		{1999, 15, 100, 300}, // 299.85
+		{1999, 10, 100, 200}, // 199.9
+		{1000, 1, 8, 125},    // exact
+		{0, 7, 3, 0},
+		{1 << 40, 1 << 40, 1 << 40, 1 << 40}, // the product overflows int64
+	}
+	for _, tt := range tests {
+		if got := mulDiv(tt.a, tt.b, tt.c); got != tt.want {
+			t.Errorf("mulDiv(%d, %d, %d) = %d, want %d", tt.a, tt.b, tt.c, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeCouponCode(t *testing.T) {
+	if got := NormalizeCouponCode("  summer10 "); got != "SUMMER10" {
+		t.Errorf("NormalizeCouponCode = %q", got)
+	}
+}
+
+func testOrderItems() []models.OrderItem {
+	return []models.OrderItem{
+		{ProductID: 1, Quantity: 1, Price: money.New(1999, "USD")},
+		{ProductID: 2, Quantity: 3, Price: money.New(500, "USD")},
+	}
+}
+
+func itemDiscounts(items []models.OrderItem) []int64 {
+	discounts := make([]int64, len(items))
+	for i, item := range items {
+		discounts[i] = item.Discount.Amount
+	}
+	return discounts
+}
+
+// discountItems only needs the database for coupons restricted to some
+// products, so unrestricted ones are applied without one
+func TestDiscountItems(t *testing.T) {
+	percent := models.Coupon{ID: 1, Code: "TEN", Type: models.CouponPercentage, Percentage: 10}
+	fixed := models.Coupon{ID: 2, Code: "OFF10", Type: models.CouponFixedAmount, AmountOff: money.New(1000, "USD")}
+	huge := models.Coupon{ID: 3, Code: "HUGE", Type: models.CouponFixedAmount, AmountOff: money.New(1_000_000, "USD")}
+	shipping := models.Coupon{ID: 4, Code: "SHIP", Type: models.CouponFreeShipping}
+
+	tests := []struct {
+		name         string
+		coupons      []models.Coupon
+		items        []int64 // discount of each item
+		discounts    []int64 // amount of each coupon
+		freeShipping bool
+	}{
+		{"none", nil, []int64{0, 0}, nil, false},
+		{"percentage per line", []models.Coupon{percent}, []int64{200, 150}, []int64{350}, false},
+		{"fixed spread by line value", []models.Coupon{fixed}, []int64{572, 428}, []int64{1000}, false},
+		// the fixed amount spreads over what the percentage left
+		{"stacked", []models.Coupon{percent, fixed}, []int64{200 + 572, 150 + 428}, []int64{350, 1000}, false},
+		{"capped at the order", []models.Coupon{huge}, []int64{1999, 1500}, []int64{3499}, false},
+		{"free shipping", []models.Coupon{shipping}, []int64{0, 0}, []int64{0}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			items := testOrderItems()
+			applied, err := discountItems(nil, tt.coupons, items, usdPricer, true)
+			if err != nil {
+				t.Fatalf("discountItems: %v", err)
+			}
+			if got := itemDiscounts(items); !reflect.DeepEqual(got, tt.items) {
+				t.Errorf("item discounts = %v, want %v", got, tt.items)
+			}
+			var discounts []int64
+			for _, discount := range applied.Discounts {
+				discounts = append(discounts, discount.Amount.Amount)
+			}
+			if !reflect.DeepEqual(discounts, tt.discounts) {
+				t.Errorf("coupon discounts = %v, want %v", discounts, tt.discounts)
+			}
+			if applied.FreeShipping != tt.freeShipping {
+				t.Errorf("free shipping = %v, want %v", applied.FreeShipping, tt.freeShipping)
+			}
+		})
+	}
+}
+
+// checkCoupon only needs the database for per-user limits
+func TestCheckCoupon(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	before, after := now.Add(-time.Hour), now.Add(time.Hour)
+	limit := 5
+	subtotal := money.New(5000, "USD")
+
+	valid := models.Coupon{Code: "OK", IsActive: true, Type: models.CouponPercentage, Percentage: 10}
+	with := func(change func(*models.Coupon)) models.Coupon {
+		coupon := valid
+		change(&coupon)
+		return coupon
+	}
+
+	tests := []struct {
+		name    string
+		coupon  models.Coupon
+		count   int
+		wantErr string
+	}{
+		{"valid", valid, 1, ""},
+		{"inactive", with(func(c *models.Coupon) { c.IsActive = false }), 1, "coupon OK is not active"},
+		{"not started", with(func(c *models.Coupon) { c.StartsAt = &after }), 1, "coupon OK is not valid yet"},
+		{"ended", with(func(c *models.Coupon) { c.EndsAt = &before }), 1, "coupon OK has expired"},
+		{"in its window", with(func(c *models.Coupon) { c.StartsAt, c.EndsAt = &before, &after }), 1, ""},
+		{"used up", with(func(c *models.Coupon) { c.UsageLimit, c.TimesUsed = &limit, 5 }), 1, "coupon OK has reached its usage limit"},
+		{"uses left", with(func(c *models.Coupon) { c.UsageLimit, c.TimesUsed = &limit, 4 }), 1, ""},
+		{"not stackable", valid, 2, "coupon OK cannot be combined with other coupons"},
+		{"stackable", with(func(c *models.Coupon) { c.Stackable = true }), 2, ""},
+		{"below the minimum", with(func(c *models.Coupon) { c.MinOrderValue = money.New(5001, "USD") }), 1, "coupon OK requires an order of at least 50.01 USD"},
+		{"at the minimum", with(func(c *models.Coupon) { c.MinOrderValue = money.New(5000, "USD") }), 1, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkCoupon(nil, tt.coupon, 1, tt.count, subtotal, usdPricer, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+				return
+			}
+			if _, ok := err.(CouponError); !ok || err.Error() != tt.wantErr {
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// PriceBucketBounds are the lower bounds of the price facet buckets in whole
+// units of the base currency; the last bucket has no upper bound. Products are
+// bucketed on their own base price, the column the min_price/max_price filters
+// use, so a bucket's count is what filtering on its bounds returns. Prices
+// shown in another currency, and variant prices, may fall elsewhere.
+var PriceBucketBounds = []int64{0, 25, 50, 100, 250, 500}
+
+// productInStockSQL mirrors the catalog's in_stock flag: a product with
+// variants is in stock when any active variant is, otherwise its own stock counts
+const productInStockSQL = `CASE
+	WHEN EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.is_active AND pv.deleted_at IS NULL)
+	THEN EXISTS (SELECT 1 FROM product_variants pv WHERE pv.product_id = products.id AND pv.is_active AND pv.deleted_at IS NULL AND pv.stock > 0)
+	ELSE products.stock > 0
+END`
+
+type CategoryFacet struct {
+	ID       uint   `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	ParentID *uint  `json:"parent_id"`
+	Count    int64  `json:"count"`
+}
+
+// PriceBucketFacet bounds are always in the base currency
+type PriceBucketFacet struct {
+	Min   money.Money  `json:"min"`
+	Max   *money.Money `json:"max"` // null for the open ended last bucket
+	Count int64        `json:"count"`
+}
+
+type AvailabilityFacet struct {
+	InStock    int64 `json:"in_stock"`
+	OutOfStock int64 `json:"out_of_stock"`
+}
+
+type AttributeValueFacet struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// ProductFacets are product counts over a filtered listing. Attribute counts
+// are products with at least one active variant carrying the value.
+type ProductFacets struct {
+	Categories   []CategoryFacet                  `json:"categories"`
+	PriceRanges  []PriceBucketFacet               `json:"price_ranges"`
+	Availability AvailabilityFacet                `json:"availability"`
+	Attributes   map[string][]AttributeValueFacet `json:"attributes"`
+}
+
+// ComputeProductFacets counts the products matched by filtered, a product
+// query carrying only WHERE conditions (no order, limit or preloads)
+func ComputeProductFacets(filtered *gorm.DB) (*ProductFacets, error) {
+	ids := filtered.Session(&gorm.Session{}).Select("products.id")
+	facets := &ProductFacets{Attributes: map[string][]AttributeValueFacet{}}
+
+	// categories, most products first
+	if err := initializers.DB.Table("categories").
+		Select("categories.id, categories.name, categories.slug, categories.parent_id, COUNT(*) AS count").
+		Joins("JOIN product_categories ON product_categories.category_id = categories.id").
+		Where("product_categories.product_id IN (?)", ids).
+		Group("categories.id").
+		Order("count DESC, categories.name ASC").
+		Scan(&facets.Categories).Error; err != nil {
+		return nil, err
+	}
+	if facets.Categories == nil {
+		facets.Categories = []CategoryFacet{}
+	}
+
+	// price buckets and availability in a single pass
+	columns := []string{
+		"COUNT(*) FILTER (WHERE " + productInStockSQL + ")",
+		"COUNT(*) FILTER (WHERE NOT (" + productInStockSQL + "))",
+	}
+	currency := money.BaseCurrency()
+	bound := func(i int) money.Money {
+		return money.New(PriceBucketBounds[i]*money.MinorFactor(currency), currency)
+	}
+	var args []interface{}
+	for i := range PriceBucketBounds {
+		if i == len(PriceBucketBounds)-1 {
+			columns = append(columns, "COUNT(*) FILTER (WHERE products.price_minor >= ?)")
+			args = append(args, bound(i).Amount)
+		} else {
+			columns = append(columns, "COUNT(*) FILTER (WHERE products.price_minor >= ? AND products.price_minor < ?)")
+			args = append(args, bound(i).Amount, bound(i+1).Amount)
+		}
+	}
+
+	counts := make([]int64, len(columns))
+	dest := make([]interface{}, len(counts))
+	for i := range counts {
+		dest[i] = &counts[i]
+	}
+	row := initializers.DB.Table("products").
+		Select(strings.Join(columns, ", "), args...).
+		Where("products.id IN (?)", ids).
+		Row()
+	if err := row.Scan(dest...); err != nil {
+		return nil, fmt.Errorf("failed to count price and stock facets: %w", err)
+	}
+
+	facets.Availability = AvailabilityFacet{InStock: counts[0], OutOfStock: counts[1]}
+	for i := range PriceBucketBounds {
+		bucket := PriceBucketFacet{Min: bound(i), Count: counts[i+2]}
+		if i < len(PriceBucketBounds)-1 {
+			upper := bound(i + 1)
+			bucket.Max = &upper
+		}
+		facets.PriceRanges = append(facets.PriceRanges, bucket)
+	}
+
+	// variant attributes, counting each product once per value
+	var attributes []struct {
+		Key   string
+		Value string
+		Count int64
+	}
+	if err := initializers.DB.Table("product_variants").
+		Select("attribute.key, attribute.value, COUNT(DISTINCT product_variants.product_id) AS count").
+		Joins("CROSS JOIN LATERAL jsonb_each_text(product_variants.attributes) AS attribute").
+		Where("product_variants.is_active AND product_variants.deleted_at IS NULL").
+		Where("product_variants.product_id IN (?)", ids).
+		Group("attribute.key, attribute.value").
+		Scan(&attributes).Error; err != nil {
+		return nil, err
+	}
+	for _, attribute := range attributes {
+		facets.Attributes[attribute.Key] = append(facets.Attributes[attribute.Key], AttributeValueFacet{
+			Value: attribute.Value,
+			Count: attribute.Count,
+		})
+	}
+	for _, values := range facets.Attributes {
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Count != values[j].Count {
+				return values[i].Count > values[j].Count
+			}
+			return values[i].Value < values[j].Value
+		})
+	}
+
+	return facets, nil
+}
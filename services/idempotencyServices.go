@@ -0,0 +1,102 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrIdempotencyKeyMismatch   = errors.New("idempotency key was already used with a different request")
+	ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is still being processed")
+)
+
+const defaultIdempotencyKeyTTL = 24 * time.Hour
+
+// IdempotencyKeyTTL reads IDEMPOTENCY_KEY_TTL (e.g. "24h"), falling back to the default
+func IdempotencyKeyTTL() time.Duration {
+	return durationFromEnv("IDEMPOTENCY_KEY_TTL", defaultIdempotencyKeyTTL)
+}
+
+// RequestFingerprint hashes the parts of a request that must match on a retry
+func RequestFingerprint(method string, path string, body []byte) string {
+	hash := sha256.New()
+	hash.Write([]byte(method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(path))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ReserveIdempotencyKey claims the key for the user. It returns (nil, nil) when
+// the caller should process the request, or the stored record when a completed
+// response can be replayed. A key reused with another fingerprint returns
+// ErrIdempotencyKeyMismatch, one whose first request hasn't finished returns
+// ErrIdempotencyKeyInProgress.
+func ReserveIdempotencyKey(userID uint, key string, fingerprint string) (*models.IdempotencyKey, error) {
+	now := time.Now()
+
+	// Expired keys of this user are dropped so they can be reused
+	if err := initializers.DB.Where("user_id = ? AND expires_at < ?", userID, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, err
+	}
+
+	record := models.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: fingerprint,
+		ExpiresAt:   now.Add(IdempotencyKeyTTL()),
+	}
+
+	result := initializers.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := initializers.DB.Where("user_id = ? AND key = ?", userID, key).First(&existing).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			// released by a failed first attempt in the meantime
+			return ReserveIdempotencyKey(userID, key, fingerprint)
+		}
+		return nil, err
+	}
+
+	if existing.RequestHash != fingerprint {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+	if existing.CompletedAt == nil {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey stores the response of the first request
+func CompleteIdempotencyKey(userID uint, key string, statusCode int, contentType string, body []byte) error {
+	return initializers.DB.Model(&models.IdempotencyKey{}).
+		Where("user_id = ? AND key = ?", userID, key).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+			"completed_at":  time.Now(),
+		}).Error
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed, so it can be retried
+func ReleaseIdempotencyKey(userID uint, key string) error {
+	return initializers.DB.Where("user_id = ? AND key = ?", userID, key).
+		Delete(&models.IdempotencyKey{}).Error
+}
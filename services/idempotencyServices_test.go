@@ -0,0 +1,85 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRequestFingerprint(t *testing.T) {
+	base := RequestFingerprint("POST", "/orders", []byte(`{"a":1}`))
+	if len(base) != 64 {
+		t.Fatalf("fingerprint %q, want a hex SHA-256", base)
+	}
+	if again := RequestFingerprint("POST", "/orders", []byte(`{"a":1}`)); again != base {
+		t.Errorf("the same request gave %q and %q", base, again)
+	}
+
+	others := []struct {
+		method, path, body string
+	}{
+		{"PUT", "/orders", `{"a":1}`},
+		{"POST", "/orders/", `{"a":1}`},
+		{"POST", "/orders", `{"a":2}`},
+		{"POST", "/orders", ``},
+		// the parts are separated, so moving bytes between them changes it
+		{"POST", "/orders{", `"a":1}`},
+		{"POST/", "orders", `{"a":1}`},
+	}
+	for _, other := range others {
+		if got := RequestFingerprint(other.method, other.path, []byte(other.body)); got == base {
+			t.Errorf("%s %s %s has the same fingerprint", other.method, other.path, other.body)
+		}
+	}
+}
+
+func TestIdempotencyKeyLifecycle(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t)
+	key := fmt.Sprintf("key-%d", time.Now().UnixNano())
+	fingerprint := RequestFingerprint("POST", "/orders", []byte(`{}`))
+
+	if stored, err := ReserveIdempotencyKey(user.ID, key, fingerprint); err != nil || stored != nil {
+		t.Fatalf("first reservation = %v, %v, want to process the request", stored, err)
+	}
+	if _, err := ReserveIdempotencyKey(user.ID, key, fingerprint); !errors.Is(err, ErrIdempotencyKeyInProgress) {
+		t.Errorf("retry while processing: error = %v, want ErrIdempotencyKeyInProgress", err)
+	}
+	other := RequestFingerprint("POST", "/orders", []byte(`{"other":true}`))
+	if _, err := ReserveIdempotencyKey(user.ID, key, other); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Errorf("other request: error = %v, want ErrIdempotencyKeyMismatch", err)
+	}
+
+	if err := CompleteIdempotencyKey(user.ID, key, 201, "application/json", []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("CompleteIdempotencyKey: %v", err)
+	}
+	stored, err := ReserveIdempotencyKey(user.ID, key, fingerprint)
+	if err != nil || stored == nil {
+		t.Fatalf("retry after completion = %v, %v, want the stored response", stored, err)
+	}
+	if stored.StatusCode != 201 || stored.ContentType != "application/json" || string(stored.ResponseBody) != `{"id":1}` {
+		t.Errorf("stored response = %d %q %q", stored.StatusCode, stored.ContentType, stored.ResponseBody)
+	}
+	if _, err := ReserveIdempotencyKey(user.ID, key, other); !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Errorf("other request after completion: error = %v, want ErrIdempotencyKeyMismatch", err)
+	}
+
+	// keys belong to their user
+	otherUser := createTestUser(t)
+	if stored, err := ReserveIdempotencyKey(otherUser.ID, key, other); err != nil || stored != nil {
+		t.Errorf("another user's reservation = %v, %v, want to process the request", stored, err)
+	}
+
+	// a released key can be used again
+	released := key + "-released"
+	if _, err := ReserveIdempotencyKey(user.ID, released, fingerprint); err != nil {
+		t.Fatalf("ReserveIdempotencyKey: %v", err)
+	}
+	if err := ReleaseIdempotencyKey(user.ID, released); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey: %v", err)
+	}
+	if stored, err := ReserveIdempotencyKey(user.ID, released, other); err != nil || stored != nil {
+		t.Errorf("reservation after release = %v, %v, want to process the request", stored, err)
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif" // registers the gif decoder
+	"image/jpeg"
+	"image/png"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedImageType = errors.New("unsupported image type, allowed types are jpeg, png and gif")
+	ErrInvalidImage         = errors.New("the file is not a valid image")
+)
+
+const (
+	defaultMaxImageUploadSize = 5 << 20 // 5 MiB
+	maxImagePixels            = 40_000_000
+)
+
+// ThumbnailSizes are generated for every upload, scaled to fit within MaxSide
+var ThumbnailSizes = []struct {
+	Name    string
+	MaxSide int
+}{
+	{Name: "small", MaxSide: 150},
+	{Name: "medium", MaxSide: 400},
+	{Name: "large", MaxSide: 800},
+}
+
+// allowedImageTypes maps sniffed content types to file extensions
+var allowedImageTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// MaxImageUploadSize reads MAX_IMAGE_UPLOAD_SIZE (bytes), falling back to 5 MiB
+func MaxImageUploadSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MAX_IMAGE_UPLOAD_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultMaxImageUploadSize
+}
+
+// SaveProductImage validates and stores an uploaded image with its
+// thumbnails and appends it to the product's images. The first image of a
+// product always becomes primary.
+func SaveProductImage(productID uint, data []byte, makePrimary bool) (models.ProductImage, error) {
+	img, contentType, err := decodeUpload(data)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	ext := allowedImageTypes[contentType]
+
+	name, err := randomToken(16)
+	if err != nil {
+		return models.ProductImage{}, err
+	}
+	baseKey := fmt.Sprintf("products/%d/%s", productID, name)
+	ctx := context.Background()
+
+	var storedKeys []string
+	cleanup := func() {
+		for _, key := range storedKeys {
+			if err := initializers.Storage.Delete(ctx, key); err != nil {
+				log.Println("Failed to delete stored image", key, err)
+			}
+		}
+	}
+
+	record := models.ProductImage{
+		ProductID:   productID,
+		Key:         baseKey + "." + ext,
+		ContentType: contentType,
+		Size:        int64(len(data)),
+		Width:       img.Bounds().Dx(),
+		Height:      img.Bounds().Dy(),
+		Thumbnails:  models.ImageThumbnails{},
+	}
+
+	if err := initializers.Storage.Put(ctx, record.Key, data, contentType); err != nil {
+		return models.ProductImage{}, err
+	}
+	storedKeys = append(storedKeys, record.Key)
+	record.URL = initializers.Storage.URL(record.Key)
+
+	// converted once, every thumbnail is scaled from the same pixels
+	rgba := toRGBA(img)
+	for _, size := range ThumbnailSizes {
+		thumb := resizeToFit(rgba, size.MaxSide)
+
+		// jpeg stays jpeg, png and gif become png to keep transparency
+		var buf bytes.Buffer
+		thumbType, thumbExt := "image/png", "png"
+		if contentType == "image/jpeg" {
+			thumbType, thumbExt = "image/jpeg", "jpg"
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+		} else {
+			err = png.Encode(&buf, thumb)
+		}
+		if err != nil {
+			cleanup()
+			return models.ProductImage{}, err
+		}
+
+		key := fmt.Sprintf("%s_%s.%s", baseKey, size.Name, thumbExt)
+		if err := initializers.Storage.Put(ctx, key, buf.Bytes(), thumbType); err != nil {
+			cleanup()
+			return models.ProductImage{}, err
+		}
+		storedKeys = append(storedKeys, key)
+
+		record.Thumbnails[size.Name] = models.ImageVariant{
+			Key:    key,
+			URL:    initializers.Storage.URL(key),
+			Width:  thumb.Bounds().Dx(),
+			Height: thumb.Bounds().Dy(),
+		}
+	}
+
+	tx := initializers.DB.Begin()
+
+	var count int64
+	if err := tx.Model(&models.ProductImage{}).Where("product_id = ?", productID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		cleanup()
+		return models.ProductImage{}, err
+	}
+	record.Position = int(count)
+	record.IsPrimary = makePrimary || count == 0
+
+	if record.IsPrimary {
+		if err := tx.Model(&models.ProductImage{}).
+			Where("product_id = ?", productID).
+			Update("is_primary", false).Error; err != nil {
+			tx.Rollback()
+			cleanup()
+			return models.ProductImage{}, err
+		}
+	}
+
+	if err := tx.Create(&record).Error; err != nil {
+		tx.Rollback()
+		cleanup()
+		return models.ProductImage{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		cleanup()
+		return models.ProductImage{}, err
+	}
+
+	return record, nil
+}
+
+// DeleteProductImage removes the image record and its stored files. When the
+// primary image is removed, the next image in order becomes primary.
+func DeleteProductImage(image models.ProductImage) error {
+	tx := initializers.DB.Begin()
+
+	if err := tx.Delete(&image).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if image.IsPrimary {
+		var next models.ProductImage
+		err := tx.Where("product_id = ?", image.ProductID).Order("position ASC, id ASC").First(&next).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			tx.Rollback()
+			return err
+		}
+		if err == nil {
+			if err := tx.Model(&next).Update("is_primary", true).Error; err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	// Files are removed after the commit; a leftover file is harmless
+	ctx := context.Background()
+	keys := []string{image.Key}
+	for _, thumb := range image.Thumbnails {
+		keys = append(keys, thumb.Key)
+	}
+	for _, key := range keys {
+		if err := initializers.Storage.Delete(ctx, key); err != nil {
+			log.Println("Failed to delete stored image", key, err)
+		}
+	}
+	return nil
+}
+
+// decodeUpload decodes an uploaded image and returns it with its content type,
+// sniffed from the bytes rather than trusting the client supplied one
+func decodeUpload(data []byte) (image.Image, string, error) {
+	contentType := http.DetectContentType(data)
+	if _, ok := allowedImageTypes[contentType]; !ok {
+		return nil, "", ErrUnsupportedImageType
+	}
+
+	// Check the dimensions before decoding so a small file can't expand into
+	// a huge bitmap
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width*config.Height > maxImagePixels {
+		return nil, "", ErrInvalidImage
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", ErrInvalidImage
+	}
+	return img, contentType, nil
+}
+
+// toRGBA returns the image as RGBA pixels starting at the origin, copying
+// it only when it isn't already
+func toRGBA(src image.Image) *image.RGBA {
+	if rgba, ok := src.(*image.RGBA); ok && rgba.Bounds().Min == (image.Point{}) {
+		return rgba
+	}
+
+	bounds := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+	return rgba
+}
+
+// resizeToFit scales the image down (never up) so that neither side exceeds
+// maxSide, averaging the source pixels covered by each target pixel. The
+// source must start at the origin, as toRGBA returns it.
+func resizeToFit(rgba *image.RGBA, maxSide int) *image.RGBA {
+	width, height := rgba.Bounds().Dx(), rgba.Bounds().Dy()
+	if width <= maxSide && height <= maxSide {
+		return rgba
+	}
+
+	newWidth, newHeight := maxSide, maxSide
+	if width > height {
+		newHeight = max(1, height*maxSide/width)
+	} else {
+		newWidth = max(1, width*maxSide/height)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0, y1 := y*height/newHeight, max((y+1)*height/newHeight, y*height/newHeight+1)
+		for x := 0; x < newWidth; x++ {
+			x0, x1 := x*width/newWidth, max((x+1)*width/newWidth, x*width/newWidth+1)
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					i := rgba.PixOffset(sx, sy)
+					r += uint32(rgba.Pix[i])
+					g += uint32(rgba.Pix[i+1])
+					b += uint32(rgba.Pix[i+2])
+					a += uint32(rgba.Pix[i+3])
+					n++
+				}
+			}
+
+			i := dst.PixOffset(x, y)
+			dst.Pix[i] = uint8(r / n)
+			dst.Pix[i+1] = uint8(g / n)
+			dst.Pix[i+2] = uint8(b / n)
+			dst.Pix[i+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(width, height int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeTestImage(t *testing.T, img image.Image, format string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "png":
+		err = png.Encode(&buf, img)
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("encoding %s: %v", format, err)
+	}
+	return buf.Bytes()
+}
+
+// withPNGSize rewrites the dimensions in a PNG's header, so it claims a size
+// its pixel data doesn't have
+func withPNGSize(data []byte, width, height uint32) []byte {
+	data = append([]byte{}, data...)
+	// signature (8), IHDR length (4), "IHDR" (4), then width and height
+	binary.BigEndian.PutUint32(data[16:20], width)
+	binary.BigEndian.PutUint32(data[20:24], height)
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestWithPNGSize(t *testing.T) {
+	data := withPNGSize(encodeTestImage(t, testImage(4, 3, color.White), "png"), 10_000, 10_000)
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("DecodeConfig: %v", err)
+	}
+	if config.Width != 10_000 || config.Height != 10_000 {
+		t.Errorf("size = %dx%d, want 10000x10000", config.Width, config.Height)
+	}
+}
+
+func TestDecodeUpload(t *testing.T) {
+	red := testImage(4, 3, color.RGBA{R: 255, A: 255})
+	pngData := encodeTestImage(t, red, "png")
+
+	tests := []struct {
+		name        string
+		data        []byte
+		contentType string
+		err         error
+	}{
+		{"png", pngData, "image/png", nil},
+		{"jpeg", encodeTestImage(t, red, "jpeg"), "image/jpeg", nil},
+		{"gif", encodeTestImage(t, red, "gif"), "image/gif", nil},
+		{"text", []byte("hello, not an image"), "", ErrUnsupportedImageType},
+		{"html", []byte("<html><body>hi</body></html>"), "", ErrUnsupportedImageType},
+		{"truncated png", pngData[:40], "", ErrInvalidImage},
+		{"too many pixels", withPNGSize(pngData, 10_000, 10_000), "", ErrInvalidImage},
+		{"pixels not matching its size", withPNGSize(pngData, 40, 30), "", ErrInvalidImage},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img, contentType, err := decodeUpload(tt.data)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				return
+			}
+			if contentType != tt.contentType {
+				t.Errorf("content type = %q, want %q", contentType, tt.contentType)
+			}
+			if size := img.Bounds().Size(); size != image.Pt(4, 3) {
+				t.Errorf("size = %v, want 4x3", size)
+			}
+		})
+	}
+}
+
+func TestToRGBA(t *testing.T) {
+	rgba := testImage(2, 2, color.White)
+	if got := toRGBA(rgba); got != rgba {
+		t.Error("an RGBA image at the origin was copied")
+	}
+
+	// other models and offset bounds are redrawn at the origin
+	gray := image.NewGray(image.Rect(2, 3, 5, 5))
+	gray.SetGray(2, 3, color.Gray{Y: 200})
+	got := toRGBA(gray)
+	if got.Bounds() != image.Rect(0, 0, 3, 2) {
+		t.Errorf("bounds = %v, want (0,0)-(3,2)", got.Bounds())
+	}
+	if c := got.RGBAAt(0, 0); c != (color.RGBA{200, 200, 200, 255}) {
+		t.Errorf("first pixel = %v, want the gray source pixel", c)
+	}
+}
+
+func TestResizeToFit(t *testing.T) {
+	tests := []struct {
+		name          string
+		width, height int
+		maxSide       int
+		want          image.Point
+	}{
+		{"landscape", 800, 400, 150, image.Pt(150, 75)},
+		{"portrait", 300, 900, 150, image.Pt(50, 150)},
+		{"square", 1000, 1000, 400, image.Pt(400, 400)},
+		{"never upscaled", 100, 50, 400, image.Pt(100, 50)},
+		{"thin strip keeps a pixel", 2000, 1, 150, image.Pt(150, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := resizeToFit(testImage(tt.width, tt.height, color.White), tt.maxSide).Bounds().Size()
+			if got != tt.want {
+				t.Errorf("size = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResizeToFitAveragesPixels(t *testing.T) {
+	// left half black, right half white: each target pixel covers one half
+	src := testImage(4, 2, color.White)
+	for y := 0; y < 2; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.Black)
+		}
+	}
+
+	dst := resizeToFit(src, 2)
+	if got := color.RGBAModel.Convert(dst.At(0, 0)).(color.RGBA); got != (color.RGBA{0, 0, 0, 255}) {
+		t.Errorf("left pixel = %v, want black", got)
+	}
+	if got := color.RGBAModel.Convert(dst.At(1, 0)).(color.RGBA); got != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("right pixel = %v, want white", got)
+	}
+
+	// two pixels, black and white, average to grey
+	src = testImage(2, 1, color.White)
+	src.Set(0, 0, color.Black)
+	grey := resizeToFit(src, 1)
+	if got := color.RGBAModel.Convert(grey.At(0, 0)).(color.RGBA); got.R < 126 || got.R > 128 {
+		t.Errorf("averaged pixel = %v, want mid grey", got)
+	}
+}
@@ -0,0 +1,554 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CancelLine cancels units of one order item
+type CancelLine struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+// OrderChangeError is a change to an order's items the order doesn't allow
+type OrderChangeError struct {
+	Field  string
+	Reason string
+}
+
+func (e OrderChangeError) Error() string {
+	return e.Reason
+}
+
+// EditOrder sets the quantities of products on a pending order: a listed
+// product already on the order gets the new quantity, or is removed with 0,
+// and other listed products are added. When ownerID is not zero the order
+// must belong to that user.
+//
+// Items kept on the order keep the price they were ordered at, added ones are
+// priced now in the order's currency at its locked exchange rate. The order's
+// coupons are spread over the new items, with their minimum order value
+// checked again, its taxes are recomputed and stock follows the quantity
+// changes. Orders with an authorized or captured payment can't be edited.
+func EditOrder(orderID uint, ownerID uint, lines []OrderLine, actor models.User, note string) (models.Order, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, ownerID)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if order.Status != models.StatusPending {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "order", Reason: fmt.Sprintf("only pending orders can be edited, this order is %s", order.Status)}
+	}
+
+	var active int64
+	if err := tx.Model(&models.Payment{}).
+		Where("order_id = ? AND status IN ?", order.ID, []models.PaymentStatus{models.PaymentAuthorized, models.PaymentCaptured}).
+		Count(&active).Error; err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+	if active > 0 {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "order", Reason: "orders with an authorized or captured payment can't be edited"}
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id ASC").Find(&items).Error; err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	type lineKey struct {
+		productID uint
+		variantID uint
+	}
+	byKey := make(map[lineKey]int, len(items))
+	previous := make([]int, len(items))
+	for i, item := range items {
+		key := lineKey{productID: item.ProductID}
+		if item.VariantID != nil {
+			key.variantID = *item.VariantID
+		}
+		byKey[key] = i
+		previous[i] = item.Quantity
+	}
+
+	// stock is taken for added lines and increases, and given back for
+	// decreases and removed lines
+	var addedLines, deductLines []OrderLine
+	seen := make(map[lineKey]bool, len(lines))
+	for _, line := range lines {
+		key := lineKey{line.ProductID, line.VariantID}
+		if seen[key] {
+			tx.Rollback()
+			return models.Order{}, OrderChangeError{Field: "items", Reason: fmt.Sprintf("%s is listed twice", describeOrderLine(line))}
+		}
+		seen[key] = true
+
+		if i, ok := byKey[key]; ok {
+			items[i].Quantity = line.Quantity
+			continue
+		}
+		if line.Quantity == 0 {
+			tx.Rollback()
+			return models.Order{}, OrderChangeError{Field: "items", Reason: fmt.Sprintf("%s is not part of this order", describeOrderLine(line))}
+		}
+		addedLines = append(addedLines, line)
+	}
+
+	var kept, removed, restock []models.OrderItem
+	var changes []models.OrderChangeItem
+	for i, item := range items {
+		if item.Quantity == previous[i] {
+			kept = append(kept, item)
+			continue
+		}
+		changes = append(changes, models.OrderChangeItem{
+			OrderItemID:      item.ID,
+			ProductID:        item.ProductID,
+			VariantID:        item.VariantID,
+			PreviousQuantity: previous[i],
+			NewQuantity:      item.Quantity,
+		})
+
+		line := OrderLine{ProductID: item.ProductID, Quantity: item.Quantity - previous[i]}
+		if item.VariantID != nil {
+			line.VariantID = *item.VariantID
+		}
+		if line.Quantity > 0 {
+			deductLines = append(deductLines, line)
+		} else {
+			returned := item
+			returned.Quantity = -line.Quantity
+			restock = append(restock, returned)
+		}
+
+		if item.Quantity == 0 {
+			removed = append(removed, item)
+		} else {
+			kept = append(kept, item)
+		}
+	}
+	deductLines = append(deductLines, addedLines...)
+
+	if len(changes) == 0 && len(addedLines) == 0 {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "items", Reason: "the order already has these quantities"}
+	}
+	if len(kept) == 0 && len(addedLines) == 0 {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "items", Reason: "an order needs at least one item, cancel it instead"}
+	}
+
+	// every product and variant of the order is locked, in the order
+	// CreateOrderTx locks them
+	allLines := append([]OrderLine{}, addedLines...)
+	for _, item := range items {
+		line := OrderLine{ProductID: item.ProductID}
+		if item.VariantID != nil {
+			line.VariantID = *item.VariantID
+		}
+		allLines = append(allLines, line)
+	}
+	productIDs, variantIDs := orderLineIDs(mergeOrderLines(allLines))
+
+	products, err := loadProducts(tx, productIDs, true)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	variants, err := loadVariants(tx, variantIDs, true)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	addedIDs, _ := orderLineIDs(addedLines)
+	withVariants, err := productsWithVariants(tx, addedIDs)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+	// added lines and increases take stock, so their products and variants
+	// must still be available
+	if err := checkOrderLines(deductLines, products, variants, withVariants); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	deductLines = mergeOrderLines(deductLines)
+	if shortages := stockShortages(deductLines, products, variants); len(shortages) > 0 {
+		tx.Rollback()
+		return models.Order{}, InsufficientStockError{Items: shortages}
+	}
+
+	pricer, err := OrderPricer(tx, order, addedIDs)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	for _, line := range addedLines {
+		item := models.OrderItem{
+			OrderID:   order.ID,
+			ProductID: line.ProductID,
+			Quantity:  line.Quantity,
+			Price:     pricer.Price(products[line.ProductID], nil),
+		}
+		if line.VariantID != 0 {
+			variant := variants[line.VariantID]
+			item.Price = pricer.Price(products[line.ProductID], &variant)
+			item.VariantID = &variant.ID
+		}
+		kept = append(kept, item)
+	}
+
+	previousTotal := order.TotalAmount
+	if err := repriceOrder(tx, &order, kept, products, pricer, true); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	order.PaymentStatus = models.OrderUnpaid
+	if order.TotalAmount.IsZero() {
+		order.PaymentStatus = models.OrderPaid
+	}
+
+	for _, item := range removed {
+		if err := tx.Delete(&models.OrderItem{}, item.ID).Error; err != nil {
+			tx.Rollback()
+			return models.Order{}, err
+		}
+	}
+	if err := saveRepricedOrder(tx, &order); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	for _, line := range deductLines {
+		if err := DeductStock(tx, line); err != nil {
+			tx.Rollback()
+			return models.Order{}, err
+		}
+	}
+	if err := RestockOrderItems(tx, restock); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	for _, item := range order.Items[len(order.Items)-len(addedLines):] {
+		changes = append(changes, models.OrderChangeItem{
+			OrderItemID: item.ID,
+			ProductID:   item.ProductID,
+			VariantID:   item.VariantID,
+			NewQuantity: item.Quantity,
+		})
+	}
+
+	change := models.OrderChange{
+		OrderID:       order.ID,
+		Type:          models.OrderChangeEdit,
+		ActorID:       actor.ID,
+		Note:          note,
+		PreviousTotal: previousTotal,
+		NewTotal:      order.TotalAmount,
+		Items:         changes,
+	}
+	if err := tx.Create(&change).Error; err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// CancelOrderItems cancels units of a processing order, e.g. ones that can't
+// be shipped; shipped units can't be cancelled, and an order whose other units
+// have all been shipped moves to shipped. The units go back into stock and the
+// order is repriced: items
+// keep their price, the order's coupons stay applied even when they no longer
+// would, and taxes are recomputed.
+//
+// What the order no longer costs is taken off an authorized payment, which
+// then captures less, or refunded from a captured one. The order row stays
+// locked while the provider is called; a declined refund cancels nothing and
+// is returned as the payments.DeclinedError.
+func CancelOrderItems(ctx context.Context, orderID uint, lines []CancelLine, actor models.User, note string) (models.Order, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if order.Status != models.StatusProcessing {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "order", Reason: fmt.Sprintf("only processing orders can have items cancelled, this order is %s", order.Status)}
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id ASC").Find(&items).Error; err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+	byID := make(map[uint]int, len(items))
+	for i, item := range items {
+		byID[item.ID] = i
+	}
+
+	shipped, err := shippedQuantities(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	var restock []models.OrderItem
+	var changes []models.OrderChangeItem
+	seen := make(map[uint]bool, len(lines))
+	for _, line := range lines {
+		i, ok := byID[line.OrderItemID]
+		if !ok {
+			tx.Rollback()
+			return models.Order{}, OrderChangeError{Field: "items", Reason: fmt.Sprintf("order item %d is not part of this order", line.OrderItemID)}
+		}
+		if seen[line.OrderItemID] {
+			tx.Rollback()
+			return models.Order{}, OrderChangeError{Field: "items", Reason: fmt.Sprintf("order item %d is listed twice", line.OrderItemID)}
+		}
+		seen[line.OrderItemID] = true
+
+		item := items[i]
+		if left := unshippedQuantity(item, shipped); line.Quantity > left {
+			tx.Rollback()
+			return models.Order{}, OrderChangeError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can be cancelled", left, item.ID)}
+		}
+
+		items[i].Quantity -= line.Quantity
+		items[i].CancelledQuantity += line.Quantity
+		changes = append(changes, models.OrderChangeItem{
+			OrderItemID:      item.ID,
+			ProductID:        item.ProductID,
+			VariantID:        item.VariantID,
+			PreviousQuantity: item.Quantity,
+			NewQuantity:      items[i].Quantity,
+		})
+
+		cancelled := item
+		cancelled.Quantity = line.Quantity
+		restock = append(restock, cancelled)
+	}
+
+	left := 0
+	for _, item := range items {
+		left += item.Quantity - item.RefundedQuantity
+	}
+	if left == 0 {
+		tx.Rollback()
+		return models.Order{}, OrderChangeError{Field: "items", Reason: "no item would be left to ship, cancel the order instead"}
+	}
+
+	productIDs := make([]uint, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	products, err := loadProducts(tx, productIDs, false)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	pricer, err := OrderPricer(tx, order, nil)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	previousTotal := order.TotalAmount
+	if err := repriceOrder(tx, &order, items, products, pricer, false); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+	if err := saveRepricedOrder(tx, &order); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := RestockOrderItems(tx, restock); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	change := models.OrderChange{
+		OrderID:       order.ID,
+		Type:          models.OrderChangeCancelItems,
+		ActorID:       actor.ID,
+		Note:          note,
+		PreviousTotal: previousTotal,
+		NewTotal:      order.TotalAmount,
+		Items:         changes,
+	}
+
+	adjustment, err := cancellationAdjustment(order.PaymentStatus, previousTotal, order.TotalAmount)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+	if adjustment.Authorized.IsSet() {
+		if err := tx.Model(&models.Payment{}).
+			Where("order_id = ? AND status = ?", order.ID, models.PaymentAuthorized).
+			Update("amount_minor", adjustment.Authorized.Amount).Error; err != nil {
+			tx.Rollback()
+			return models.Order{}, err
+		}
+	}
+	if adjustment.Refund.IsSet() {
+		reason := note
+		if reason == "" {
+			reason = "Cancelled items"
+		}
+		refund, err := RefundOrderTx(ctx, tx, &order, RefundOptions{Amount: adjustment.Refund, Reason: reason}, actor)
+		if err != nil {
+			tx.Rollback()
+			return models.Order{}, err
+		}
+		change.RefundID = &refund.ID
+	}
+
+	if err := tx.Create(&change).Error; err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := advanceOrderShipping(tx, order.ID, actor, fmt.Sprintf("Order change #%d", change.ID)); err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// paymentAdjustment is what a change of the order's total does to its payment
+type paymentAdjustment struct {
+	Authorized money.Money // the new amount of the authorized payment, when set
+	Refund     money.Money // refunded from the captured payment, when set
+}
+
+// cancellationAdjustment works out the payment change for cancelled items: an
+// authorized payment captures the new total, a captured one is refunded what
+// the order no longer costs, and unpaid orders have nothing to adjust
+func cancellationAdjustment(status models.OrderPaymentStatus, previousTotal money.Money, newTotal money.Money) (paymentAdjustment, error) {
+	difference, err := previousTotal.Sub(newTotal)
+	if err != nil {
+		return paymentAdjustment{}, err
+	}
+
+	switch {
+	case status == models.OrderPaymentAuthorized:
+		return paymentAdjustment{Authorized: newTotal}, nil
+	case status.IsPaid() && difference.IsPositive():
+		return paymentAdjustment{Refund: difference}, nil
+	}
+	return paymentAdjustment{}, nil
+}
+
+// repriceOrder sets the order's items to the given ones, reapplies its
+// coupons, taxes and shipping method to them and recomputes its totals. When
+// not strict, an order its shipping method no longer ships keeps the shipping
+// it was charged.
+func repriceOrder(tx *gorm.DB, order *models.Order, items []models.OrderItem, products map[uint]models.Product, pricer Pricer, strict bool) error {
+	discounts, err := reapplyOrderCoupons(tx, order.ID, items, pricer, strict)
+	if err != nil {
+		return err
+	}
+
+	taxLines, err := ApplyTaxes(tx, order.TaxRegion, items, products, order.Currency)
+	if err != nil {
+		return err
+	}
+
+	order.Items = items
+	order.Discounts = discounts
+	order.TaxLines = taxLines
+
+	if order.ShippingMethodID != nil {
+		method, err := LoadShippingMethod(tx, *order.ShippingMethodID, false)
+		if err != nil {
+			return err
+		}
+		charged := order.ShippingTotal
+		if err := shipOrder(order, &method, products, pricer); err != nil {
+			var shippingErr ShippingError
+			if strict || !errors.As(err, &shippingErr) {
+				return err
+			}
+			order.ShippingTotal = charged
+		}
+	}
+
+	return sumOrderTotals(order)
+}
+
+// saveRepricedOrder saves the items, discounts, tax lines and totals set by
+// repriceOrder; items without an ID are created
+func saveRepricedOrder(tx *gorm.DB, order *models.Order) error {
+	for i := range order.Items {
+		order.Items[i].OrderID = order.ID
+		if err := tx.Omit(clause.Associations).Save(&order.Items[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	for i := range order.Discounts {
+		if err := tx.Save(&order.Discounts[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Where("order_id = ?", order.ID).Delete(&models.OrderTaxLine{}).Error; err != nil {
+		return err
+	}
+	for i := range order.TaxLines {
+		order.TaxLines[i].OrderID = order.ID
+		if err := tx.Create(&order.TaxLines[i]).Error; err != nil {
+			return err
+		}
+	}
+
+	return tx.Model(order).Updates(map[string]interface{}{
+		"subtotal_minor":       order.Subtotal.Amount,
+		"discount_total_minor": order.DiscountTotal.Amount,
+		"tax_total_minor":      order.TaxTotal.Amount,
+		"total_amount_minor":   order.TotalAmount.Amount,
+		"shipping_total_minor": order.ShippingTotal.Amount,
+		"payment_status":       order.PaymentStatus,
+	}).Error
+}
+
+func describeOrderLine(line OrderLine) string {
+	if line.VariantID != 0 {
+		return fmt.Sprintf("product %d variant %d", line.ProductID, line.VariantID)
+	}
+	return fmt.Sprintf("product %d", line.ProductID)
+}
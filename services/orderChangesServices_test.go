@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+func TestCancellationAdjustment(t *testing.T) {
+	usd := func(amount int64) money.Money { return money.New(amount, "USD") }
+
+	tests := []struct {
+		name       string
+		status     models.OrderPaymentStatus
+		previous   money.Money
+		total      money.Money
+		authorized money.Money
+		refund     money.Money
+	}{
+		{"unpaid", models.OrderUnpaid, usd(3000), usd(2000), money.Money{}, money.Money{}},
+		{"authorized captures less", models.OrderPaymentAuthorized, usd(3000), usd(2000), usd(2000), money.Money{}},
+		{"paid is refunded the difference", models.OrderPaid, usd(3000), usd(2000), money.Money{}, usd(1000)},
+		{"partially refunded too", models.OrderPartiallyRefunded, usd(3000), usd(2500), money.Money{}, usd(500)},
+		{"same total", models.OrderPaid, usd(3000), usd(3000), money.Money{}, money.Money{}},
+		// e.g. shipping that no longer ships free
+		{"higher total", models.OrderPaid, usd(3000), usd(3100), money.Money{}, money.Money{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cancellationAdjustment(tt.status, tt.previous, tt.total)
+			if err != nil {
+				t.Fatalf("error = %v", err)
+			}
+			if got.Authorized != tt.authorized || got.Refund != tt.refund {
+				t.Errorf("adjustment = %+v, want authorized %v refund %v", got, tt.authorized, tt.refund)
+			}
+		})
+	}
+
+	if _, err := cancellationAdjustment(models.OrderPaid, usd(3000), money.New(2000, "EUR")); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("mixed currencies: error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestSumOrderTotals(t *testing.T) {
+	usd := func(amount int64) money.Money { return money.New(amount, "USD") }
+
+	order := models.Order{
+		Currency: "USD",
+		Items: []models.OrderItem{
+			{Quantity: 2, Price: usd(1000), Discount: usd(200), Tax: usd(180)},
+			{Quantity: 1, Price: usd(500), Discount: usd(0), Tax: usd(50)},
+		},
+		TaxLines: []models.OrderTaxLine{
+			{Inclusive: false, Amount: usd(180)},
+			{Inclusive: true, Amount: usd(50)}, // already in the price
+		},
+		ShippingTotal: usd(400),
+	}
+	if err := sumOrderTotals(&order); err != nil {
+		t.Fatal(err)
+	}
+
+	if order.Subtotal != usd(2500) || order.DiscountTotal != usd(200) || order.TaxTotal != usd(230) {
+		t.Errorf("subtotal %v, discounts %v, taxes %v", order.Subtotal, order.DiscountTotal, order.TaxTotal)
+	}
+	// 2500 - 200 + 180 exclusive tax + 400 shipping
+	if order.TotalAmount != usd(2880) {
+		t.Errorf("total = %v, want 28.80", order.TotalAmount)
+	}
+}
+
+// createOrderOf places an order of quantity units of a new product at 10.00
+// with the given stock
+func createOrderOf(t *testing.T, user models.User, quantity int, stock int) (models.Order, models.Product) {
+	t.Helper()
+
+	product := models.Product{
+		Name:     "Changed product",
+		Price:    money.New(1000, money.BaseCurrency()),
+		Stock:    stock,
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	order, err := CreateOrder(user.ID, []OrderLine{{ProductID: product.ID, Quantity: quantity}}, OrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	return order, product
+}
+
+func requireStock(t *testing.T, productID uint, want int) {
+	t.Helper()
+
+	var product models.Product
+	if err := initializers.DB.First(&product, productID).Error; err != nil {
+		t.Fatalf("reloading product: %v", err)
+	}
+	if product.Stock != want {
+		t.Errorf("product %d stock is %d, want %d", productID, product.Stock, want)
+	}
+}
+
+func TestEditOrder(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t)
+
+	order, first := createOrderOf(t, user, 2, 5)
+	_, second := createOrderOf(t, user, 1, 5)
+
+	edited, err := EditOrder(order.ID, user.ID, []OrderLine{
+		{ProductID: first.ID, Quantity: 1},
+		{ProductID: second.ID, Quantity: 3},
+	}, user, "")
+	if err != nil {
+		t.Fatalf("EditOrder: %v", err)
+	}
+	if edited.TotalAmount != money.New(4000, money.BaseCurrency()) {
+		t.Errorf("total = %v, want 40.00", edited.TotalAmount)
+	}
+	requireStock(t, first.ID, 4)
+	requireStock(t, second.ID, 1)
+
+	var changes []models.OrderChange
+	if err := initializers.DB.Preload("Items").Where("order_id = ?", order.ID).Find(&changes).Error; err != nil {
+		t.Fatalf("loading changes: %v", err)
+	}
+	if len(changes) != 1 || len(changes[0].Items) != 2 || changes[0].PreviousTotal != money.New(2000, money.BaseCurrency()) {
+		t.Errorf("changes = %+v", changes)
+	}
+
+	// a product taken off sale can't be added, nor ordered more of
+	if err := initializers.DB.Model(&second).Update("is_active", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	_, err = EditOrder(order.ID, user.ID, []OrderLine{{ProductID: second.ID, Quantity: 4}}, user, "")
+	if !errors.As(err, &ProductNotFoundError{}) {
+		t.Errorf("ordering more of an inactive product: error = %v, want ProductNotFoundError", err)
+	}
+
+	// but it can still be removed
+	if _, err := EditOrder(order.ID, user.ID, []OrderLine{{ProductID: second.ID, Quantity: 0}}, user, ""); err != nil {
+		t.Errorf("removing an inactive product: %v", err)
+	}
+	requireStock(t, second.ID, 4)
+}
+
+func TestCancelOrderItems(t *testing.T) {
+	useTestDB(t)
+	useMockPayments(t)
+	ctx := context.Background()
+
+	for _, capture := range []bool{false, true} {
+		user := createTestUser(t)
+		admin := user
+		admin.Role = models.UserRoleAdmin
+
+		order, product := createOrderOf(t, user, 3, 5)
+		if _, err := PayOrder(ctx, order.ID, user.ID, "tok_visa", capture); err != nil {
+			t.Fatalf("PayOrder: %v", err)
+		}
+		if _, err := ChangeOrderStatus(order.ID, 0, models.StatusProcessing, admin, ""); err != nil {
+			t.Fatalf("moving to processing: %v", err)
+		}
+
+		item := order.Items[0]
+		changed, err := CancelOrderItems(ctx, order.ID, []CancelLine{{OrderItemID: item.ID, Quantity: 1}}, admin, "")
+		if err != nil {
+			t.Fatalf("CancelOrderItems: %v", err)
+		}
+		if changed.TotalAmount != money.New(2000, money.BaseCurrency()) {
+			t.Errorf("total = %v, want 20.00", changed.TotalAmount)
+		}
+		requireStock(t, product.ID, 3)
+
+		if err := initializers.DB.First(&item, item.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if item.Quantity != 2 || item.CancelledQuantity != 1 {
+			t.Errorf("item quantity %d, cancelled %d, want 2 and 1", item.Quantity, item.CancelledQuantity)
+		}
+
+		var payment models.Payment
+		if err := initializers.DB.Where("order_id = ?", order.ID).Last(&payment).Error; err != nil {
+			t.Fatal(err)
+		}
+		var reloaded models.Order
+		if err := initializers.DB.First(&reloaded, order.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if capture {
+			// the captured 30.00 stays, 10.00 of it is refunded
+			if payment.Amount.Amount != 3000 || reloaded.RefundedTotal.Amount != 1000 || reloaded.PaymentStatus != models.OrderPartiallyRefunded {
+				t.Errorf("captured: payment %v, refunded %v, status %s", payment.Amount, reloaded.RefundedTotal, reloaded.PaymentStatus)
+			}
+		} else {
+			// the authorization now captures the new total
+			if payment.Amount.Amount != 2000 || reloaded.RefundedTotal.Amount != 0 || reloaded.PaymentStatus != models.OrderPaymentAuthorized {
+				t.Errorf("authorized: payment %v, refunded %v, status %s", payment.Amount, reloaded.RefundedTotal, reloaded.PaymentStatus)
+			}
+		}
+	}
+}
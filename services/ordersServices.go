@@ -0,0 +1,746 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OrderLine is a single product/quantity pair to be ordered. VariantID is
+// required for products that have variants and 0 otherwise.
+type OrderLine struct {
+	ProductID uint
+	VariantID uint
+	Quantity  int
+}
+
+type InsufficientStock struct {
+	ProductID   uint   `json:"product_id"`
+	VariantID   uint   `json:"variant_id,omitempty"`
+	SKU         string `json:"sku,omitempty"`
+	ProductName string `json:"product_name"`
+	Requested   int    `json:"requested"`
+	Available   int    `json:"available"`
+}
+
+// InsufficientStockError lists every line that cannot be fulfilled
+type InsufficientStockError struct {
+	Items []InsufficientStock
+}
+
+func (e InsufficientStockError) Error() string {
+	return "insufficient stock for some products"
+}
+
+type ProductNotFoundError struct {
+	ProductID uint
+}
+
+func (e ProductNotFoundError) Error() string {
+	return fmt.Sprintf("Product ID: %d not found", e.ProductID)
+}
+
+// VariantError reports a line whose variant is missing, unavailable, doesn't
+// belong to the product, or wasn't given for a product that has variants
+type VariantError struct {
+	ProductID uint
+	VariantID uint
+	Reason    string
+}
+
+func (e VariantError) Error() string {
+	return fmt.Sprintf("Product ID: %d: %s", e.ProductID, e.Reason)
+}
+
+// OrderOptions are the choices made by the customer besides the lines
+type OrderOptions struct {
+	Currency         string           // the base currency when empty
+	CouponCodes      []string         // applied in this order
+	TaxRegion        models.TaxRegion // the shipping address's when empty; no tax is charged without a country
+	AddressID        uint             // from the user's address book, required with a shipping method
+	ShippingMethodID uint             // nothing is charged for shipping without one
+}
+
+// CreateOrder places an order for the given lines in its own transaction
+func CreateOrder(userID uint, lines []OrderLine, options OrderOptions) (models.Order, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := CreateOrderTx(tx, userID, lines, options)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// CreateOrderTx validates stock, creates the order and its items at current
+// product (or variant) prices and deducts stock, all inside the caller's
+// transaction. The caller is responsible for committing or rolling back.
+//
+// Prices are taken in the options' currency, and the currency and exchange
+// rate are locked on the order. Coupons and the taxes of the options' region
+// are applied to the priced items and recorded with the order, and shipping
+// is charged by the chosen method for the chosen address.
+//
+// Product rows and then variant rows are locked (SELECT ... FOR UPDATE) in
+// ascending ID order before stock is checked, so concurrent orders for the
+// same products serialize instead of overselling, and can't deadlock on each
+// other.
+func CreateOrderTx(tx *gorm.DB, userID uint, lines []OrderLine, options OrderOptions) (models.Order, error) {
+	lines = mergeOrderLines(lines)
+	productIDs, variantIDs := orderLineIDs(lines)
+
+	products, err := loadProducts(tx, productIDs, true)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	variants, err := loadVariants(tx, variantIDs, true)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	priced, err := priceOrder(tx, userID, lines, products, variants, options)
+	if err != nil {
+		return models.Order{}, err
+	}
+	order := priced.Order
+
+	// items, discounts and tax lines are saved below with the order ID
+	if err := tx.Omit(clause.Associations).Create(&order).Error; err != nil {
+		return models.Order{}, err
+	}
+
+	if err := recordStatusEvent(tx, order.ID, "", models.StatusPending, userID, "Order created"); err != nil {
+		return models.Order{}, err
+	}
+
+	for i, line := range lines {
+		order.Items[i].OrderID = order.ID
+		if err := tx.Create(&order.Items[i]).Error; err != nil {
+			return models.Order{}, err
+		}
+
+		if err := DeductStock(tx, line); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	if err := RecordCouponRedemptions(tx, priced.Coupons, userID, order.ID); err != nil {
+		return models.Order{}, err
+	}
+
+	for i := range order.TaxLines {
+		order.TaxLines[i].OrderID = order.ID
+		if err := tx.Create(&order.TaxLines[i]).Error; err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	return order, nil
+}
+
+// QuoteOrder prices the lines the way CreateOrder would, coupons and taxes
+// included, without placing the order. Nothing is locked or written: stock is
+// checked but not taken and coupon uses are not counted.
+func QuoteOrder(userID uint, lines []OrderLine, options OrderOptions) (models.Order, error) {
+	lines = mergeOrderLines(lines)
+	productIDs, variantIDs := orderLineIDs(lines)
+
+	products, err := loadProducts(initializers.DB, productIDs, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	variants, err := loadVariants(initializers.DB, variantIDs, false)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	priced, err := priceOrder(initializers.DB, userID, lines, products, variants, options)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	order := priced.Order
+	for i, item := range order.Items {
+		order.Items[i].Product = products[item.ProductID]
+		if item.VariantID != nil {
+			variant := variants[*item.VariantID]
+			order.Items[i].Variant = &variant
+		}
+	}
+	return order, nil
+}
+
+// pricedOrder is an order built by priceOrder, not saved yet
+type pricedOrder struct {
+	Order   models.Order // with its Items, Discounts and TaxLines
+	Coupons AppliedCoupons
+}
+
+// priceOrder checks the merged lines against the products, variants and
+// stock, then prices them in the options' currency and applies coupons and
+// taxes
+func priceOrder(db *gorm.DB, userID uint, lines []OrderLine, products map[uint]models.Product, variants map[uint]models.ProductVariant, options OrderOptions) (pricedOrder, error) {
+	currency := options.Currency
+	if currency == "" {
+		currency = money.BaseCurrency()
+	}
+
+	productIDs, _ := orderLineIDs(lines)
+	withVariants, err := productsWithVariants(db, productIDs)
+	if err != nil {
+		return pricedOrder{}, err
+	}
+
+	pricer, err := NewPricer(db, currency, productIDs)
+	if err != nil {
+		return pricedOrder{}, err
+	}
+
+	if err := checkOrderLines(lines, products, variants, withVariants); err != nil {
+		return pricedOrder{}, err
+	}
+
+	// Validate stock availability for all items first
+	if shortages := stockShortages(lines, products, variants); len(shortages) > 0 {
+		return pricedOrder{}, InsufficientStockError{Items: shortages}
+	}
+
+	items := make([]models.OrderItem, 0, len(lines))
+	for _, line := range lines {
+		product := products[line.ProductID]
+		item := models.OrderItem{
+			ProductID: product.ID,
+			Quantity:  line.Quantity,
+			Price:     pricer.Price(product, nil),
+		}
+		if line.VariantID != 0 {
+			variant := variants[line.VariantID]
+			item.Price = pricer.Price(product, &variant)
+			item.VariantID = &variant.ID
+		}
+		items = append(items, item)
+	}
+
+	applied, err := ApplyCoupons(db, userID, options.CouponCodes, items, pricer)
+	if err != nil {
+		return pricedOrder{}, err
+	}
+
+	address, method, err := orderShipping(db, userID, options)
+	if err != nil {
+		return pricedOrder{}, err
+	}
+
+	region := NormalizeTaxRegion(options.TaxRegion)
+	if !region.IsSet() {
+		region = address.TaxRegion()
+	}
+	taxLines, err := ApplyTaxes(db, region, items, products, pricer.Currency)
+	if err != nil {
+		return pricedOrder{}, err
+	}
+
+	order := models.Order{
+		UserID:          userID,
+		Status:          models.StatusPending,
+		PaymentStatus:   models.OrderUnpaid,
+		Currency:        pricer.Currency,
+		ExchangeRate:    pricer.RateString(),
+		RefundedTotal:   money.New(0, pricer.Currency),
+		TaxRegion:       region,
+		FreeShipping:    applied.FreeShipping,
+		ShippingAddress: address,
+		Items:           items,
+		Discounts:       applied.Discounts,
+		TaxLines:        taxLines,
+	}
+	if method != nil {
+		order.ShippingMethodID = &method.ID
+		order.ShippingMethodName = method.Name
+	}
+	if err := shipOrder(&order, method, products, pricer); err != nil {
+		return pricedOrder{}, err
+	}
+	if err := sumOrderTotals(&order); err != nil {
+		return pricedOrder{}, err
+	}
+	if order.TotalAmount.IsZero() {
+		// nothing to pay, e.g. fully discounted
+		order.PaymentStatus = models.OrderPaid
+	}
+
+	return pricedOrder{Order: order, Coupons: applied}, nil
+}
+
+// orderShipping loads the address and shipping method chosen in the options
+func orderShipping(db *gorm.DB, userID uint, options OrderOptions) (models.PostalAddress, *models.ShippingMethod, error) {
+	var address models.PostalAddress
+	if options.AddressID != 0 {
+		var entry models.Address
+		if err := db.Where("id = ? AND user_id = ? AND deleted_at IS NULL", options.AddressID, userID).First(&entry).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return address, nil, ShippingError{Field: "address_id", Reason: fmt.Sprintf("address %d not found", options.AddressID)}
+			}
+			return address, nil, err
+		}
+		address = entry.PostalAddress
+	}
+
+	if options.ShippingMethodID == 0 {
+		return address, nil, nil
+	}
+	if !address.IsSet() {
+		return address, nil, ShippingError{Field: "address_id", Reason: "a shipping address is required with a shipping method"}
+	}
+
+	method, err := LoadShippingMethod(db, options.ShippingMethodID, true)
+	if err != nil {
+		return address, nil, err
+	}
+	return address, &method, nil
+}
+
+// checkOrderLines checks that the products of the lines exist and can be
+// ordered, and that a line names an available variant of its product exactly
+// when the product has variants
+func checkOrderLines(lines []OrderLine, products map[uint]models.Product, variants map[uint]models.ProductVariant, withVariants map[uint]bool) error {
+	for _, line := range lines {
+		product, ok := products[line.ProductID]
+		if !ok || !product.IsAvailable() {
+			return ProductNotFoundError{ProductID: line.ProductID}
+		}
+
+		if line.VariantID == 0 {
+			if withVariants[product.ID] {
+				return VariantError{ProductID: product.ID, Reason: "a variant must be selected"}
+			}
+			continue
+		}
+
+		variant, ok := variants[line.VariantID]
+		if !ok || variant.ProductID != product.ID || !variant.IsAvailable() {
+			return VariantError{
+				ProductID: product.ID,
+				VariantID: line.VariantID,
+				Reason:    fmt.Sprintf("variant ID %d not found", line.VariantID),
+			}
+		}
+	}
+	return nil
+}
+
+// stockShortages lists the checked lines whose variant, or product when they
+// have none, doesn't have their quantity in stock
+func stockShortages(lines []OrderLine, products map[uint]models.Product, variants map[uint]models.ProductVariant) []InsufficientStock {
+	var shortages []InsufficientStock
+	for _, line := range lines {
+		product := products[line.ProductID]
+		if line.VariantID == 0 {
+			if product.Stock < line.Quantity {
+				shortages = append(shortages, InsufficientStock{
+					ProductID:   product.ID,
+					ProductName: product.Name,
+					Requested:   line.Quantity,
+					Available:   product.Stock,
+				})
+			}
+			continue
+		}
+
+		variant := variants[line.VariantID]
+		if variant.Stock < line.Quantity {
+			shortages = append(shortages, InsufficientStock{
+				ProductID:   product.ID,
+				VariantID:   variant.ID,
+				SKU:         variant.SKU,
+				ProductName: product.Name,
+				Requested:   line.Quantity,
+				Available:   variant.Stock,
+			})
+		}
+	}
+	return shortages
+}
+
+// sumOrderTotals sets the subtotal, discount, tax and total of the order from
+// its priced Items and TaxLines, and its ShippingTotal
+func sumOrderTotals(order *models.Order) error {
+	order.Subtotal = money.New(0, order.Currency)
+	order.DiscountTotal = money.New(0, order.Currency)
+	order.TaxTotal = money.New(0, order.Currency)
+
+	var err error
+	for _, item := range order.Items {
+		if order.Subtotal, err = order.Subtotal.Add(item.Price.Mul(item.Quantity)); err != nil {
+			return err
+		}
+		if order.DiscountTotal, err = order.DiscountTotal.Add(item.Discount); err != nil {
+			return err
+		}
+		if order.TaxTotal, err = order.TaxTotal.Add(item.Tax); err != nil {
+			return err
+		}
+	}
+
+	exclusiveTax, err := ExclusiveTax(order.TaxLines, order.Currency)
+	if err != nil {
+		return err
+	}
+	if order.TotalAmount, err = order.Subtotal.Sub(order.DiscountTotal); err != nil {
+		return err
+	}
+	if order.TotalAmount, err = order.TotalAmount.Add(exclusiveTax); err != nil {
+		return err
+	}
+	if order.ShippingTotal.IsPositive() {
+		order.TotalAmount, err = order.TotalAmount.Add(order.ShippingTotal)
+	}
+	return err
+}
+
+// DeductStock atomically takes the line quantity from the stock of its variant,
+// or of its product when it has no variant. The conditional UPDATE never lets
+// stock go below zero, even without a prior lock.
+func DeductStock(tx *gorm.DB, line OrderLine) error {
+	var result *gorm.DB
+	if line.VariantID != 0 {
+		result = tx.Model(&models.ProductVariant{}).
+			Where("id = ? AND stock >= ?", line.VariantID, line.Quantity).
+			Update("stock", gorm.Expr("stock - ?", line.Quantity))
+	} else {
+		result = tx.Model(&models.Product{}).
+			Where("id = ? AND stock >= ?", line.ProductID, line.Quantity).
+			Update("stock", gorm.Expr("stock - ?", line.Quantity))
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		var product models.Product
+		if err := tx.First(&product, line.ProductID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return ProductNotFoundError{ProductID: line.ProductID}
+			}
+			return err
+		}
+
+		shortage := InsufficientStock{
+			ProductID:   product.ID,
+			ProductName: product.Name,
+			Requested:   line.Quantity,
+			Available:   product.Stock,
+		}
+		if line.VariantID != 0 {
+			var variant models.ProductVariant
+			if err := tx.First(&variant, line.VariantID).Error; err != nil {
+				return err
+			}
+			shortage.VariantID = variant.ID
+			shortage.SKU = variant.SKU
+			shortage.Available = variant.Stock
+		}
+		return InsufficientStockError{Items: []InsufficientStock{shortage}}
+	}
+
+	return nil
+}
+
+// RestockOrderItems puts the quantities of the given order items back into
+// the stock of their variant or product, in ascending ID order
+func RestockOrderItems(tx *gorm.DB, items []models.OrderItem) error {
+	lines := make([]OrderLine, 0, len(items))
+	for _, item := range items {
+		line := OrderLine{ProductID: item.ProductID, Quantity: item.Quantity}
+		if item.VariantID != nil {
+			line.VariantID = *item.VariantID
+		}
+		lines = append(lines, line)
+	}
+	lines = mergeOrderLines(lines)
+
+	// products first, then variants, the same order CreateOrderTx locks in
+	for _, line := range lines {
+		if line.VariantID != 0 {
+			continue
+		}
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", line.ProductID).
+			Update("stock", gorm.Expr("stock + ?", line.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(lines, func(i, j int) bool {
+		return lines[i].VariantID < lines[j].VariantID
+	})
+	for _, line := range lines {
+		if line.VariantID == 0 {
+			continue
+		}
+		if err := tx.Model(&models.ProductVariant{}).
+			Where("id = ?", line.VariantID).
+			Update("stock", gorm.Expr("stock + ?", line.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// restockOrderUnits puts units of items that stay on their order back into
+// stock, for refunds, returns and cancelled orders, and counts them in the
+// items' RestockedQuantity so they are never restocked twice. Each item's
+// Quantity is the number of its units to restock.
+func restockOrderUnits(tx *gorm.DB, items []models.OrderItem) error {
+	for _, item := range items {
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", item.ID).
+			Update("restocked_quantity", gorm.Expr("restocked_quantity + ?", item.Quantity)).Error; err != nil {
+			return err
+		}
+	}
+	return RestockOrderItems(tx, items)
+}
+
+// restockCancelledOrder puts the units of a cancelled order back into stock,
+// except those a refund or return already restocked
+func restockCancelledOrder(tx *gorm.DB, items []models.OrderItem) error {
+	var restock []models.OrderItem
+	for _, item := range items {
+		item.Quantity -= item.RestockedQuantity
+		if item.Quantity > 0 {
+			restock = append(restock, item)
+		}
+	}
+	return restockOrderUnits(tx, restock)
+}
+
+// orderLineIDs returns the product and variant IDs of the lines
+func orderLineIDs(lines []OrderLine) ([]uint, []uint) {
+	var productIDs, variantIDs []uint
+	for _, line := range lines {
+		productIDs = append(productIDs, line.ProductID)
+		if line.VariantID != 0 {
+			variantIDs = append(variantIDs, line.VariantID)
+		}
+	}
+	return productIDs, variantIDs
+}
+
+// loadProducts loads the given products in ascending ID order, row-locking
+// them when lock is set
+func loadProducts(db *gorm.DB, productIDs []uint, lock bool) (map[uint]models.Product, error) {
+	var products []models.Product
+	if len(productIDs) > 0 {
+		query := db
+		if lock {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		err := query.
+			Where("id IN ?", productIDs).
+			Order("id ASC").
+			Find(&products).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	byID := make(map[uint]models.Product, len(products))
+	for _, product := range products {
+		byID[product.ID] = product
+	}
+	return byID, nil
+}
+
+// loadVariants loads the given variants in ascending ID order, row-locking
+// them when lock is set
+func loadVariants(db *gorm.DB, variantIDs []uint, lock bool) (map[uint]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	if len(variantIDs) > 0 {
+		query := db
+		if lock {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		err := query.
+			Where("id IN ?", variantIDs).
+			Order("id ASC").
+			Find(&variants).Error
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	byID := make(map[uint]models.ProductVariant, len(variants))
+	for _, variant := range variants {
+		byID[variant.ID] = variant
+	}
+	return byID, nil
+}
+
+// productsWithVariants reports which of the products have orderable variants
+func productsWithVariants(db *gorm.DB, productIDs []uint) (map[uint]bool, error) {
+	result := make(map[uint]bool)
+	if len(productIDs) == 0 {
+		return result, nil
+	}
+
+	var ids []uint
+	err := db.Model(&models.ProductVariant{}).
+		Where("product_id IN ? AND is_active = ? AND deleted_at IS NULL", productIDs, true).
+		Distinct().
+		Pluck("product_id", &ids).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result, nil
+}
+
+// mergeOrderLines combines lines for the same product and variant and sorts
+// them by product ID, which gives every transaction the same lock order
+func mergeOrderLines(lines []OrderLine) []OrderLine {
+	type lineKey struct {
+		productID uint
+		variantID uint
+	}
+
+	quantities := make(map[lineKey]int, len(lines))
+	for _, line := range lines {
+		quantities[lineKey{line.ProductID, line.VariantID}] += line.Quantity
+	}
+
+	merged := make([]OrderLine, 0, len(quantities))
+	for key, quantity := range quantities {
+		merged = append(merged, OrderLine{ProductID: key.productID, VariantID: key.variantID, Quantity: quantity})
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		if merged[i].ProductID != merged[j].ProductID {
+			return merged[i].ProductID < merged[j].ProductID
+		}
+		return merged[i].VariantID < merged[j].VariantID
+	})
+	return merged
+}
+
+// ChangeOrderStatus moves an order along the status graph on behalf of actor
+// and applies the side effects of the transition in one transaction. When
+// ownerID is not zero the order must belong to that user.
+func ChangeOrderStatus(orderID uint, ownerID uint, newStatus models.OrderStatus, actor models.User, note string) (models.Order, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := ChangeOrderStatusTx(tx, orderID, ownerID, newStatus, actor, note)
+	if err != nil {
+		tx.Rollback()
+		return models.Order{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+// ChangeOrderStatusTx is ChangeOrderStatus inside the caller's transaction.
+// The order row is locked so concurrent changes see each other's result, and
+// the change is recorded in the order's status history.
+func ChangeOrderStatusTx(tx *gorm.DB, orderID uint, ownerID uint, newStatus models.OrderStatus, actor models.User, note string) (models.Order, error) {
+	order, err := lockOrder(tx, orderID, ownerID)
+	if err != nil {
+		return models.Order{}, err
+	}
+
+	if err := order.Status.ValidateTransitionFor(newStatus, actor.Role); err != nil {
+		return models.Order{}, err
+	}
+
+	if newStatus == models.StatusCancelled {
+		var shipments int64
+		if err := tx.Model(&models.Shipment{}).Where("order_id = ?", order.ID).Count(&shipments).Error; err != nil {
+			return models.Order{}, err
+		}
+		if shipments > 0 {
+			return models.Order{}, models.TransitionError{
+				From:   order.Status,
+				To:     newStatus,
+				Reason: "items of this order have been shipped, cancel the unshipped items instead",
+			}
+		}
+	}
+
+	transition, _ := order.Status.Transition(newStatus)
+	if transition.RequiresPayment && !order.PaymentStatus.IsPaid() {
+		return models.Order{}, models.TransitionError{
+			From:   order.Status,
+			To:     newStatus,
+			Reason: fmt.Sprintf("only paid orders can be %s, this order is %s", newStatus, order.PaymentStatus),
+		}
+	}
+
+	if err := tx.Where("order_id = ?", order.ID).Find(&order.Items).Error; err != nil {
+		return models.Order{}, err
+	}
+
+	for _, effect := range transition.Effects {
+		if err := applyTransitionEffect(tx, order, effect); err != nil {
+			return models.Order{}, err
+		}
+	}
+
+	if err := recordStatusEvent(tx, order.ID, order.Status, newStatus, actor.ID, note); err != nil {
+		return models.Order{}, err
+	}
+
+	if err := tx.Model(&order).Update("status", newStatus).Error; err != nil {
+		return models.Order{}, err
+	}
+
+	return order, nil
+}
+
+func recordStatusEvent(tx *gorm.DB, orderID uint, from models.OrderStatus, to models.OrderStatus, actorID uint, note string) error {
+	return tx.Create(&models.OrderStatusEvent{
+		OrderID:    orderID,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Note:       note,
+	}).Error
+}
+
+func applyTransitionEffect(tx *gorm.DB, order models.Order, effect models.TransitionEffect) error {
+	switch effect {
+	case models.EffectRestock:
+		return restockCancelledOrder(tx, order.Items)
+	case models.EffectVoidPayment:
+		return voidAuthorizedPayments(tx, order)
+	}
+	return fmt.Errorf("unknown transition effect: %s", effect)
+}
+
+// ProductHasVariants reports whether the product has orderable variants, in
+// which case orders and cart lines must name one
+func ProductHasVariants(productID uint) (bool, error) {
+	withVariants, err := productsWithVariants(initializers.DB, []uint{productID})
+	if err != nil {
+		return false, err
+	}
+	return withVariants[productID], nil
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// placeConcurrentOrders places n orders of one unit of the line at once and
+// returns how many succeeded; every failure must be an InsufficientStockError
+func placeConcurrentOrders(t *testing.T, userID uint, line OrderLine, n int) int {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			_, err := CreateOrder(userID, []OrderLine{line}, OrderOptions{})
+			errs <- err
+		}()
+	}
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		var stockErr InsufficientStockError
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.As(err, &stockErr):
+		default:
+			t.Errorf("unexpected error: %v", err)
+		}
+	}
+	return succeeded
+}
+
+func TestCreateOrderConcurrentStock(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t)
+
+	const stock, orders = 3, 10
+	product := models.Product{
+		Name:     "Concurrent product",
+		Price:    money.New(1000, money.BaseCurrency()),
+		Stock:    stock,
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+
+	succeeded := placeConcurrentOrders(t, user.ID, OrderLine{ProductID: product.ID, Quantity: 1}, orders)
+	if succeeded != stock {
+		t.Errorf("%d orders succeeded, want %d", succeeded, stock)
+	}
+
+	if err := initializers.DB.First(&product, product.ID).Error; err != nil {
+		t.Fatalf("reloading product: %v", err)
+	}
+	if product.Stock != 0 {
+		t.Errorf("stock is %d, want 0", product.Stock)
+	}
+}
+
+func TestCreateOrderConcurrentVariantStock(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t)
+
+	const stock, orders = 2, 8
+	product := models.Product{
+		Name:     "Concurrent product with variants",
+		Price:    money.New(1000, money.BaseCurrency()),
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	variant := models.ProductVariant{
+		ProductID:  product.ID,
+		SKU:        fmt.Sprintf("TEST-%d", time.Now().UnixNano()),
+		Attributes: models.VariantAttributes{"size": "M"},
+		Stock:      stock,
+		IsActive:   true,
+	}
+	if err := initializers.DB.Create(&variant).Error; err != nil {
+		t.Fatalf("creating variant: %v", err)
+	}
+
+	line := OrderLine{ProductID: product.ID, VariantID: variant.ID, Quantity: 1}
+	succeeded := placeConcurrentOrders(t, user.ID, line, orders)
+	if succeeded != stock {
+		t.Errorf("%d orders succeeded, want %d", succeeded, stock)
+	}
+
+	if err := initializers.DB.First(&variant, variant.ID).Error; err != nil {
+		t.Fatalf("reloading variant: %v", err)
+	}
+	if variant.Stock != 0 {
+		t.Errorf("variant stock is %d, want 0", variant.Stock)
+	}
+}
+
+func TestCheckOrderLines(t *testing.T) {
+	deletedAt := time.Now()
+	products := map[uint]models.Product{
+		1: {ID: 1, IsActive: true},
+		2: {ID: 2, IsActive: false},
+		3: {ID: 3, IsActive: true, DeletedAt: &deletedAt},
+		4: {ID: 4, IsActive: true},
+	}
+	variants := map[uint]models.ProductVariant{
+		10: {ID: 10, ProductID: 4, IsActive: true},
+		11: {ID: 11, ProductID: 4, IsActive: false},
+		12: {ID: 12, ProductID: 1, IsActive: true},
+	}
+	withVariants := map[uint]bool{4: true}
+
+	tests := []struct {
+		name string
+		line OrderLine
+		err  error
+	}{
+		{"active product", OrderLine{ProductID: 1, Quantity: 1}, nil},
+		{"unknown product", OrderLine{ProductID: 9, Quantity: 1}, ProductNotFoundError{ProductID: 9}},
+		{"inactive product", OrderLine{ProductID: 2, Quantity: 1}, ProductNotFoundError{ProductID: 2}},
+		{"deleted product", OrderLine{ProductID: 3, Quantity: 1}, ProductNotFoundError{ProductID: 3}},
+		{"variant", OrderLine{ProductID: 4, VariantID: 10, Quantity: 1}, nil},
+		{"no variant", OrderLine{ProductID: 4, Quantity: 1}, VariantError{}},
+		{"inactive variant", OrderLine{ProductID: 4, VariantID: 11, Quantity: 1}, VariantError{}},
+		{"other product's variant", OrderLine{ProductID: 4, VariantID: 12, Quantity: 1}, VariantError{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkOrderLines([]OrderLine{tt.line}, products, variants, withVariants)
+			switch want := tt.err.(type) {
+			case nil:
+				if err != nil {
+					t.Errorf("error = %v, want none", err)
+				}
+			case ProductNotFoundError:
+				if err != want {
+					t.Errorf("error = %v, want %v", err, want)
+				}
+			case VariantError:
+				var variantErr VariantError
+				if !errors.As(err, &variantErr) {
+					t.Errorf("error = %v, want a VariantError", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMergeOrderLines(t *testing.T) {
+	lines := []OrderLine{
+		{ProductID: 3, Quantity: 1},
+		{ProductID: 1, VariantID: 7, Quantity: 2},
+		{ProductID: 3, Quantity: 4},
+		{ProductID: 1, Quantity: 1},
+		{ProductID: 1, VariantID: 7, Quantity: 1},
+		{ProductID: 1, VariantID: 5, Quantity: 1},
+	}
+	want := []OrderLine{
+		{ProductID: 1, Quantity: 1},
+		{ProductID: 1, VariantID: 5, Quantity: 1},
+		{ProductID: 1, VariantID: 7, Quantity: 3},
+		{ProductID: 3, Quantity: 5},
+	}
+
+	got := mergeOrderLines(lines)
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("mergeOrderLines = %v, want %v", got, want)
+	}
+}
+
+func TestStockShortages(t *testing.T) {
+	products := map[uint]models.Product{
+		1: {ID: 1, Name: "Plain", Stock: 2},
+		2: {ID: 2, Name: "Sized"},
+	}
+	variants := map[uint]models.ProductVariant{
+		20: {ID: 20, ProductID: 2, SKU: "SIZED-S", Stock: 1},
+		21: {ID: 21, ProductID: 2, SKU: "SIZED-M", Stock: 5},
+	}
+
+	lines := []OrderLine{
+		{ProductID: 1, Quantity: 3},
+		{ProductID: 2, VariantID: 20, Quantity: 2},
+		{ProductID: 2, VariantID: 21, Quantity: 5},
+	}
+	want := []InsufficientStock{
+		{ProductID: 1, ProductName: "Plain", Requested: 3, Available: 2},
+		{ProductID: 2, VariantID: 20, SKU: "SIZED-S", ProductName: "Sized", Requested: 2, Available: 1},
+	}
+	if got := stockShortages(lines, products, variants); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("stockShortages = %+v, want %+v", got, want)
+	}
+
+	enough := []OrderLine{{ProductID: 1, Quantity: 2}, {ProductID: 2, VariantID: 21, Quantity: 5}}
+	if got := stockShortages(enough, products, variants); len(got) != 0 {
+		t.Errorf("stockShortages with enough stock = %+v, want none", got)
+	}
+}
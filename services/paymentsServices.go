@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentError is a payment operation the order's state doesn't allow
+type PaymentError struct {
+	Reason string
+}
+
+func (e PaymentError) Error() string {
+	return e.Reason
+}
+
+// PayOrder authorizes the order's total with the payment provider and, when
+// capture is set, captures it right away. When ownerID is not zero the order
+// must belong to that user.
+//
+// The order row stays locked while the provider is called, so two attempts to
+// pay the same order can't both go through. A declined payment is kept as a
+// failed payment and returned along with the payments.DeclinedError. When the
+// capture fails after a successful authorization, the authorized payment is
+// kept and can be captured later.
+func PayOrder(ctx context.Context, orderID uint, ownerID uint, token string, capture bool) (models.Payment, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, ownerID)
+	if err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	if order.Status != models.StatusPending && order.Status != models.StatusProcessing {
+		tx.Rollback()
+		return models.Payment{}, PaymentError{Reason: fmt.Sprintf("cannot pay a %s order", order.Status)}
+	}
+	if order.PaymentStatus != models.OrderUnpaid {
+		tx.Rollback()
+		return models.Payment{}, PaymentError{Reason: fmt.Sprintf("order is already %s", order.PaymentStatus)}
+	}
+
+	provider := initializers.Payments
+	payment := models.Payment{
+		OrderID:  order.ID,
+		Provider: provider.Name(),
+		Status:   models.PaymentPending,
+		Amount:   order.TotalAmount,
+	}
+	if err := tx.Create(&payment).Error; err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	result, err := provider.Authorize(ctx, payments.AuthorizeRequest{
+		PaymentID: payment.ID,
+		OrderID:   order.ID,
+		Amount:    payment.Amount,
+		Token:     token,
+	})
+	if err != nil {
+		var declined payments.DeclinedError
+		if !errors.As(err, &declined) {
+			tx.Rollback()
+			return models.Payment{}, err
+		}
+
+		payment.Status = models.PaymentFailed
+		payment.FailureReason = declined.Message
+		if err := tx.Save(&payment).Error; err != nil {
+			tx.Rollback()
+			return models.Payment{}, err
+		}
+		if err := tx.Commit().Error; err != nil {
+			return models.Payment{}, err
+		}
+		return payment, declined
+	}
+
+	now := time.Now()
+	payment.Reference = result.Reference
+	payment.Status = models.PaymentAuthorized
+	payment.AuthorizedAt = &now
+	if err := tx.Save(&payment).Error; err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	var captureErr error
+	if capture {
+		captureErr = capturePayment(ctx, tx, &payment)
+		var declined payments.DeclinedError
+		if captureErr != nil && !errors.As(captureErr, &declined) {
+			// the authorization stands at the provider whatever happened to
+			// the capture, so it is recorded all the same
+			captureErr = fmt.Errorf("capture failed: %w", captureErr)
+		}
+	}
+
+	if err := syncOrderPaymentStatus(tx, &order); err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Payment{}, err
+	}
+
+	return payment, captureErr
+}
+
+// CapturePayment takes the money of the order's authorized payment
+func CapturePayment(ctx context.Context, orderID uint) (models.Payment, error) {
+	return changeAuthorizedPayment(ctx, orderID, capturePayment)
+}
+
+// VoidPayment releases the order's authorized payment
+func VoidPayment(ctx context.Context, orderID uint) (models.Payment, error) {
+	return changeAuthorizedPayment(ctx, orderID, voidPayment)
+}
+
+func changeAuthorizedPayment(ctx context.Context, orderID uint, change func(context.Context, *gorm.DB, *models.Payment) error) (models.Payment, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	var payment models.Payment
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, models.PaymentAuthorized).
+		Order("id DESC").First(&payment).Error; err != nil {
+		tx.Rollback()
+		if err == gorm.ErrRecordNotFound {
+			return models.Payment{}, PaymentError{Reason: "order has no authorized payment"}
+		}
+		return models.Payment{}, err
+	}
+
+	if err := change(ctx, tx, &payment); err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	if err := syncOrderPaymentStatus(tx, &order); err != nil {
+		tx.Rollback()
+		return models.Payment{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Payment{}, err
+	}
+
+	return payment, nil
+}
+
+func capturePayment(ctx context.Context, tx *gorm.DB, payment *models.Payment) error {
+	if _, err := initializers.Payments.Capture(ctx, payment.Reference, payment.Amount); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentCaptured
+	payment.CapturedAt = &now
+	return tx.Save(payment).Error
+}
+
+func voidPayment(ctx context.Context, tx *gorm.DB, payment *models.Payment) error {
+	if _, err := initializers.Payments.Void(ctx, payment.Reference); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	payment.Status = models.PaymentVoided
+	payment.VoidedAt = &now
+	return tx.Save(payment).Error
+}
+
+// voidAuthorizedPayments releases every authorized payment of the order, for
+// orders that are cancelled before their payment is captured
+func voidAuthorizedPayments(tx *gorm.DB, order models.Order) error {
+	var authorized []models.Payment
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, models.PaymentAuthorized).Find(&authorized).Error; err != nil {
+		return err
+	}
+	if len(authorized) == 0 {
+		return nil
+	}
+
+	for i := range authorized {
+		if err := voidPayment(context.Background(), tx, &authorized[i]); err != nil {
+			return err
+		}
+	}
+	return syncOrderPaymentStatus(tx, &order)
+}
+
+// HandlePaymentWebhook applies a verified provider event to the payment it
+// is about. Every event is applied once; events that don't move the payment
+// forward (e.g. a late "authorized" for a captured payment) are ignored.
+// An event for an unknown payment is an error, so that the provider retries
+// it in case it arrived before the payment was saved.
+func HandlePaymentWebhook(provider string, event payments.WebhookEvent) error {
+	tx := initializers.DB.Begin()
+
+	var payment models.Payment
+	if err := tx.Where("provider = ? AND reference = ?", provider, event.Reference).First(&payment).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.PaymentWebhookEvent{
+		Provider:  provider,
+		EventID:   event.ID,
+		Type:      string(event.Type),
+		Reference: event.Reference,
+	})
+	if result.Error != nil {
+		tx.Rollback()
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// already handled
+		tx.Rollback()
+		return nil
+	}
+
+	order, err := lockOrder(tx, payment.OrderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	// reloaded under the order lock, the payment may have moved meanwhile
+	if err := tx.First(&payment, payment.ID).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	applyPaymentEvent(&payment, event, time.Now())
+
+	if err := tx.Save(&payment).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := syncOrderPaymentStatus(tx, &order); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// applyPaymentEvent moves the payment forward by the event. Events that
+// don't apply to the payment's status leave it as it is.
+func applyPaymentEvent(payment *models.Payment, event payments.WebhookEvent, now time.Time) {
+	open := payment.Status == models.PaymentPending || payment.Status == models.PaymentAuthorized
+	switch event.Type {
+	case payments.EventAuthorized:
+		if payment.Status == models.PaymentPending {
+			payment.Status = models.PaymentAuthorized
+			payment.AuthorizedAt = &now
+		}
+	case payments.EventCaptured:
+		if open {
+			payment.Status = models.PaymentCaptured
+			payment.CapturedAt = &now
+		}
+	case payments.EventVoided:
+		if open {
+			payment.Status = models.PaymentVoided
+			payment.VoidedAt = &now
+		}
+	case payments.EventFailed:
+		if open {
+			payment.Status = models.PaymentFailed
+			payment.FailureReason = event.FailureReason
+		}
+	case payments.EventRefunded:
+		// refunds are recorded by RefundOrder when they are made
+	}
+}
+
+// syncOrderPaymentStatus derives the order's payment status from its
+// payments and refunded total, and saves it
+func syncOrderPaymentStatus(tx *gorm.DB, order *models.Order) error {
+	var orderPayments []models.Payment
+	if err := tx.Where("order_id = ? AND status IN ?", order.ID, []models.PaymentStatus{models.PaymentAuthorized, models.PaymentCaptured}).
+		Find(&orderPayments).Error; err != nil {
+		return err
+	}
+
+	status, err := orderPaymentStatus(orderPayments, order.RefundedTotal, order.Currency)
+	if err != nil {
+		return err
+	}
+
+	order.PaymentStatus = status
+	return tx.Model(order).Update("payment_status", status).Error
+}
+
+// orderPaymentStatus is the payment status of an order with the authorized
+// and captured payments and refunded total
+func orderPaymentStatus(orderPayments []models.Payment, refunded money.Money, currency string) (models.OrderPaymentStatus, error) {
+	status := models.OrderUnpaid
+	captured := money.New(0, currency)
+	for _, payment := range orderPayments {
+		switch payment.Status {
+		case models.PaymentCaptured:
+			status = models.OrderPaid
+			var err error
+			if captured, err = captured.Add(payment.Amount); err != nil {
+				return "", err
+			}
+		case models.PaymentAuthorized:
+			if status == models.OrderUnpaid {
+				status = models.OrderPaymentAuthorized
+			}
+		}
+	}
+	if status == models.OrderPaid && refunded.IsPositive() {
+		status = models.OrderPartiallyRefunded
+		cmp, err := refunded.Cmp(captured)
+		if err != nil {
+			return "", err
+		}
+		if cmp >= 0 {
+			status = models.OrderRefunded
+		}
+	}
+	return status, nil
+}
+
+// lockOrder loads the order and locks its row until the end of the
+// transaction. When ownerID is not zero the order must belong to that user.
+func lockOrder(tx *gorm.DB, orderID uint, ownerID uint) (models.Order, error) {
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", orderID)
+	if ownerID != 0 {
+		query = query.Where("user_id = ?", ownerID)
+	}
+
+	var order models.Order
+	if err := query.First(&order).Error; err != nil {
+		return models.Order{}, err
+	}
+	return order, nil
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+)
+
+// useMockPayments points initializers.Payments at the mock gateway for the
+// test
+func useMockPayments(t *testing.T) {
+	t.Helper()
+
+	previous := initializers.Payments
+	initializers.Payments = payments.NewMockProvider("secret")
+	t.Cleanup(func() { initializers.Payments = previous })
+}
+
+func requirePaymentStatus(t *testing.T, orderID uint, want models.OrderPaymentStatus) {
+	t.Helper()
+
+	var order models.Order
+	if err := initializers.DB.First(&order, orderID).Error; err != nil {
+		t.Fatalf("reloading order: %v", err)
+	}
+	if order.PaymentStatus != want {
+		t.Fatalf("payment status is %s, want %s", order.PaymentStatus, want)
+	}
+}
+
+func TestApplyPaymentEvent(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		status models.PaymentStatus
+		event  payments.EventType
+		want   models.PaymentStatus
+	}{
+		{models.PaymentPending, payments.EventAuthorized, models.PaymentAuthorized},
+		{models.PaymentPending, payments.EventCaptured, models.PaymentCaptured},
+		{models.PaymentAuthorized, payments.EventCaptured, models.PaymentCaptured},
+		{models.PaymentAuthorized, payments.EventVoided, models.PaymentVoided},
+		{models.PaymentAuthorized, payments.EventFailed, models.PaymentFailed},
+		// late or out of order events are ignored
+		{models.PaymentCaptured, payments.EventAuthorized, models.PaymentCaptured},
+		{models.PaymentAuthorized, payments.EventAuthorized, models.PaymentAuthorized},
+		{models.PaymentCaptured, payments.EventVoided, models.PaymentCaptured},
+		{models.PaymentVoided, payments.EventCaptured, models.PaymentVoided},
+		{models.PaymentFailed, payments.EventCaptured, models.PaymentFailed},
+		{models.PaymentCaptured, payments.EventRefunded, models.PaymentCaptured},
+	}
+	for _, tt := range tests {
+		payment := models.Payment{Status: tt.status}
+		applyPaymentEvent(&payment, payments.WebhookEvent{Type: tt.event, FailureReason: "expired card"}, now)
+		if payment.Status != tt.want {
+			t.Errorf("%s payment after %s is %s, want %s", tt.status, tt.event, payment.Status, tt.want)
+		}
+	}
+
+	payment := models.Payment{Status: models.PaymentAuthorized}
+	applyPaymentEvent(&payment, payments.WebhookEvent{Type: payments.EventCaptured}, now)
+	if payment.CapturedAt == nil || !payment.CapturedAt.Equal(now) {
+		t.Errorf("captured at = %v, want %v", payment.CapturedAt, now)
+	}
+	payment = models.Payment{Status: models.PaymentPending}
+	applyPaymentEvent(&payment, payments.WebhookEvent{Type: payments.EventFailed, FailureReason: "expired card"}, now)
+	if payment.FailureReason != "expired card" {
+		t.Errorf("failure reason = %q, want the event's", payment.FailureReason)
+	}
+}
+
+func TestOrderPaymentStatus(t *testing.T) {
+	authorized := models.Payment{Status: models.PaymentAuthorized, Amount: money.New(1000, "USD")}
+	captured := models.Payment{Status: models.PaymentCaptured, Amount: money.New(1000, "USD")}
+
+	tests := []struct {
+		name     string
+		payments []models.Payment
+		refunded int64
+		want     models.OrderPaymentStatus
+	}{
+		{"no payments", nil, 0, models.OrderUnpaid},
+		{"authorized", []models.Payment{authorized}, 0, models.OrderPaymentAuthorized},
+		{"captured", []models.Payment{captured}, 0, models.OrderPaid},
+		{"captured and authorized", []models.Payment{authorized, captured}, 0, models.OrderPaid},
+		{"partly refunded", []models.Payment{captured}, 400, models.OrderPartiallyRefunded},
+		{"refunded", []models.Payment{captured}, 1000, models.OrderRefunded},
+		{"refunded of two captures", []models.Payment{captured, captured}, 1000, models.OrderPartiallyRefunded},
+	}
+	for _, tt := range tests {
+		got, err := orderPaymentStatus(tt.payments, money.New(tt.refunded, "USD"), "USD")
+		if err != nil || got != tt.want {
+			t.Errorf("%s: status = %s, %v, want %s", tt.name, got, err, tt.want)
+		}
+	}
+
+	other := models.Payment{Status: models.PaymentCaptured, Amount: money.New(1000, "EUR")}
+	if _, err := orderPaymentStatus([]models.Payment{other}, money.New(0, "USD"), "USD"); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("payment in another currency error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestPayOrder(t *testing.T) {
+	useTestDB(t)
+	useMockPayments(t)
+	ctx := context.Background()
+
+	user := createTestUser(t)
+	admin := user
+	admin.Role = models.UserRoleAdmin
+
+	product := models.Product{
+		Name:     "Payment product",
+		Price:    money.New(1000, money.BaseCurrency()),
+		Stock:    5,
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	order, err := CreateOrder(user.ID, []OrderLine{{ProductID: product.ID, Quantity: 2}}, OrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	requirePaymentStatus(t, order.ID, models.OrderUnpaid)
+
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusProcessing, admin, ""); err != nil {
+		t.Fatalf("moving to processing: %v", err)
+	}
+
+	// an unpaid order can't be shipped
+	var transitionErr models.TransitionError
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusShipped, admin, ""); !errors.As(err, &transitionErr) {
+		t.Fatalf("shipping an unpaid order: error = %v, want a TransitionError", err)
+	}
+
+	// someone else's order is not found
+	other := createTestUser(t)
+	if _, err := PayOrder(ctx, order.ID, other.ID, "tok_visa", true); err == nil {
+		t.Fatal("paying another user's order succeeded")
+	}
+
+	// a declined payment is kept as failed and leaves the order unpaid
+	payment, err := PayOrder(ctx, order.ID, user.ID, payments.MockTokenDeclined, true)
+	var declined payments.DeclinedError
+	if !errors.As(err, &declined) {
+		t.Fatalf("declined PayOrder: error = %v, want a DeclinedError", err)
+	}
+	if payment.Status != models.PaymentFailed || payment.FailureReason == "" {
+		t.Errorf("declined payment = %+v", payment)
+	}
+	requirePaymentStatus(t, order.ID, models.OrderUnpaid)
+
+	// an authorization alone doesn't pay the order
+	payment, err = PayOrder(ctx, order.ID, user.ID, "tok_visa", false)
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if payment.Status != models.PaymentAuthorized || payment.Reference == "" || payment.Amount != money.New(2000, money.BaseCurrency()) {
+		t.Errorf("authorized payment = %+v", payment)
+	}
+	requirePaymentStatus(t, order.ID, models.OrderPaymentAuthorized)
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusShipped, admin, ""); !errors.As(err, &transitionErr) {
+		t.Fatalf("shipping an authorized order: error = %v, want a TransitionError", err)
+	}
+
+	var paymentErr PaymentError
+	if _, err := PayOrder(ctx, order.ID, user.ID, "tok_visa", true); !errors.As(err, &paymentErr) {
+		t.Fatalf("paying an authorized order again: error = %v, want a PaymentError", err)
+	}
+
+	payment, err = CapturePayment(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("CapturePayment: %v", err)
+	}
+	if payment.Status != models.PaymentCaptured || payment.CapturedAt == nil {
+		t.Errorf("captured payment = %+v", payment)
+	}
+	requirePaymentStatus(t, order.ID, models.OrderPaid)
+
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusShipped, admin, ""); err != nil {
+		t.Fatalf("shipping a paid order: %v", err)
+	}
+	if _, err := PayOrder(ctx, order.ID, user.ID, "tok_visa", true); !errors.As(err, &paymentErr) {
+		t.Fatalf("paying a shipped order: error = %v, want a PaymentError", err)
+	}
+}
+
+func TestPayOrderCapture(t *testing.T) {
+	useTestDB(t)
+	useMockPayments(t)
+
+	user := createTestUser(t)
+	product := models.Product{
+		Name:     "Captured product",
+		Price:    money.New(500, money.BaseCurrency()),
+		Stock:    1,
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	order, err := CreateOrder(user.ID, []OrderLine{{ProductID: product.ID, Quantity: 1}}, OrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	payment, err := PayOrder(context.Background(), order.ID, user.ID, "tok_visa", true)
+	if err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if payment.Status != models.PaymentCaptured || payment.AuthorizedAt == nil || payment.CapturedAt == nil {
+		t.Errorf("payment = %+v", payment)
+	}
+	requirePaymentStatus(t, order.ID, models.OrderPaid)
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// UnsupportedCurrencyError is returned for a currency without an exchange rate
+type UnsupportedCurrencyError struct {
+	Currency string
+}
+
+func (e UnsupportedCurrencyError) Error() string {
+	return fmt.Sprintf("currency %s is not supported", e.Currency)
+}
+
+// Pricer prices products in one currency: an explicit product price in that
+// currency when there is one, otherwise the base price converted at the
+// exchange rate. A variant's own price is always converted.
+type Pricer struct {
+	Currency string
+	Rate     *big.Rat
+	explicit map[uint]money.Money
+}
+
+// NewPricer loads the exchange rate of currency and the explicit prices of
+// the given products in it
+func NewPricer(db *gorm.DB, currency string, productIDs []uint) (Pricer, error) {
+	currency, err := money.NormalizeCurrency(currency)
+	if err != nil {
+		return Pricer{}, err
+	}
+
+	pricer := Pricer{Currency: currency, Rate: big.NewRat(1, 1), explicit: map[uint]money.Money{}}
+	if currency == money.BaseCurrency() {
+		return pricer, nil
+	}
+
+	var rate models.ExchangeRate
+	if err := db.Where("currency = ?", currency).First(&rate).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return Pricer{}, UnsupportedCurrencyError{Currency: currency}
+		}
+		return Pricer{}, err
+	}
+	if pricer.Rate, err = rate.Ratio(); err != nil {
+		return Pricer{}, err
+	}
+
+	if err := pricer.loadExplicitPrices(db, productIDs); err != nil {
+		return Pricer{}, err
+	}
+
+	return pricer, nil
+}
+
+// OrderPricer prices products for a placed order: in its currency, at the
+// exchange rate locked on it
+func OrderPricer(db *gorm.DB, order models.Order, productIDs []uint) (Pricer, error) {
+	rate, err := money.ParseRate(order.ExchangeRate)
+	if err != nil {
+		return Pricer{}, err
+	}
+
+	pricer := Pricer{Currency: order.Currency, Rate: rate, explicit: map[uint]money.Money{}}
+	if order.Currency == money.BaseCurrency() {
+		return pricer, nil
+	}
+
+	if err := pricer.loadExplicitPrices(db, productIDs); err != nil {
+		return Pricer{}, err
+	}
+
+	return pricer, nil
+}
+
+func (p Pricer) loadExplicitPrices(db *gorm.DB, productIDs []uint) error {
+	if len(productIDs) == 0 {
+		return nil
+	}
+
+	var prices []models.ProductPrice
+	if err := db.Where("product_id IN ? AND price_currency = ?", productIDs, p.Currency).Find(&prices).Error; err != nil {
+		return err
+	}
+	for _, price := range prices {
+		p.explicit[price.ProductID] = price.Price
+	}
+	return nil
+}
+
+// Price is the unit price of the product, or of the variant when not nil
+func (p Pricer) Price(product models.Product, variant *models.ProductVariant) money.Money {
+	if variant != nil && variant.Price.IsSet() {
+		return p.Convert(variant.Price)
+	}
+	if price, ok := p.explicit[product.ID]; ok {
+		return price
+	}
+	return p.Convert(product.Price)
+}
+
+// Convert converts a base currency amount to the pricer's currency
+func (p Pricer) Convert(amount money.Money) money.Money {
+	if amount.Currency == p.Currency {
+		return amount
+	}
+	return amount.Convert(p.Currency, p.Rate)
+}
+
+// RateString is the exchange rate as stored on orders
+func (p Pricer) RateString() string {
+	return p.Rate.FloatString(10)
+}
+
+// SupportedCurrencies lists the base currency followed by every currency with
+// an exchange rate
+func SupportedCurrencies(db *gorm.DB) ([]string, error) {
+	var currencies []string
+	if err := db.Model(&models.ExchangeRate{}).Order("currency ASC").Pluck("currency", &currencies).Error; err != nil {
+		return nil, err
+	}
+	return append([]string{money.BaseCurrency()}, currencies...), nil
+}
@@ -0,0 +1,453 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/libs"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxImportSize = 20 << 20 // 20 MiB
+
+	// progress is written to the job every importProgressInterval rows
+	importProgressInterval = 100
+	// only the first maxImportRowErrors row errors are kept on the job
+	maxImportRowErrors = 1000
+)
+
+// ProductImportColumns are the CSV columns understood by the import, in the
+// order the export writes them. category_ids are separated by "|".
+var ProductImportColumns = []string{"sku", "name", "description", "price", "stock", "is_active", "category_ids"}
+
+// ProductImportRow is one product of an import file. Pointers tell a missing
+// value apart from a zero one.
+type ProductImportRow struct {
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       money.Money `json:"price"`
+	Stock       int         `json:"stock"`
+	IsActive    *bool       `json:"is_active"`
+	CategoryIDs *[]uint     `json:"category_ids"` // replaces the categories when present
+}
+
+// parsedRow is a row as read from the file with the errors found parsing it;
+// rows that failed to parse still count towards the total
+type parsedRow struct {
+	row    ProductImportRow
+	errors []models.ImportRowError
+}
+
+// MaxImportSize reads MAX_IMPORT_SIZE (bytes), falling back to 20 MiB
+func MaxImportSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("MAX_IMPORT_SIZE"), 10, 64); err == nil && value > 0 {
+		return value
+	}
+	return defaultMaxImportSize
+}
+
+// StartProductImport records a queued import job and processes data in the
+// background. validate applies the product input rules to a row.
+func StartProductImport(userID uint, filename, format string, data []byte, validate func(ProductImportRow) error) (models.ProductImportJob, error) {
+	job := models.ProductImportJob{
+		UserID:   userID,
+		Filename: filename,
+		Format:   format,
+		Status:   models.ImportJobQueued,
+		Errors:   models.ImportRowErrors{},
+	}
+	if err := initializers.DB.Create(&job).Error; err != nil {
+		return job, err
+	}
+
+	go runProductImport(job, data, validate)
+
+	return job, nil
+}
+
+// FailInterruptedImports marks jobs left queued or running by a previous
+// process as failed; their uploads are gone and they can't be resumed
+func FailInterruptedImports() {
+	now := time.Now()
+	err := initializers.DB.Model(&models.ProductImportJob{}).
+		Where("status IN ?", []models.ImportJobStatus{models.ImportJobQueued, models.ImportJobRunning}).
+		Updates(map[string]interface{}{
+			"status":      models.ImportJobFailed,
+			"error":       "the import was interrupted by a server restart, upload the file again",
+			"finished_at": now,
+		}).Error
+	if err != nil {
+		log.Println("Failed to mark interrupted imports", err)
+	}
+}
+
+func runProductImport(job models.ProductImportJob, data []byte, validate func(ProductImportRow) error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Println("Product import panicked", job.ID, r)
+			finishImport(&job, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	now := time.Now()
+	job.Status = models.ImportJobRunning
+	job.StartedAt = &now
+	saveImportProgress(&job)
+
+	var rows []parsedRow
+	var err error
+	if job.Format == "csv" {
+		rows, err = parseCSVImport(data)
+	} else {
+		rows, err = parseNDJSONImport(data)
+	}
+	if err != nil {
+		finishImport(&job, err.Error())
+		return
+	}
+
+	job.TotalRows = len(rows)
+	saveImportProgress(&job)
+
+	for i, parsed := range rows {
+		rowNumber := i + 1
+		rowErrors := parsed.errors
+		created := false
+
+		if len(rowErrors) == 0 {
+			rowErrors = validateImportRow(rowNumber, parsed.row, validate)
+		}
+		if len(rowErrors) == 0 {
+			var err error
+			if created, err = importProductRow(parsed.row); err != nil {
+				rowErrors = []models.ImportRowError{{Row: rowNumber, Message: err.Error()}}
+			}
+		}
+
+		switch {
+		case len(rowErrors) > 0:
+			job.FailedCount++
+			if room := maxImportRowErrors - len(job.Errors); room > 0 {
+				job.Errors = append(job.Errors, rowErrors[:min(room, len(rowErrors))]...)
+			}
+		case created:
+			job.CreatedCount++
+		default:
+			job.UpdatedCount++
+		}
+
+		job.ProcessedRows++
+		if job.ProcessedRows%importProgressInterval == 0 {
+			saveImportProgress(&job)
+		}
+	}
+
+	finishImport(&job, "")
+}
+
+func saveImportProgress(job *models.ProductImportJob) {
+	if err := initializers.DB.Save(job).Error; err != nil {
+		log.Println("Failed to save import progress", job.ID, err)
+	}
+}
+
+func finishImport(job *models.ProductImportJob, failure string) {
+	now := time.Now()
+	job.FinishedAt = &now
+	job.Status = models.ImportJobCompleted
+	if failure != "" {
+		job.Status = models.ImportJobFailed
+		job.Error = failure
+	}
+	saveImportProgress(job)
+}
+
+func validateImportRow(rowNumber int, row ProductImportRow, validate func(ProductImportRow) error) []models.ImportRowError {
+	err := validate(row)
+	if err == nil {
+		return nil
+	}
+
+	var rowErrors []models.ImportRowError
+	for _, validationError := range libs.NewValidationError(err).Errors {
+		rowErrors = append(rowErrors, models.ImportRowError{
+			Row:     rowNumber,
+			Field:   validationError.Field,
+			Message: validationError.Message,
+		})
+	}
+	if len(rowErrors) == 0 {
+		rowErrors = append(rowErrors, models.ImportRowError{Row: rowNumber, Message: err.Error()})
+	}
+	return rowErrors
+}
+
+// importProductRow creates the product or updates the one with the same SKU,
+// or without a SKU, the same name. It reports whether a product was created.
+func importProductRow(row ProductImportRow) (bool, error) {
+	var categories []models.Category
+	if row.CategoryIDs != nil {
+		var missing []uint
+		var err error
+		if categories, missing, err = LoadCategories(*row.CategoryIDs); err != nil {
+			return false, err
+		}
+		if len(missing) > 0 {
+			return false, fmt.Errorf("categories not found: %v", missing)
+		}
+	}
+
+	tx := initializers.DB.Begin()
+
+	product, found, err := findImportedProduct(tx, row)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+
+	if found {
+		// only the imported columns are written: a full save would also
+		// write back columns read above that orders may have changed since
+		updates := map[string]interface{}{
+			"name":           row.Name,
+			"description":    row.Description,
+			"price_minor":    row.Price.Amount,
+			"price_currency": row.Price.Currency,
+			"stock":          row.Stock,
+		}
+		if row.SKU != "" {
+			updates["sku"] = row.SKU
+		}
+		if row.IsActive != nil {
+			updates["is_active"] = *row.IsActive
+		}
+		if err := tx.Model(&product).Updates(updates).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	} else {
+		if row.SKU != "" {
+			sku := row.SKU
+			product.SKU = &sku
+		}
+		product.Name = row.Name
+		product.Description = row.Description
+		product.Price = row.Price
+		product.Stock = row.Stock
+		product.IsActive = row.IsActive == nil || *row.IsActive
+		if err := tx.Create(&product).Error; err != nil {
+			tx.Rollback()
+			return false, err
+		}
+
+		// is_active defaults to true in the database, so creating a product
+		// ignores a false value
+		if !product.IsActive {
+			if err := tx.Model(&product).Update("is_active", false).Error; err != nil {
+				tx.Rollback()
+				return false, err
+			}
+		}
+	}
+
+	if row.CategoryIDs != nil {
+		if err := tx.Model(&product).Association("Categories").Replace(categories); err != nil {
+			tx.Rollback()
+			return false, err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return false, err
+	}
+	return !found, nil
+}
+
+func findImportedProduct(tx *gorm.DB, row ProductImportRow) (models.Product, bool, error) {
+	var product models.Product
+
+	if row.SKU != "" {
+		// deleted products keep their SKU, so they are looked up as well
+		err := tx.Where("sku = ?", row.SKU).First(&product).Error
+		if err == gorm.ErrRecordNotFound {
+			return models.Product{}, false, nil
+		}
+		if err != nil {
+			return product, false, err
+		}
+		if product.DeletedAt != nil {
+			return product, false, fmt.Errorf("sku %q belongs to a deleted product", row.SKU)
+		}
+		return product, true, nil
+	}
+
+	var matches []models.Product
+	if err := tx.Where("LOWER(name) = LOWER(?) AND deleted_at IS NULL", row.Name).
+		Limit(2).Find(&matches).Error; err != nil {
+		return product, false, err
+	}
+	switch len(matches) {
+	case 0:
+		return models.Product{}, false, nil
+	case 1:
+		return matches[0], true, nil
+	default:
+		return product, false, fmt.Errorf("several products are named %q, add a sku to choose one", row.Name)
+	}
+}
+
+func parseCSVImport(data []byte) ([]parsedRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("the file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv: %w", err)
+	}
+
+	known := make(map[string]bool, len(ProductImportColumns))
+	for _, column := range ProductImportColumns {
+		known[column] = true
+	}
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if !known[column] {
+			return nil, fmt.Errorf("unknown column %q, allowed columns are: %s", column, strings.Join(ProductImportColumns, ", "))
+		}
+		columns[column] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, errors.New("the name column is required")
+	}
+
+	var rows []parsedRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+
+		// a broken quote ruins the rest of the file, so give up on it
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv: %w", err)
+		}
+
+		rows = append(rows, parseCSVRecord(len(rows)+1, record, columns))
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+	return rows, nil
+}
+
+func parseCSVRecord(rowNumber int, record []string, columns map[string]int) parsedRow {
+	var parsed parsedRow
+	value := func(column string) (string, bool) {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return "", false
+		}
+		return strings.TrimSpace(record[i]), true
+	}
+	fail := func(field, message string) {
+		parsed.errors = append(parsed.errors, models.ImportRowError{Row: rowNumber, Field: field, Message: message})
+	}
+
+	parsed.row.SKU, _ = value("sku")
+	parsed.row.Name, _ = value("name")
+	parsed.row.Description, _ = value("description")
+
+	if raw, ok := value("price"); ok && raw != "" {
+		price, err := money.Parse(raw, money.BaseCurrency())
+		if err != nil {
+			fail("price", err.Error())
+		}
+		parsed.row.Price = price
+	}
+	if raw, ok := value("stock"); ok && raw != "" {
+		stock, err := strconv.Atoi(raw)
+		if err != nil {
+			fail("stock", "stock must be a whole number")
+		}
+		parsed.row.Stock = stock
+	}
+	if raw, ok := value("is_active"); ok && raw != "" {
+		active, err := strconv.ParseBool(raw)
+		if err != nil {
+			fail("is_active", "is_active must be true or false")
+		}
+		parsed.row.IsActive = &active
+	}
+	if raw, ok := value("category_ids"); ok {
+		ids := []uint{}
+		for _, part := range strings.Split(raw, "|") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			id, err := strconv.ParseUint(part, 10, 64)
+			if err != nil {
+				fail("category_ids", "category_ids must be numbers separated by |")
+				break
+			}
+			ids = append(ids, uint(id))
+		}
+		parsed.row.CategoryIDs = &ids
+	}
+
+	return parsed
+}
+
+func parseNDJSONImport(data []byte) ([]parsedRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(data)+1)
+
+	var rows []parsedRow
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var parsed parsedRow
+		decoder := json.NewDecoder(bytes.NewReader(line))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&parsed.row); err != nil {
+			parsed.errors = []models.ImportRowError{{Row: len(rows) + 1, Message: "invalid json: " + err.Error()}}
+		}
+		if parsed.errors == nil && parsed.row.Price.IsSet() && parsed.row.Price.Currency != money.BaseCurrency() {
+			parsed.errors = []models.ImportRowError{{Row: len(rows) + 1, Field: "price", Message: "prices must be in " + money.BaseCurrency()}}
+		}
+		parsed.row.SKU = strings.TrimSpace(parsed.row.SKU)
+		parsed.row.Name = strings.TrimSpace(parsed.row.Name)
+		rows = append(rows, parsed)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("invalid ndjson: %w", err)
+	}
+
+	if len(rows) == 0 {
+		return nil, errors.New("the file has no rows")
+	}
+	return rows, nil
+}
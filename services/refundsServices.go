@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+	"gorm.io/gorm"
+)
+
+// RefundLine asks to refund units of one order item
+type RefundLine struct {
+	OrderItemID uint
+	Quantity    int
+	Restock     bool // put the units back into stock
+}
+
+// RefundOptions describe a refund. Without lines or amount everything not
+// refunded yet is refunded.
+type RefundOptions struct {
+	Lines   []RefundLine
+	Amount  money.Money // when set, refunded instead of what the lines were charged, or alone
+	Restock bool        // restocks every unit of a full refund
+	Reason  string
+}
+
+// RefundError is a refund the order doesn't allow
+type RefundError struct {
+	Field  string
+	Reason string
+}
+
+func (e RefundError) Error() string {
+	return e.Reason
+}
+
+// RefundOrder refunds part or all of an order's captured payment through the
+// provider that captured it. Unless an amount is given, the lines are refunded
+// at what they were charged, discounts and exclusive taxes included, and the
+// last unit of a line gets what rounding left over. Refunds never exceed the
+// captured amount minus earlier refunds.
+//
+// The order row stays locked while the provider is called. A declined refund
+// is kept as a failed refund and returned along with the
+// payments.DeclinedError; nothing else changes then.
+func RefundOrder(ctx context.Context, orderID uint, options RefundOptions, actor models.User) (models.Refund, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return models.Refund{}, err
+	}
+
+	refund, err := RefundOrderTx(ctx, tx, &order, options, actor)
+	var declined payments.DeclinedError
+	if err != nil && !errors.As(err, &declined) {
+		tx.Rollback()
+		return models.Refund{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Refund{}, err
+	}
+
+	return refund, err
+}
+
+// RefundOrderTx is RefundOrder inside the caller's transaction, for an order
+// the caller has locked. A declined refund is saved as failed and returned
+// with the payments.DeclinedError, for the caller to commit.
+func RefundOrderTx(ctx context.Context, tx *gorm.DB, order *models.Order, options RefundOptions, actor models.User) (models.Refund, error) {
+	refund, payment, err := buildRefund(tx, *order, options)
+	if err != nil {
+		return models.Refund{}, err
+	}
+	refund.ActorID = actor.ID
+
+	if err := tx.Create(&refund).Error; err != nil {
+		return models.Refund{}, err
+	}
+
+	result, err := initializers.Payments.Refund(ctx, payments.RefundRequest{
+		RefundID:  refund.ID,
+		Reference: payment.Reference,
+		Amount:    refund.Amount,
+	})
+	if err != nil {
+		var declined payments.DeclinedError
+		if !errors.As(err, &declined) {
+			return models.Refund{}, err
+		}
+
+		refund.Status = models.RefundFailed
+		refund.FailureReason = declined.Message
+		if err := tx.Model(&refund).Updates(map[string]interface{}{
+			"status":         refund.Status,
+			"failure_reason": refund.FailureReason,
+		}).Error; err != nil {
+			return models.Refund{}, err
+		}
+		return refund, declined
+	}
+
+	refund.Status = models.RefundSucceeded
+	refund.Reference = result.Reference
+	if err := tx.Model(&refund).Updates(map[string]interface{}{
+		"status":    refund.Status,
+		"reference": refund.Reference,
+	}).Error; err != nil {
+		return models.Refund{}, err
+	}
+
+	if err := applyRefund(tx, order, refund); err != nil {
+		return models.Refund{}, err
+	}
+
+	return refund, nil
+}
+
+// buildRefund checks the options against the order and what is left to
+// refund, and returns the refund to make and the payment it refunds
+func buildRefund(tx *gorm.DB, order models.Order, options RefundOptions) (models.Refund, models.Payment, error) {
+	if !order.PaymentStatus.IsPaid() {
+		return models.Refund{}, models.Payment{}, RefundError{
+			Field:  "order",
+			Reason: fmt.Sprintf("only paid orders can be refunded, this order is %s", order.PaymentStatus),
+		}
+	}
+
+	var payment models.Payment
+	if err := tx.Where("order_id = ? AND status = ?", order.ID, models.PaymentCaptured).
+		Order("id DESC").First(&payment).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "order", Reason: "order was not paid through the payment provider"}
+		}
+		return models.Refund{}, models.Payment{}, err
+	}
+
+	refundable, err := payment.Amount.Sub(order.RefundedTotal)
+	if err != nil {
+		return models.Refund{}, models.Payment{}, err
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id ASC").Find(&items).Error; err != nil {
+		return models.Refund{}, models.Payment{}, err
+	}
+	byID := make(map[uint]models.OrderItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	full := len(options.Lines) == 0 && !options.Amount.IsSet()
+	lines := options.Lines
+	if full {
+		for _, item := range items {
+			if left := item.Quantity - item.RefundedQuantity; left > 0 {
+				lines = append(lines, RefundLine{OrderItemID: item.ID, Quantity: left, Restock: options.Restock})
+			}
+		}
+	}
+
+	refund := models.Refund{
+		OrderID:   order.ID,
+		PaymentID: payment.ID,
+		Provider:  payment.Provider,
+		Status:    models.RefundPending,
+		Amount:    money.New(0, order.Currency),
+		Reason:    options.Reason,
+	}
+
+	seen := make(map[uint]bool, len(lines))
+	for _, line := range lines {
+		item, ok := byID[line.OrderItemID]
+		if !ok {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "items", Reason: fmt.Sprintf("order item %d is not part of this order", line.OrderItemID)}
+		}
+		if seen[item.ID] {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "items", Reason: fmt.Sprintf("order item %d is listed twice", item.ID)}
+		}
+		seen[item.ID] = true
+
+		if left := item.Quantity - item.RefundedQuantity; line.Quantity > left {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can still be refunded", left, item.ID)}
+		}
+		if line.Restock && order.Status == models.StatusCancelled {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "items", Reason: "items of cancelled orders are already back in stock"}
+		}
+		if left := item.Quantity - item.RestockedQuantity; line.Restock && line.Quantity > left {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can still be restocked", left, item.ID)}
+		}
+
+		amount := money.New(
+			mulDiv(item.Total.Amount, int64(item.RefundedQuantity+line.Quantity), int64(item.Quantity))-
+				mulDiv(item.Total.Amount, int64(item.RefundedQuantity), int64(item.Quantity)),
+			item.Total.Currency,
+		)
+		if refund.Amount, err = refund.Amount.Add(amount); err != nil {
+			return models.Refund{}, models.Payment{}, err
+		}
+		refund.Items = append(refund.Items, models.RefundItem{
+			OrderItemID: item.ID,
+			Quantity:    line.Quantity,
+			Amount:      amount,
+			Restocked:   line.Restock,
+		})
+	}
+
+	switch {
+	case options.Amount.IsSet():
+		if options.Amount.Currency != order.Currency {
+			return models.Refund{}, models.Payment{}, RefundError{Field: "amount", Reason: "amount must be in the order's currency, " + order.Currency}
+		}
+		refund.Amount = options.Amount
+	case full:
+		// what is left, including rounding and amounts refunded on their own
+		refund.Amount = refundable
+	}
+
+	if !refund.Amount.IsPositive() {
+		return models.Refund{}, models.Payment{}, RefundError{Field: "amount", Reason: "nothing left to refund"}
+	}
+	cmp, err := refund.Amount.Cmp(refundable)
+	if err != nil {
+		return models.Refund{}, models.Payment{}, err
+	}
+	if cmp > 0 {
+		return models.Refund{}, models.Payment{}, RefundError{
+			Field:  "amount",
+			Reason: fmt.Sprintf("at most %s %s can still be refunded", refundable, order.Currency),
+		}
+	}
+
+	return refund, payment, nil
+}
+
+// applyRefund records a successful refund on the order and its items and
+// restocks the units asked for
+func applyRefund(tx *gorm.DB, order *models.Order, refund models.Refund) error {
+	var restock []models.OrderItem
+	for _, refunded := range refund.Items {
+		if err := tx.Model(&models.OrderItem{}).Where("id = ?", refunded.OrderItemID).
+			Update("refunded_quantity", gorm.Expr("refunded_quantity + ?", refunded.Quantity)).Error; err != nil {
+			return err
+		}
+
+		if refunded.Restocked {
+			var item models.OrderItem
+			if err := tx.First(&item, refunded.OrderItemID).Error; err != nil {
+				return err
+			}
+			item.Quantity = refunded.Quantity
+			restock = append(restock, item)
+		}
+	}
+
+	if len(restock) > 0 {
+		if err := restockOrderUnits(tx, restock); err != nil {
+			return err
+		}
+	}
+
+	refundedTotal, err := order.RefundedTotal.Add(refund.Amount)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(order).Updates(map[string]interface{}{
+		"refunded_total_minor":    refundedTotal.Amount,
+		"refunded_total_currency": refundedTotal.Currency,
+	}).Error; err != nil {
+		return err
+	}
+	order.RefundedTotal = refundedTotal
+
+	return syncOrderPaymentStatus(tx, order)
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+func TestRefundRestockThenCancel(t *testing.T) {
+	useTestDB(t)
+	useMockPayments(t)
+	ctx := context.Background()
+
+	user := createTestUser(t)
+	admin := user
+	admin.Role = models.UserRoleAdmin
+
+	const stock = 5
+	product := models.Product{
+		Name:     "Refunded product",
+		Price:    money.New(1000, money.BaseCurrency()),
+		Stock:    stock,
+		IsActive: true,
+	}
+	if err := initializers.DB.Create(&product).Error; err != nil {
+		t.Fatalf("creating product: %v", err)
+	}
+	order, err := CreateOrder(user.ID, []OrderLine{{ProductID: product.ID, Quantity: 3}}, OrderOptions{})
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	if _, err := PayOrder(ctx, order.ID, user.ID, "tok_visa", true); err != nil {
+		t.Fatalf("PayOrder: %v", err)
+	}
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusProcessing, admin, ""); err != nil {
+		t.Fatalf("moving to processing: %v", err)
+	}
+
+	item := order.Items[0]
+	options := RefundOptions{Lines: []RefundLine{{OrderItemID: item.ID, Quantity: 1, Restock: true}}}
+	if _, err := RefundOrder(ctx, order.ID, options, admin); err != nil {
+		t.Fatalf("RefundOrder: %v", err)
+	}
+	if _, err := ChangeOrderStatus(order.ID, 0, models.StatusCancelled, admin, ""); err != nil {
+		t.Fatalf("cancelling: %v", err)
+	}
+
+	if err := initializers.DB.First(&product, product.ID).Error; err != nil {
+		t.Fatalf("reloading product: %v", err)
+	}
+	if product.Stock != stock {
+		t.Errorf("stock is %d, want %d", product.Stock, stock)
+	}
+	if err := initializers.DB.First(&item, item.ID).Error; err != nil {
+		t.Fatalf("reloading item: %v", err)
+	}
+	if item.RestockedQuantity != 3 {
+		t.Errorf("restocked quantity is %d, want 3", item.RestockedQuantity)
+	}
+}
@@ -0,0 +1,351 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"github.com/roronoazor/goShopAPI/payments"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultReturnWindow = 30 * 24 * time.Hour
+
+// ReturnWindow reads RETURN_WINDOW (e.g. "720h"), how long after delivery a
+// return can be opened, falling back to the default
+func ReturnWindow() time.Duration {
+	return durationFromEnv("RETURN_WINDOW", defaultReturnWindow)
+}
+
+// ReturnLine asks to return units of one order item
+type ReturnLine struct {
+	OrderItemID uint
+	Quantity    int
+	Reason      models.ReturnReason
+	Comment     string
+}
+
+// ReturnError is a return operation the order or the return doesn't allow
+type ReturnError struct {
+	Field  string
+	Reason string
+}
+
+func (e ReturnError) Error() string {
+	return e.Reason
+}
+
+// OpenReturn requests the return of units of a delivered order of the user,
+// within ReturnWindow of its delivery. Units already refunded, or held by
+// another return, can't be returned.
+func OpenReturn(orderID uint, userID uint, lines []ReturnLine, note string) (models.ReturnRequest, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, userID)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if order.Status != models.StatusDelivered {
+		tx.Rollback()
+		return models.ReturnRequest{}, ReturnError{Field: "order", Reason: fmt.Sprintf("only delivered orders can be returned, this order is %s", order.Status)}
+	}
+
+	deliveredAt, err := orderDeliveredAt(tx, order)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+	if time.Since(deliveredAt) > ReturnWindow() {
+		tx.Rollback()
+		return models.ReturnRequest{}, ReturnError{Field: "order", Reason: "the return window of this order has closed"}
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+	byID := make(map[uint]models.OrderItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	returned, err := returnedQuantities(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	request := models.ReturnRequest{
+		OrderID: order.ID,
+		UserID:  userID,
+		Status:  models.ReturnRequested,
+		Note:    note,
+	}
+	seen := make(map[uint]bool, len(lines))
+	for _, line := range lines {
+		item, ok := byID[line.OrderItemID]
+		if !ok {
+			tx.Rollback()
+			return models.ReturnRequest{}, ReturnError{Field: "items", Reason: fmt.Sprintf("order item %d is not part of this order", line.OrderItemID)}
+		}
+		if seen[item.ID] {
+			tx.Rollback()
+			return models.ReturnRequest{}, ReturnError{Field: "items", Reason: fmt.Sprintf("order item %d is listed twice", item.ID)}
+		}
+		seen[item.ID] = true
+
+		if left := item.Quantity - item.RefundedQuantity - returned[item.ID]; line.Quantity > left {
+			tx.Rollback()
+			return models.ReturnRequest{}, ReturnError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can still be returned", left, item.ID)}
+		}
+
+		request.Items = append(request.Items, models.ReturnItem{
+			OrderItemID: item.ID,
+			Quantity:    line.Quantity,
+			Reason:      line.Reason,
+			Comment:     line.Comment,
+		})
+	}
+
+	if err := tx.Create(&request).Error; err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return request, nil
+}
+
+// ApproveReturn lets the customer send the goods
+func ApproveReturn(returnID uint, note string) (models.ReturnRequest, error) {
+	return moveReturn(returnID, models.ReturnApproved, func(request *models.ReturnRequest, now time.Time) {
+		request.AdminNote = note
+		request.ApprovedAt = &now
+	})
+}
+
+// RejectReturn turns the return down; its items can be returned again
+func RejectReturn(returnID uint, note string) (models.ReturnRequest, error) {
+	return moveReturn(returnID, models.ReturnRejected, func(request *models.ReturnRequest, now time.Time) {
+		request.AdminNote = note
+	})
+}
+
+// ReceiveReturn records that the goods arrived
+func ReceiveReturn(returnID uint) (models.ReturnRequest, error) {
+	return moveReturn(returnID, models.ReturnReceived, func(request *models.ReturnRequest, now time.Time) {
+		request.ReceivedAt = &now
+	})
+}
+
+func moveReturn(returnID uint, status models.ReturnStatus, change func(*models.ReturnRequest, time.Time)) (models.ReturnRequest, error) {
+	tx := initializers.DB.Begin()
+
+	request, err := lockReturn(tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if !request.Status.CanMoveTo(status) {
+		tx.Rollback()
+		return models.ReturnRequest{}, ReturnError{Field: "status", Reason: fmt.Sprintf("cannot change a %s return to %s", request.Status, status)}
+	}
+
+	request.Status = status
+	change(&request, time.Now())
+	if err := tx.Omit(clause.Associations).Save(&request).Error; err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return request, nil
+}
+
+// ReturnInspection is the outcome of inspecting received goods
+type ReturnInspection struct {
+	Outcomes map[uint]models.ReturnOutcome // by return item ID, one for every item
+	Refund   bool                          // refund the items through the payment provider
+	Amount   money.Money                   // refunded instead of what the items were charged when set
+}
+
+// CompleteReturn applies the inspection of a received return: restocked items
+// go back into stock, written off ones don't. With Refund set the items are
+// refunded and the refund is linked to the return; a declined refund leaves
+// the return received, along with the failed refund and the
+// payments.DeclinedError.
+func CompleteReturn(ctx context.Context, returnID uint, inspection ReturnInspection, actor models.User) (models.ReturnRequest, error) {
+	tx := initializers.DB.Begin()
+
+	request, err := lockReturn(tx, returnID)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if !request.Status.CanMoveTo(models.ReturnCompleted) {
+		tx.Rollback()
+		return models.ReturnRequest{}, ReturnError{Field: "status", Reason: fmt.Sprintf("cannot complete a %s return", request.Status)}
+	}
+
+	for i, item := range request.Items {
+		outcome, ok := inspection.Outcomes[item.ID]
+		if !ok {
+			tx.Rollback()
+			return models.ReturnRequest{}, ReturnError{Field: "items", Reason: fmt.Sprintf("return item %d needs an outcome", item.ID)}
+		}
+		request.Items[i].Outcome = outcome
+	}
+	if len(inspection.Outcomes) != len(request.Items) {
+		tx.Rollback()
+		return models.ReturnRequest{}, ReturnError{Field: "items", Reason: "outcomes must only list the items of this return"}
+	}
+
+	order, err := lockOrder(tx, request.OrderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if inspection.Refund {
+		options := RefundOptions{
+			Amount: inspection.Amount,
+			Reason: fmt.Sprintf("Return #%d", request.ID),
+		}
+		for _, item := range request.Items {
+			options.Lines = append(options.Lines, RefundLine{
+				OrderItemID: item.OrderItemID,
+				Quantity:    item.Quantity,
+				Restock:     item.Outcome == models.ReturnRestock,
+			})
+		}
+
+		refund, err := RefundOrderTx(ctx, tx, &order, options, actor)
+		if err != nil {
+			var declined payments.DeclinedError
+			if !errors.As(err, &declined) {
+				tx.Rollback()
+				return models.ReturnRequest{}, err
+			}
+			if err := tx.Commit().Error; err != nil {
+				return models.ReturnRequest{}, err
+			}
+			request.Refund = &refund
+			return request, declined
+		}
+		request.RefundID = &refund.ID
+		request.Refund = &refund
+	} else {
+		var restock []models.OrderItem
+		for _, item := range request.Items {
+			if item.Outcome != models.ReturnRestock {
+				continue
+			}
+			var orderItem models.OrderItem
+			if err := tx.First(&orderItem, item.OrderItemID).Error; err != nil {
+				tx.Rollback()
+				return models.ReturnRequest{}, err
+			}
+			if left := orderItem.Quantity - orderItem.RestockedQuantity; item.Quantity > left {
+				tx.Rollback()
+				return models.ReturnRequest{}, ReturnError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can still be restocked", left, orderItem.ID)}
+			}
+			orderItem.Quantity = item.Quantity
+			restock = append(restock, orderItem)
+		}
+		if err := restockOrderUnits(tx, restock); err != nil {
+			tx.Rollback()
+			return models.ReturnRequest{}, err
+		}
+	}
+
+	for _, item := range request.Items {
+		if err := tx.Model(&item).Update("outcome", item.Outcome).Error; err != nil {
+			tx.Rollback()
+			return models.ReturnRequest{}, err
+		}
+	}
+
+	now := time.Now()
+	request.Status = models.ReturnCompleted
+	request.CompletedAt = &now
+	if err := tx.Omit(clause.Associations).Save(&request).Error; err != nil {
+		tx.Rollback()
+		return models.ReturnRequest{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.ReturnRequest{}, err
+	}
+
+	return request, nil
+}
+
+// lockReturn loads the return with its items and locks its row until the end
+// of the transaction
+func lockReturn(tx *gorm.DB, returnID uint) (models.ReturnRequest, error) {
+	var request models.ReturnRequest
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&request, returnID).Error; err != nil {
+		return models.ReturnRequest{}, err
+	}
+	if err := tx.Where("return_request_id = ?", request.ID).Order("id ASC").Find(&request.Items).Error; err != nil {
+		return models.ReturnRequest{}, err
+	}
+	return request, nil
+}
+
+// orderDeliveredAt is when the order became delivered, from its status
+// history; orders from before the history started count from their last update
+func orderDeliveredAt(tx *gorm.DB, order models.Order) (time.Time, error) {
+	var event models.OrderStatusEvent
+	err := tx.Where("order_id = ? AND to_status = ?", order.ID, models.StatusDelivered).
+		Order("created_at DESC").First(&event).Error
+	if err == gorm.ErrRecordNotFound {
+		return order.UpdatedAt, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return event.CreatedAt, nil
+}
+
+// returnedQuantities sums the units of each order item held by open returns,
+// or returned without a refund (refunded units are in RefundedQuantity)
+func returnedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := tx.Model(&models.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_requests.order_id = ?", orderID).
+		Where("return_requests.status IN ? OR (return_requests.status = ? AND return_requests.refund_id IS NULL)",
+			[]models.ReturnStatus{models.ReturnRequested, models.ReturnApproved, models.ReturnReceived}, models.ReturnCompleted).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	returned := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		returned[row.OrderItemID] = row.Quantity
+	}
+	return returned, nil
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const searchConfig = models.ProductSearchConfig
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=35, MinWords=15, MaxFragments=2"
+
+// ProductSearch is a parsed q= search. Every word is matched as a prefix
+// ("runn" finds "running"), and names that are close to the raw text still
+// match through pg_trgm, which tolerates typos.
+type ProductSearch struct {
+	Text    string // the trimmed search text
+	TSQuery string // prefix tsquery, empty when the text has no words
+}
+
+// NewProductSearch parses q, reporting false when there is nothing to search
+func NewProductSearch(q string) (ProductSearch, bool) {
+	text := strings.TrimSpace(q)
+	if text == "" {
+		return ProductSearch{}, false
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+
+	return ProductSearch{Text: text, TSQuery: strings.Join(terms, " & ")}, true
+}
+
+// Filter restricts the query to matching products
+func (s ProductSearch) Filter(query *gorm.DB) *gorm.DB {
+	if s.TSQuery == "" {
+		return query.Where("? <% products.name", s.Text)
+	}
+	return query.Where(
+		"(products.search_vector @@ to_tsquery('"+searchConfig+"', ?) OR ? <% products.name)",
+		s.TSQuery, s.Text,
+	)
+}
+
+// OrderByRank sorts the best matches first: the text search rank plus the
+// trigram similarity of the name
+func (s ProductSearch) OrderByRank(query *gorm.DB) *gorm.DB {
+	if s.TSQuery == "" {
+		return query.Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "word_similarity(?, products.name) DESC",
+			Vars: []interface{}{s.Text},
+		}})
+	}
+	return query.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "ts_rank(products.search_vector, to_tsquery('" + searchConfig + "', ?)) + word_similarity(?, products.name) DESC",
+		Vars: []interface{}{s.TSQuery, s.Text},
+	}})
+}
+
+// Highlights returns the name and description of the given products with the
+// matched words wrapped in <mark> tags, keyed by product ID
+func (s ProductSearch) Highlights(productIDs []uint) (map[uint]models.ProductHighlight, error) {
+	highlights := make(map[uint]models.ProductHighlight, len(productIDs))
+	if len(productIDs) == 0 || s.TSQuery == "" {
+		return highlights, nil
+	}
+
+	var rows []struct {
+		ID          uint
+		Name        string
+		Description string
+	}
+	err := initializers.DB.Model(&models.Product{}).
+		Select(
+			"id, ts_headline('"+searchConfig+"', name, tsq, ?) AS name, ts_headline('"+searchConfig+"', description, tsq, ?) AS description",
+			headlineOptions, headlineOptions,
+		).
+		Joins("CROSS JOIN to_tsquery('"+searchConfig+"', ?) AS tsq", s.TSQuery).
+		Where("products.id IN ?", productIDs).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		highlights[row.ID] = models.ProductHighlight{Name: row.Name, Description: row.Description}
+	}
+	return highlights, nil
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ShipmentLine ships units of one order item
+type ShipmentLine struct {
+	OrderItemID uint
+	Quantity    int
+}
+
+type ShipmentOptions struct {
+	Carrier        string         // the order's shipping method carrier when empty
+	TrackingNumber string         // optional
+	Lines          []ShipmentLine // all unshipped units when empty
+}
+
+// ShipmentError is a shipment operation the order or the shipment doesn't
+// allow
+type ShipmentError struct {
+	Field  string
+	Reason string
+}
+
+func (e ShipmentError) Error() string {
+	return e.Reason
+}
+
+// CreateShipment hands units of a paid processing order to a carrier. Units
+// that were refunded or already shipped can't be shipped; once all units are,
+// the order moves to shipped.
+func CreateShipment(orderID uint, options ShipmentOptions, actor models.User) (models.Shipment, error) {
+	tx := initializers.DB.Begin()
+
+	order, err := lockOrder(tx, orderID, 0)
+	if err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if order.Status != models.StatusProcessing {
+		tx.Rollback()
+		return models.Shipment{}, ShipmentError{Field: "order", Reason: fmt.Sprintf("only processing orders can be shipped, this order is %s", order.Status)}
+	}
+	if !order.PaymentStatus.IsPaid() {
+		tx.Rollback()
+		return models.Shipment{}, ShipmentError{Field: "order", Reason: fmt.Sprintf("only paid orders can be shipped, this order is %s", order.PaymentStatus)}
+	}
+
+	carrier := strings.TrimSpace(options.Carrier)
+	if carrier == "" && order.ShippingMethodID != nil {
+		method, err := LoadShippingMethod(tx, *order.ShippingMethodID, false)
+		if err != nil {
+			tx.Rollback()
+			return models.Shipment{}, err
+		}
+		carrier = method.Carrier
+	}
+	if carrier == "" {
+		tx.Rollback()
+		return models.Shipment{}, ShipmentError{Field: "carrier", Reason: "a carrier is required"}
+	}
+
+	var items []models.OrderItem
+	if err := tx.Where("order_id = ?", order.ID).Order("id ASC").Find(&items).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+	byID := make(map[uint]models.OrderItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	shipped, err := shippedQuantities(tx, order.ID)
+	if err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	lines := options.Lines
+	if len(lines) == 0 {
+		for _, item := range items {
+			if left := unshippedQuantity(item, shipped); left > 0 {
+				lines = append(lines, ShipmentLine{OrderItemID: item.ID, Quantity: left})
+			}
+		}
+		if len(lines) == 0 {
+			tx.Rollback()
+			return models.Shipment{}, ShipmentError{Field: "items", Reason: "all items of this order have been shipped"}
+		}
+	}
+
+	shipment := models.Shipment{
+		OrderID:        order.ID,
+		Carrier:        carrier,
+		TrackingNumber: strings.TrimSpace(options.TrackingNumber),
+		Status:         models.ShipmentShipped,
+		ActorID:        actor.ID,
+	}
+	seen := make(map[uint]bool, len(lines))
+	for _, line := range lines {
+		item, ok := byID[line.OrderItemID]
+		if !ok {
+			tx.Rollback()
+			return models.Shipment{}, ShipmentError{Field: "items", Reason: fmt.Sprintf("order item %d is not part of this order", line.OrderItemID)}
+		}
+		if seen[item.ID] {
+			tx.Rollback()
+			return models.Shipment{}, ShipmentError{Field: "items", Reason: fmt.Sprintf("order item %d is listed twice", item.ID)}
+		}
+		seen[item.ID] = true
+
+		if left := unshippedQuantity(item, shipped); line.Quantity > left {
+			tx.Rollback()
+			return models.Shipment{}, ShipmentError{Field: "items", Reason: fmt.Sprintf("only %d of order item %d can be shipped", left, item.ID)}
+		}
+		shipment.Items = append(shipment.Items, models.ShipmentItem{
+			OrderItemID: item.ID,
+			Quantity:    line.Quantity,
+		})
+	}
+
+	if err := tx.Create(&shipment).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if err := advanceOrderShipping(tx, order.ID, actor, fmt.Sprintf("Shipment #%d", shipment.ID)); err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Shipment{}, err
+	}
+
+	return shipment, nil
+}
+
+// DeliverShipment records the delivery of a shipment. Once all shipments of a
+// shipped order are delivered, the order moves to delivered.
+func DeliverShipment(shipmentID uint, actor models.User) (models.Shipment, error) {
+	tx := initializers.DB.Begin()
+
+	// The order is locked first, as everywhere else
+	var shipment models.Shipment
+	if err := tx.First(&shipment, shipmentID).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+	if _, err := lockOrder(tx, shipment.OrderID, 0); err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&shipment, shipmentID).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if shipment.Status != models.ShipmentShipped {
+		tx.Rollback()
+		return models.Shipment{}, ShipmentError{Field: "shipment", Reason: fmt.Sprintf("shipment %d is already %s", shipment.ID, shipment.Status)}
+	}
+
+	now := time.Now()
+	shipment.Status = models.ShipmentDelivered
+	shipment.DeliveredAt = &now
+	if err := tx.Omit(clause.Associations).Save(&shipment).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if err := advanceOrderShipping(tx, shipment.OrderID, actor, fmt.Sprintf("Shipment #%d delivered", shipment.ID)); err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if err := tx.Where("shipment_id = ?", shipment.ID).Order("id ASC").Find(&shipment.Items).Error; err != nil {
+		tx.Rollback()
+		return models.Shipment{}, err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return models.Shipment{}, err
+	}
+
+	return shipment, nil
+}
+
+// advanceOrderShipping moves the order on once its shipments allow it: a
+// processing order to shipped when shipments hold all its units, and a
+// shipped order to delivered when they all are. Orders without shipments are
+// left alone.
+func advanceOrderShipping(tx *gorm.DB, orderID uint, actor models.User, note string) error {
+	order, err := lockOrder(tx, orderID, 0)
+	if err != nil {
+		return err
+	}
+
+	var shipments int64
+	if err := tx.Model(&models.Shipment{}).Where("order_id = ?", order.ID).Count(&shipments).Error; err != nil {
+		return err
+	}
+	if shipments == 0 {
+		return nil
+	}
+
+	if order.Status == models.StatusProcessing {
+		var items []models.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return err
+		}
+		shipped, err := shippedQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if unshippedQuantity(item, shipped) > 0 {
+				return nil
+			}
+		}
+
+		if _, err := ChangeOrderStatusTx(tx, order.ID, 0, models.StatusShipped, actor, note); err != nil {
+			return err
+		}
+		order.Status = models.StatusShipped
+	}
+
+	if order.Status == models.StatusShipped {
+		var undelivered int64
+		if err := tx.Model(&models.Shipment{}).Where("order_id = ? AND status <> ?", order.ID, models.ShipmentDelivered).Count(&undelivered).Error; err != nil {
+			return err
+		}
+		if undelivered > 0 {
+			return nil
+		}
+
+		if _, err := ChangeOrderStatusTx(tx, order.ID, 0, models.StatusDelivered, actor, note); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shippedQuantities sums the units of each order item held by shipments
+func shippedQuantities(tx *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := tx.Model(&models.ShipmentItem{}).
+		Select("shipment_items.order_item_id, SUM(shipment_items.quantity) AS quantity").
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.order_id = ?", orderID).
+		Group("shipment_items.order_item_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	shipped := make(map[uint]int, len(rows))
+	for _, row := range rows {
+		shipped[row.OrderItemID] = row.Quantity
+	}
+	return shipped, nil
+}
+
+// unshippedQuantity is how many units of the item are neither refunded nor
+// shipped (cancelled units are already out of its quantity)
+func unshippedQuantity(item models.OrderItem, shipped map[uint]int) int {
+	left := item.Quantity - item.RefundedQuantity - shipped[item.ID]
+	if left < 0 {
+		return 0
+	}
+	return left
+}
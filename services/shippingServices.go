@@ -0,0 +1,149 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// ShippingError reports an address or shipping method an order can't use
+type ShippingError struct {
+	Field  string
+	Reason string
+}
+
+func (e ShippingError) Error() string {
+	return e.Reason
+}
+
+// NormalizePostalAddress trims the address and normalizes its region the way
+// tax regions are
+func NormalizePostalAddress(address models.PostalAddress) models.PostalAddress {
+	region := NormalizeTaxRegion(address.TaxRegion())
+	return models.PostalAddress{
+		Name:       strings.TrimSpace(address.Name),
+		Line1:      strings.TrimSpace(address.Line1),
+		Line2:      strings.TrimSpace(address.Line2),
+		City:       strings.TrimSpace(address.City),
+		State:      region.State,
+		PostalCode: region.PostalCode,
+		Country:    region.Country,
+		Phone:      strings.TrimSpace(address.Phone),
+	}
+}
+
+// ShippingWeight is the weight charged for the items in grams: per unit the
+// larger of its weight and, when the method sets a volumetric divisor, the
+// volumetric weight of its dimensions
+func ShippingWeight(method models.ShippingMethod, items []models.OrderItem, products map[uint]models.Product) int {
+	weight := 0
+	for _, item := range items {
+		product := products[item.ProductID]
+		unit := product.Weight
+		if method.VolumetricDivisor > 0 {
+			// cm³ per kg is mm³ per g
+			volumetric := int(mulDiv(int64(product.Length)*int64(product.Width)*int64(product.Height), 1, int64(method.VolumetricDivisor)))
+			if volumetric > unit {
+				unit = volumetric
+			}
+		}
+		weight += unit * item.Quantity
+	}
+	return weight
+}
+
+// QuoteShipping prices the method for the discounted items shipped to the
+// address, in the pricer's currency. It is a ShippingError when none of the
+// method's rates covers the address and the weight.
+func QuoteShipping(method models.ShippingMethod, address models.PostalAddress, items []models.OrderItem, products map[uint]models.Product, pricer Pricer) (money.Money, error) {
+	weight := ShippingWeight(method, items, products)
+
+	var rate *models.ShippingRate
+	best := 0
+	for i, candidate := range method.Rates {
+		if !candidate.Holds(weight) {
+			continue
+		}
+		if covers := candidate.Covers(address); covers > best {
+			rate = &method.Rates[i]
+			best = covers
+		}
+	}
+	if rate == nil {
+		return money.Money{}, ShippingError{
+			Field:  "shipping_method_id",
+			Reason: fmt.Sprintf("%s does not ship %d g to %s", method.Name, weight, address.Country),
+		}
+	}
+
+	if rate.FreeOver.IsSet() {
+		subtotal, err := itemsSubtotal(items, pricer.Currency)
+		if err != nil {
+			return money.Money{}, err
+		}
+		for _, item := range items {
+			if subtotal, err = subtotal.Sub(item.Discount); err != nil {
+				return money.Money{}, err
+			}
+		}
+		cmp, err := subtotal.Cmp(pricer.Convert(rate.FreeOver))
+		if err != nil {
+			return money.Money{}, err
+		}
+		if cmp >= 0 {
+			return money.New(0, pricer.Currency), nil
+		}
+	}
+
+	cost := pricer.Convert(rate.Price)
+	if rate.Type == models.ShippingRateWeight && rate.PricePerKg.IsSet() {
+		kilograms := (weight + 999) / 1000
+		var err error
+		if cost, err = cost.Add(pricer.Convert(rate.PricePerKg.Mul(kilograms))); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return cost, nil
+}
+
+// LoadShippingMethod loads the method with its rates, ordered by ID. With
+// active set it must be neither deleted nor inactive, as for new orders.
+func LoadShippingMethod(db *gorm.DB, methodID uint, active bool) (models.ShippingMethod, error) {
+	query := db.Preload("Rates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Where("id = ?", methodID)
+	if active {
+		query = query.Where("is_active = ? AND deleted_at IS NULL", true)
+	}
+
+	var method models.ShippingMethod
+	if err := query.First(&method).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return models.ShippingMethod{}, ShippingError{Field: "shipping_method_id", Reason: fmt.Sprintf("shipping method %d not found", methodID)}
+		}
+		return models.ShippingMethod{}, err
+	}
+	return method, nil
+}
+
+// shipOrder sets the shipping cost of the priced order from its address and
+// method; orders without a method ship for free, and so do orders with a free
+// shipping coupon, once the method has been checked to ship there
+func shipOrder(order *models.Order, method *models.ShippingMethod, products map[uint]models.Product, pricer Pricer) error {
+	order.ShippingTotal = money.New(0, pricer.Currency)
+	if method == nil {
+		return nil
+	}
+
+	cost, err := QuoteShipping(*method, order.ShippingAddress, order.Items, products, pricer)
+	if err != nil {
+		return err
+	}
+	if !order.FreeShipping {
+		order.ShippingTotal = cost
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+	"gorm.io/gorm"
+)
+
+// NormalizeTaxRegion upper-cases the country and state and drops the spaces
+// of the postal code, the form rules are matched in
+func NormalizeTaxRegion(region models.TaxRegion) models.TaxRegion {
+	return models.TaxRegion{
+		Country:    strings.ToUpper(strings.TrimSpace(region.Country)),
+		State:      strings.ToUpper(strings.TrimSpace(region.State)),
+		PostalCode: strings.ToUpper(strings.ReplaceAll(region.PostalCode, " ", "")),
+	}
+}
+
+// ParseTaxRate reads a percentage between 0 and 100
+func ParseTaxRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok || rate.Sign() < 0 || rate.Cmp(big.NewRat(100, 1)) > 0 {
+		return nil, fmt.Errorf("%q is not a valid tax rate", value)
+	}
+	return rate, nil
+}
+
+// MatchTaxRules returns the rules of the tax classes that cover the region,
+// by tax class
+func MatchTaxRules(db *gorm.DB, region models.TaxRegion, classes []string) (map[string][]models.TaxRule, error) {
+	matched := map[string][]models.TaxRule{}
+	if !region.IsSet() || len(classes) == 0 {
+		return matched, nil
+	}
+
+	var rules []models.TaxRule
+	if err := db.
+		Where("tax_class IN ? AND country = ?", classes, region.Country).
+		Where("state = '' OR state = ?", region.State).
+		// a plain prefix comparison: LIKE would read a % or _ in a stored
+		// prefix as a wildcard
+		Where("left(?, length(postal_prefix)) = postal_prefix", region.PostalCode).
+		Order("id ASC").
+		Find(&rules).Error; err != nil {
+		return nil, err
+	}
+
+	for _, rule := range rules {
+		matched[rule.TaxClass] = append(matched[rule.TaxClass], rule)
+	}
+	return matched, nil
+}
+
+// ApplyTaxes computes the tax of every item in the region, setting each
+// item's Tax and Total, and returns the tax per rule. Taxes are on the line
+// after its discount. Inclusive rates are taken out of the line first,
+// exclusive rates then apply to what is left without them.
+func ApplyTaxes(db *gorm.DB, region models.TaxRegion, items []models.OrderItem, products map[uint]models.Product, currency string) ([]models.OrderTaxLine, error) {
+	for i, item := range items {
+		net, err := item.Price.Mul(item.Quantity).Sub(item.Discount)
+		if err != nil {
+			return nil, err
+		}
+		items[i].Tax = money.New(0, currency)
+		items[i].Total = net
+	}
+
+	classes := make([]string, 0, len(items))
+	seen := map[string]bool{}
+	for _, item := range items {
+		class := productTaxClass(products[item.ProductID])
+		if !seen[class] {
+			seen[class] = true
+			classes = append(classes, class)
+		}
+	}
+
+	matched, err := MatchTaxRules(db, region, classes)
+	if err != nil {
+		return nil, err
+	}
+
+	byRule := map[uint]*models.OrderTaxLine{}
+	for i, item := range items {
+		rules := matched[productTaxClass(products[item.ProductID])]
+		if len(rules) == 0 {
+			continue
+		}
+
+		taxes, err := lineTaxes(item.Total, rules)
+		if err != nil {
+			return nil, err
+		}
+		for j, rule := range rules {
+			if !rule.Inclusive {
+				if items[i].Total, err = items[i].Total.Add(taxes[j]); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		for j, rule := range rules {
+			if items[i].Tax, err = items[i].Tax.Add(taxes[j]); err != nil {
+				return nil, err
+			}
+
+			line, ok := byRule[rule.ID]
+			if !ok {
+				line = &models.OrderTaxLine{
+					TaxRuleID: rule.ID,
+					Name:      rule.Name,
+					Rate:      rule.Rate,
+					Inclusive: rule.Inclusive,
+					Amount:    money.New(0, currency),
+				}
+				byRule[rule.ID] = line
+			}
+			if line.Amount, err = line.Amount.Add(taxes[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	lines := make([]models.OrderTaxLine, 0, len(byRule))
+	for _, line := range byRule {
+		lines = append(lines, *line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].TaxRuleID < lines[j].TaxRuleID })
+	return lines, nil
+}
+
+// lineTaxes is the tax of each of the rules on a line costing net after its
+// discount
+func lineTaxes(net money.Money, rules []models.TaxRule) ([]money.Money, error) {
+	var err error
+	rates := make([]*big.Rat, len(rules))
+	inclusiveRate := new(big.Rat)
+	for j, rule := range rules {
+		if rates[j], err = ParseTaxRate(rule.Rate); err != nil {
+			return nil, err
+		}
+		if rule.Inclusive {
+			inclusiveRate.Add(inclusiveRate, rates[j])
+		}
+	}
+
+	// net = base * (100 + inclusive rates) / 100
+	hundred := big.NewRat(100, 1)
+	inclusiveBase := new(big.Rat).Add(hundred, inclusiveRate)
+	base := net
+	taxes := make([]money.Money, len(rules))
+	for j, rule := range rules {
+		if rule.Inclusive {
+			taxes[j] = net.MulRat(new(big.Rat).Quo(rates[j], inclusiveBase))
+			if base, err = base.Sub(taxes[j]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for j, rule := range rules {
+		if !rule.Inclusive {
+			taxes[j] = base.MulRat(new(big.Rat).Quo(rates[j], hundred))
+		}
+	}
+	return taxes, nil
+}
+
+// ExclusiveTax is the part of the tax lines charged on top of the prices
+func ExclusiveTax(lines []models.OrderTaxLine, currency string) (money.Money, error) {
+	total := money.New(0, currency)
+	for _, line := range lines {
+		if line.Inclusive {
+			continue
+		}
+		var err error
+		if total, err = total.Add(line.Amount); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+func productTaxClass(product models.Product) string {
+	if product.TaxClass == "" {
+		return models.DefaultTaxClass
+	}
+	return product.TaxClass
+}
@@ -0,0 +1,161 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+func TestNormalizeTaxRegion(t *testing.T) {
+	got := NormalizeTaxRegion(models.TaxRegion{Country: " us ", State: "ca", PostalCode: "sw1a 1aa"})
+	want := models.TaxRegion{Country: "US", State: "CA", PostalCode: "SW1A1AA"}
+	if got != want {
+		t.Errorf("NormalizeTaxRegion = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTaxRate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  *big.Rat
+	}{
+		{"20", big.NewRat(20, 1)},
+		{" 7.25 ", big.NewRat(29, 4)},
+		{"0", new(big.Rat)},
+		{"100", big.NewRat(100, 1)},
+	}
+	for _, tt := range tests {
+		got, err := ParseTaxRate(tt.value)
+		if err != nil || got.Cmp(tt.want) != 0 {
+			t.Errorf("ParseTaxRate(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+
+	for _, value := range []string{"", "abc", "-1", "100.5"} {
+		if _, err := ParseTaxRate(value); err == nil {
+			t.Errorf("ParseTaxRate(%q) succeeded, want an error", value)
+		}
+	}
+}
+
+func TestLineTaxes(t *testing.T) {
+	tests := []struct {
+		name  string
+		net   int64
+		rules []models.TaxRule
+		want  []int64
+	}{
+		{"exclusive", 1000, []models.TaxRule{{Rate: "10"}}, []int64{100}},
+		{"exclusive rounds half up", 1000, []models.TaxRule{{Rate: "7.25"}}, []int64{73}},
+		{"inclusive", 1200, []models.TaxRule{{Rate: "20", Inclusive: true}}, []int64{200}},
+		{"inclusive rounds", 1000, []models.TaxRule{{Rate: "20", Inclusive: true}}, []int64{167}},
+		{"two inclusive", 1200, []models.TaxRule{{Rate: "10", Inclusive: true}, {Rate: "10", Inclusive: true}}, []int64{100, 100}},
+		// the exclusive rate applies to the line without its inclusive tax
+		{"mixed", 1200, []models.TaxRule{{Rate: "10"}, {Rate: "20", Inclusive: true}}, []int64{100, 200}},
+		{"zero rate", 1000, []models.TaxRule{{Rate: "0"}}, []int64{0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			taxes, err := lineTaxes(money.New(tt.net, "USD"), tt.rules)
+			if err != nil {
+				t.Fatalf("lineTaxes: %v", err)
+			}
+			for j, want := range tt.want {
+				if taxes[j] != money.New(want, "USD") {
+					t.Errorf("tax of rule %d = %#v, want %d", j, taxes[j], want)
+				}
+			}
+		})
+	}
+
+	if _, err := lineTaxes(money.New(1000, "USD"), []models.TaxRule{{Rate: "abc"}}); err == nil {
+		t.Error("lineTaxes with an invalid rate succeeded")
+	}
+}
+
+func TestExclusiveTax(t *testing.T) {
+	lines := []models.OrderTaxLine{
+		{Amount: money.New(100, "USD")},
+		{Amount: money.New(200, "USD"), Inclusive: true},
+		{Amount: money.New(50, "USD")},
+	}
+	if got, err := ExclusiveTax(lines, "USD"); err != nil || got != money.New(150, "USD") {
+		t.Errorf("ExclusiveTax = %#v, %v, want 150", got, err)
+	}
+	if got, err := ExclusiveTax(nil, "USD"); err != nil || got != money.New(0, "USD") {
+		t.Errorf("ExclusiveTax of no lines = %#v, %v, want 0", got, err)
+	}
+
+	mixed := []models.OrderTaxLine{{Amount: money.New(100, "EUR")}}
+	if _, err := ExclusiveTax(mixed, "USD"); !errors.Is(err, money.ErrCurrencyMismatch) {
+		t.Errorf("ExclusiveTax in another currency error = %v, want ErrCurrencyMismatch", err)
+	}
+}
+
+func TestApplyTaxesWithoutRegion(t *testing.T) {
+	items := []models.OrderItem{{
+		ProductID: 1,
+		Quantity:  2,
+		Price:     money.New(500, "USD"),
+		Discount:  money.New(100, "USD"),
+	}}
+
+	// no region matches no rules, so the database is never asked
+	lines, err := ApplyTaxes(nil, models.TaxRegion{}, items, map[uint]models.Product{}, "USD")
+	if err != nil {
+		t.Fatalf("ApplyTaxes: %v", err)
+	}
+	if len(lines) != 0 {
+		t.Errorf("tax lines = %+v, want none", lines)
+	}
+	if items[0].Tax != money.New(0, "USD") || items[0].Total != money.New(900, "USD") {
+		t.Errorf("item tax = %#v, total = %#v, want 0 and 900", items[0].Tax, items[0].Total)
+	}
+}
+
+func TestMatchTaxRulesPostalPrefix(t *testing.T) {
+	useTestDB(t)
+
+	class := fmt.Sprintf("test-%d", time.Now().UnixNano())
+	rules := []models.TaxRule{
+		{Name: "country", TaxClass: class, Country: "US", Rate: "1"},
+		{Name: "prefix", TaxClass: class, Country: "US", PostalPrefix: "941", Rate: "1"},
+		{Name: "percent", TaxClass: class, Country: "US", PostalPrefix: "9%", Rate: "1"},
+		{Name: "underscore", TaxClass: class, Country: "US", PostalPrefix: "9_1", Rate: "1"},
+	}
+	if err := initializers.DB.Create(&rules).Error; err != nil {
+		t.Fatalf("creating rules: %v", err)
+	}
+
+	tests := []struct {
+		postalCode string
+		want       []string
+	}{
+		{"94107", []string{"country", "prefix"}},
+		{"90210", []string{"country"}},
+		{"9%000", []string{"country", "percent"}},
+		{"9_100", []string{"country", "underscore"}},
+		{"", []string{"country"}},
+	}
+	for _, tt := range tests {
+		region := models.TaxRegion{Country: "US", PostalCode: tt.postalCode}
+		matched, err := MatchTaxRules(initializers.DB, region, []string{class})
+		if err != nil {
+			t.Fatalf("MatchTaxRules(%q): %v", tt.postalCode, err)
+		}
+
+		var names []string
+		for _, rule := range matched[class] {
+			names = append(names, rule.Name)
+		}
+		if fmt.Sprint(names) != fmt.Sprint(tt.want) {
+			t.Errorf("MatchTaxRules(%q) = %v, want %v", tt.postalCode, names, tt.want)
+		}
+	}
+}
@@ -1,24 +1,220 @@
 package services
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"log"
 	"os"
 	"time"
 
+	"github.com/roronoazor/goShopAPI/initializers"
 	"github.com/roronoazor/goShopAPI/models"
+	"gorm.io/gorm"
 
 	"github.com/golang-jwt/jwt/v4"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenExpired = errors.New("refresh token expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used")
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
 type tokenResponse struct {
-	Token    string `json:"token"`
-	Email    string `json:"email"`
-	Username string `json:"username"`
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+	Email        string `json:"email"`
+	Username     string `json:"username"`
+}
+
+// AccessTokenTTL reads ACCESS_TOKEN_TTL (e.g. "15m"), falling back to the default
+func AccessTokenTTL() time.Duration {
+	return durationFromEnv("ACCESS_TOKEN_TTL", defaultAccessTokenTTL)
 }
 
+// RefreshTokenTTL reads REFRESH_TOKEN_TTL (e.g. "720h"), falling back to the default
+func RefreshTokenTTL() time.Duration {
+	return durationFromEnv("REFRESH_TOKEN_TTL", defaultRefreshTokenTTL)
+}
+
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Invalid %s %q, using default %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+// GenerateToken starts a new login session for the user: a short-lived access
+// token plus a refresh token belonging to a fresh token family
 func GenerateToken(user models.User) (tokenResponse, error) {
+	familyID, err := randomToken(16)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	refreshToken, record, err := newRefreshToken(user.ID, familyID)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	if err := initializers.DB.Create(&record).Error; err != nil {
+		return tokenResponse{}, err
+	}
+
+	return buildTokenResponse(user, familyID, refreshToken)
+}
+
+// RefreshTokens exchanges a refresh token for a new access/refresh token pair.
+// The presented token is revoked (rotation). Presenting a token that was already
+// rotated or revoked is treated as theft and revokes the whole family.
+func RefreshTokens(refreshToken string) (tokenResponse, error) {
+	var stored models.RefreshToken
+	if err := initializers.DB.Where("token_hash = ?", hashToken(refreshToken)).First(&stored).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return tokenResponse{}, ErrInvalidRefreshToken
+		}
+		return tokenResponse{}, err
+	}
+
+	if stored.RevokedAt != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoking family %s", stored.UserID, stored.FamilyID)
+		if err := revokeFamily(initializers.DB, stored.FamilyID); err != nil {
+			return tokenResponse{}, err
+		}
+		return tokenResponse{}, ErrRefreshTokenReused
+	}
+
+	if !stored.IsActive() {
+		return tokenResponse{}, ErrRefreshTokenExpired
+	}
+
+	var user models.User
+	if err := initializers.DB.First(&user, stored.UserID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return tokenResponse{}, ErrInvalidRefreshToken
+		}
+		return tokenResponse{}, err
+	}
+
+	newToken, record, err := newRefreshToken(user.ID, stored.FamilyID)
+	if err != nil {
+		return tokenResponse{}, err
+	}
+
+	tx := initializers.DB.Begin()
+
+	if err := tx.Create(&record).Error; err != nil {
+		tx.Rollback()
+		return tokenResponse{}, err
+	}
+
+	// Only revoke if nobody else rotated this token in the meantime
+	result := tx.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", stored.ID).
+		Updates(map[string]interface{}{
+			"revoked_at":     time.Now(),
+			"replaced_by_id": record.ID,
+		})
+	if result.Error != nil {
+		tx.Rollback()
+		return tokenResponse{}, result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		log.Printf("Concurrent refresh token reuse detected for user %d, revoking family %s", stored.UserID, stored.FamilyID)
+		if err := revokeFamily(initializers.DB, stored.FamilyID); err != nil {
+			return tokenResponse{}, err
+		}
+		return tokenResponse{}, ErrRefreshTokenReused
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return tokenResponse{}, err
+	}
+
+	return buildTokenResponse(user, stored.FamilyID, newToken)
+}
+
+// RevokeRefreshToken logs out the session the refresh token belongs to
+func RevokeRefreshToken(refreshToken string) error {
+	var stored models.RefreshToken
+	if err := initializers.DB.Where("token_hash = ?", hashToken(refreshToken)).First(&stored).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return ErrInvalidRefreshToken
+		}
+		return err
+	}
+
+	return revokeFamily(initializers.DB, stored.FamilyID)
+}
+
+// RevokeAllTokens logs the user out everywhere: all refresh tokens are revoked
+// and the token version bump invalidates every access token already issued
+func RevokeAllTokens(user models.User) error {
+	tx := initializers.DB.Begin()
+
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", user.ID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Model(&models.User{}).
+		Where("id = ?", user.ID).
+		Update("token_version", gorm.Expr("token_version + 1")).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// IsTokenFamilyRevoked reports whether no usable refresh token is left in the
+// family, meaning the session was logged out or killed after token reuse
+func IsTokenFamilyRevoked(familyID string) (bool, error) {
+	var active int64
+	err := initializers.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Count(&active).Error
+	if err != nil {
+		return false, err
+	}
+	return active == 0, nil
+}
+
+func revokeFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+func buildTokenResponse(user models.User, familyID string, refreshToken string) (tokenResponse, error) {
+	ttl := AccessTokenTTL()
+	now := time.Now()
+
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
 		"sub": user.ID,
-		"exp": time.Now().Add(time.Hour * 24).Unix(),
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+		"fam": familyID,
+		"ver": user.TokenVersion,
 	})
 
 	tokenString, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
@@ -28,8 +224,37 @@ func GenerateToken(user models.User) (tokenResponse, error) {
 	}
 
 	return tokenResponse{
-		Token:    tokenString,
-		Email:    user.Email,
-		Username: user.Username,
+		Token:        tokenString,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(ttl.Seconds()),
+		Email:        user.Email,
+		Username:     user.Username,
+	}, nil
+}
+
+func newRefreshToken(userID uint, familyID string) (string, models.RefreshToken, error) {
+	raw, err := randomToken(32)
+	if err != nil {
+		return "", models.RefreshToken{}, err
+	}
+
+	return raw, models.RefreshToken{
+		UserID:    userID,
+		TokenHash: hashToken(raw),
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(RefreshTokenTTL()),
 	}, nil
 }
+
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/roronoazor/goShopAPI/initializers"
+	"github.com/roronoazor/goShopAPI/models"
+)
+
+const testJWTSecret = "test-secret"
+
+// tokenClaims parses an access token signed with testJWTSecret
+func tokenClaims(t *testing.T, tokenString string) jwt.MapClaims {
+	t.Helper()
+
+	token, err := jwt.Parse(tokenString, func(*jwt.Token) (interface{}, error) {
+		return []byte(testJWTSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("parsing access token: %v", err)
+	}
+	return token.Claims.(jwt.MapClaims)
+}
+
+func TestDurationFromEnv(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", time.Hour},
+		{"90s", 90 * time.Second},
+		{"not a duration", time.Hour},
+		{"0s", time.Hour},
+		{"-5m", time.Hour},
+	}
+	for _, tt := range tests {
+		t.Setenv("TEST_TTL", tt.value)
+		if got := durationFromEnv("TEST_TTL", time.Hour); got != tt.want {
+			t.Errorf("durationFromEnv(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewRefreshToken(t *testing.T) {
+	t.Setenv("REFRESH_TOKEN_TTL", "1h")
+
+	raw, record, err := newRefreshToken(7, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := newRefreshToken(7, "family")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if raw == "" || raw == other {
+		t.Errorf("tokens %q and %q, want distinct random tokens", raw, other)
+	}
+	if record.TokenHash != hashToken(raw) || record.TokenHash == raw || len(record.TokenHash) != 64 {
+		t.Errorf("token hash = %q, want the SHA-256 of the token", record.TokenHash)
+	}
+	if record.UserID != 7 || record.FamilyID != "family" {
+		t.Errorf("record = %+v", record)
+	}
+	if until := time.Until(record.ExpiresAt); until < 59*time.Minute || until > time.Hour {
+		t.Errorf("expires in %s, want 1h", until)
+	}
+	if !record.IsActive() {
+		t.Error("a new token is not active")
+	}
+}
+
+func TestBuildTokenResponse(t *testing.T) {
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	t.Setenv("ACCESS_TOKEN_TTL", "10m")
+
+	user := models.User{ID: 42, Username: "jo", Email: "jo@example.com", TokenVersion: 3}
+	response, err := buildTokenResponse(user, "family", "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.RefreshToken != "refresh" || response.ExpiresIn != 600 || response.Username != "jo" || response.Email != "jo@example.com" {
+		t.Errorf("response = %+v", response)
+	}
+
+	claims := tokenClaims(t, response.Token)
+	if claims["sub"] != float64(42) || claims["fam"] != "family" || claims["ver"] != float64(3) {
+		t.Errorf("claims = %v", claims)
+	}
+	if claims["exp"].(float64)-claims["iat"].(float64) != 600 {
+		t.Errorf("claims = %v, want a 10 minute lifetime", claims)
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	user := createTestUser(t)
+
+	first, err := GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken: %v", err)
+	}
+	family := tokenClaims(t, first.Token)["fam"].(string)
+
+	second, err := RefreshTokens(first.RefreshToken)
+	if err != nil {
+		t.Fatalf("RefreshTokens: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || tokenClaims(t, second.Token)["fam"] != family {
+		t.Errorf("rotation gave %+v, want a new token in the same family", second)
+	}
+
+	// presenting the rotated token again is reuse: the whole family goes
+	if _, err := RefreshTokens(first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a rotated token: error = %v, want ErrRefreshTokenReused", err)
+	}
+	if revoked, err := IsTokenFamilyRevoked(family); err != nil || !revoked {
+		t.Errorf("family revoked = %v, %v, want true", revoked, err)
+	}
+	if _, err := RefreshTokens(second.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Errorf("refreshing after reuse: error = %v, want ErrRefreshTokenReused", err)
+	}
+
+	if _, err := RefreshTokens("not a token"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshTokenExpired(t *testing.T) {
+	useTestDB(t)
+	user := createTestUser(t)
+
+	raw, record, err := newRefreshToken(user.ID, user.Username)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := initializers.DB.Create(&record).Error; err != nil {
+		t.Fatalf("creating token: %v", err)
+	}
+
+	if _, err := RefreshTokens(raw); !errors.Is(err, ErrRefreshTokenExpired) {
+		t.Errorf("error = %v, want ErrRefreshTokenExpired", err)
+	}
+}
+
+func TestRevokeAllTokens(t *testing.T) {
+	useTestDB(t)
+	t.Setenv("JWT_SECRET", testJWTSecret)
+	user := createTestUser(t)
+
+	var sessions []tokenResponse
+	for i := 0; i < 2; i++ {
+		session, err := GenerateToken(user)
+		if err != nil {
+			t.Fatalf("GenerateToken: %v", err)
+		}
+		sessions = append(sessions, session)
+	}
+
+	if err := RevokeAllTokens(user); err != nil {
+		t.Fatalf("RevokeAllTokens: %v", err)
+	}
+
+	for _, session := range sessions {
+		if revoked, err := IsTokenFamilyRevoked(tokenClaims(t, session.Token)["fam"].(string)); err != nil || !revoked {
+			t.Errorf("family revoked = %v, %v, want true", revoked, err)
+		}
+	}
+
+	var reloaded models.User
+	if err := initializers.DB.First(&reloaded, user.ID).Error; err != nil {
+		t.Fatalf("reloading user: %v", err)
+	}
+	if reloaded.TokenVersion != user.TokenVersion+1 {
+		t.Errorf("token version = %d, want %d", reloaded.TokenVersion, user.TokenVersion+1)
+	}
+}
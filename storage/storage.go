@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"context"
+)
+
+// Storage stores uploaded files (product images, thumbnails) under a key
+// like "products/12/abc.jpg" and knows the public URL they are served from
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+}
@@ -0,0 +1,22 @@
+package validators
+
+import (
+	"reflect"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/roronoazor/goShopAPI/money"
+)
+
+// RegisterMoney lets binding tags like gt=0 on a money.Money field check its
+// amount in minor units
+func RegisterMoney() {
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterCustomTypeFunc(func(field reflect.Value) interface{} {
+			if value, ok := field.Interface().(money.Money); ok {
+				return value.Amount
+			}
+			return nil
+		}, money.Money{})
+	}
+}